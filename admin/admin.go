@@ -2,16 +2,67 @@ package admin
 
 import (
 	"context"
+	"errors"
+	"fmt"
+
+	"github.com/chuckboliver/assessment-tax/tax"
+)
+
+var (
+	// ErrConfigNotFound is returned when a config key is set for a tax year that
+	// has no tax brackets, so that a mistyped tax year does not create config
+	// that no calculation uses.
+	ErrConfigNotFound = errors.New("tax config not found")
+	// ErrConfigConflict is returned when a setting is changed on the condition of
+	// a value it no longer has, e.g. after another admin changed it.
+	ErrConfigConflict = errors.New("tax config conflict")
+	ErrUnknownSetting = errors.New("unknown setting")
 )
 
+// SettingRangeError is returned when a setting is changed to a value out of its
+// bounds.
+type SettingRangeError struct {
+	Setting tax.Setting
+	Value   tax.ConfigValue
+}
+
+func (e *SettingRangeError) Error() string {
+	return fmt.Sprintf("%s must be between %s and %s", e.Setting.Key, e.Setting.Min, e.Setting.Max)
+}
+
+// SettingValue is the value of a setting for a tax year. Source is
+// tax.ConfigSourceDefault when the tax year does not configure the setting.
+type SettingValue struct {
+	tax.Setting
+	TaxYear int
+	Value   tax.ConfigValue
+	Source  tax.ConfigSource
+}
+
 type AdminRepository interface {
-	UpdatePersonalDeduction(ctx context.Context, personalDeduction float64) (float64, error)
-	UpdateKReceiptDeduction(ctx context.Context, kReceiptDeduction float64) (float64, error)
+	// FindSettings returns the values configured for taxYear by key.
+	FindSettings(ctx context.Context, taxYear int) (map[string]tax.ConfigValue, error)
+	// UpdateSetting and ReplaceTaxBrackets record the change in the audit log, in
+	// the same transaction. UpdateSetting returns ErrConfigConflict when
+	// expectedValue is not nil and differs from the value of the setting, its
+	// default when the tax year does not configure it.
+	UpdateSetting(ctx context.Context, actor AuditActor, taxYear int, setting tax.Setting, value tax.ConfigValue, expectedValue *tax.ConfigValue) (tax.ConfigValue, error)
+	FindTaxBrackets(ctx context.Context, taxYear int) ([]tax.TaxBracket, error)
+	ReplaceTaxBrackets(ctx context.Context, actor AuditActor, taxYear int, brackets []tax.TaxBracket) ([]tax.TaxBracket, error)
+	// FindAuditLog returns a page of the audit log and the number of entries
+	// matching the filter.
+	FindAuditLog(ctx context.Context, filter AuditFilter) ([]AuditEntry, int, error)
 }
 
 type AdminService interface {
-	UpdatePersonalDeduction(ctx context.Context, personalDeduction float64) (float64, error)
-	UpdateKReceiptDeduction(ctx context.Context, kReceiptDeduction float64) (float64, error)
+	FindSettings(ctx context.Context, taxYear int) ([]SettingValue, error)
+	FindSetting(ctx context.Context, taxYear int, key string) (SettingValue, error)
+	// UpdateSetting changes a setting to value. Given an expectedValue, the setting
+	// is only changed if it still has that value.
+	UpdateSetting(ctx context.Context, actor AuditActor, taxYear int, key string, value tax.ConfigValue, expectedValue *tax.ConfigValue) (SettingValue, error)
+	FindTaxBrackets(ctx context.Context, taxYear int) ([]tax.TaxBracket, error)
+	ReplaceTaxBrackets(ctx context.Context, actor AuditActor, taxYear int, brackets []tax.TaxBracket) ([]tax.TaxBracket, error)
+	FindAuditLog(ctx context.Context, filter AuditFilter) (AuditPage, error)
 }
 
 var _ AdminService = (*adminService)(nil)
@@ -26,10 +77,99 @@ func NewAdminService(adminRepository AdminRepository) AdminService {
 	}
 }
 
-func (a *adminService) UpdatePersonalDeduction(ctx context.Context, personalDeduction float64) (float64, error) {
-	return a.adminRepository.UpdatePersonalDeduction(ctx, personalDeduction)
+func (a *adminService) FindSettings(ctx context.Context, taxYear int) ([]SettingValue, error) {
+	values, err := a.adminRepository.FindSettings(ctx, taxYear)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := tax.Settings()
+	settingValues := make([]SettingValue, 0, len(settings))
+	for _, v := range settings {
+		settingValues = append(settingValues, newSettingValue(v, taxYear, values))
+	}
+
+	return settingValues, nil
+}
+
+func (a *adminService) FindSetting(ctx context.Context, taxYear int, key string) (SettingValue, error) {
+	setting, ok := tax.FindSetting(key)
+	if !ok {
+		return SettingValue{}, fmt.Errorf("%w: %q", ErrUnknownSetting, key)
+	}
+
+	values, err := a.adminRepository.FindSettings(ctx, taxYear)
+	if err != nil {
+		return SettingValue{}, err
+	}
+
+	return newSettingValue(setting, taxYear, values), nil
 }
 
-func (a *adminService) UpdateKReceiptDeduction(ctx context.Context, kReceiptDeduction float64) (float64, error) {
-	return a.adminRepository.UpdateKReceiptDeduction(ctx, kReceiptDeduction)
+func (a *adminService) UpdateSetting(ctx context.Context, actor AuditActor, taxYear int, key string, value tax.ConfigValue, expectedValue *tax.ConfigValue) (SettingValue, error) {
+	setting, ok := tax.FindSetting(key)
+	if !ok {
+		return SettingValue{}, fmt.Errorf("%w: %q", ErrUnknownSetting, key)
+	}
+
+	if value < setting.Min || value > setting.Max {
+		return SettingValue{}, &SettingRangeError{Setting: setting, Value: value}
+	}
+
+	updatedValue, err := a.adminRepository.UpdateSetting(ctx, actor, taxYear, setting, value, expectedValue)
+	if err != nil {
+		return SettingValue{}, err
+	}
+
+	return SettingValue{
+		Setting: setting,
+		TaxYear: taxYear,
+		Value:   updatedValue,
+		Source:  tax.ConfigSourceDatabase,
+	}, nil
+}
+
+// newSettingValue returns the value of setting in values, or its default when
+// taxYear does not configure it.
+func newSettingValue(setting tax.Setting, taxYear int, values map[string]tax.ConfigValue) SettingValue {
+	value, ok := values[setting.Key]
+	if !ok {
+		return SettingValue{Setting: setting, TaxYear: taxYear, Value: setting.Default, Source: tax.ConfigSourceDefault}
+	}
+
+	return SettingValue{Setting: setting, TaxYear: taxYear, Value: value, Source: tax.ConfigSourceDatabase}
+}
+
+func (a *adminService) FindTaxBrackets(ctx context.Context, taxYear int) ([]tax.TaxBracket, error) {
+	return a.adminRepository.FindTaxBrackets(ctx, taxYear)
+}
+
+func (a *adminService) ReplaceTaxBrackets(ctx context.Context, actor AuditActor, taxYear int, brackets []tax.TaxBracket) ([]tax.TaxBracket, error) {
+	if err := tax.ValidateTaxBrackets(brackets); err != nil {
+		return nil, err
+	}
+
+	return a.adminRepository.ReplaceTaxBrackets(ctx, actor, taxYear, brackets)
+}
+
+// FindAuditLog returns a page of the audit log. A limit out of range is replaced
+// by the default or the maximum page size.
+func (a *adminService) FindAuditLog(ctx context.Context, filter AuditFilter) (AuditPage, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAuditPageLimit
+	}
+	filter.Limit = min(filter.Limit, maxAuditPageLimit)
+	filter.Offset = max(filter.Offset, 0)
+
+	entries, total, err := a.adminRepository.FindAuditLog(ctx, filter)
+	if err != nil {
+		return AuditPage{}, err
+	}
+
+	return AuditPage{
+		Entries: entries,
+		Total:   total,
+		Limit:   filter.Limit,
+		Offset:  filter.Offset,
+	}, nil
 }
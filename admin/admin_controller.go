@@ -1,16 +1,25 @@
 package admin
 
 import (
-	"log/slog"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/chuckboliver/assessment-tax/common"
+	"github.com/chuckboliver/assessment-tax/tax"
 	"github.com/labstack/echo/v4"
-	"github.com/labstack/echo/v4/middleware"
+	"github.com/shopspring/decimal"
 )
 
 var _ common.Controller = (*AdminController)(nil)
 
+const (
+	ErrorCodeInvalidTaxBrackets common.ErrorCode = "INVALID_TAX_BRACKETS"
+	ErrorCodeConfigConflict     common.ErrorCode = "CONFIG_CONFLICT"
+)
+
 type AdminController struct {
 	adminService AdminService
 	appConfig    common.AppConfig
@@ -24,88 +33,427 @@ func NewAdminController(adminService AdminService, appConfig common.AppConfig) A
 }
 
 func (a *AdminController) RouteConfig(e *echo.Echo) {
-	group := e.Group("/admin/deductions")
-	group.Use(middleware.BasicAuth(func(username, password string, c echo.Context) (bool, error) {
-		return username == a.appConfig.AdminUsername && password == a.appConfig.AdminPassword, nil
-	}))
+	group := e.Group("/admin")
+	group.Use(common.AdminBasicAuth(a.appConfig))
 	{
-		group.POST("/personal", a.updatePersonalDeduction)
-		group.POST("/k-receipt", a.updateKReceiptDeduction)
+		group.GET("/settings", a.getSettings)
+		group.GET("/settings/:key", a.getSetting)
+		// A setting has a single value, so a PATCH replaces it like a PUT.
+		group.PUT("/settings/:key", a.updateSetting)
+		group.PATCH("/settings/:key", a.updateSetting)
+		// The deduction routes predate settings and are kept for existing clients.
+		group.POST("/deductions/personal", a.updatePersonalDeduction)
+		group.POST("/deductions/k-receipt", a.updateKReceiptDeduction)
+		group.GET("/brackets", a.getTaxBrackets)
+		group.PUT("/brackets", a.replaceTaxBrackets)
+		group.GET("/audit-log", a.getAuditLog)
 	}
 }
 
+type settingResponse struct {
+	Key         string           `json:"key"`
+	TaxYear     int              `json:"taxYear"`
+	Value       tax.ConfigValue  `json:"value"`
+	Source      tax.ConfigSource `json:"source"`
+	Unit        tax.SettingUnit  `json:"unit"`
+	Description string           `json:"description"`
+	Min         tax.ConfigValue  `json:"min"`
+	Max         tax.ConfigValue  `json:"max"`
+}
+
+type settingsResponse struct {
+	TaxYear  int               `json:"taxYear"`
+	Settings []settingResponse `json:"settings"`
+}
+
+func newSettingResponse(settingValue SettingValue) settingResponse {
+	return settingResponse{
+		Key:         settingValue.Key,
+		TaxYear:     settingValue.TaxYear,
+		Value:       settingValue.Value,
+		Source:      settingValue.Source,
+		Unit:        settingValue.Unit,
+		Description: settingValue.Description,
+		Min:         settingValue.Min,
+		Max:         settingValue.Max,
+	}
+}
+
+func (a *AdminController) getSettings(ctx echo.Context) error {
+	taxYear, err := bindTaxYearQueryParam(ctx)
+	if err != nil {
+		return err
+	}
+
+	settingValues, err := a.adminService.FindSettings(ctx.Request().Context(), taxYear)
+	if err != nil {
+		return fmt.Errorf("get settings: %w", err)
+	}
+
+	response := settingsResponse{
+		TaxYear:  taxYear,
+		Settings: make([]settingResponse, 0, len(settingValues)),
+	}
+	for _, v := range settingValues {
+		response.Settings = append(response.Settings, newSettingResponse(v))
+	}
+
+	return ctx.JSON(http.StatusOK, response)
+}
+
+func (a *AdminController) getSetting(ctx echo.Context) error {
+	taxYear, err := bindTaxYearQueryParam(ctx)
+	if err != nil {
+		return err
+	}
+
+	settingValue, err := a.adminService.FindSetting(ctx.Request().Context(), taxYear, ctx.Param("key"))
+	if err != nil {
+		return configError(fmt.Errorf("get setting: %w", err))
+	}
+
+	return ctx.JSON(http.StatusOK, newSettingResponse(settingValue))
+}
+
+// updateSettingRequest changes a setting to Value. Given ExpectedValue, the
+// setting is only changed if it still has that value, so that an admin does not
+// overwrite a change made since they read it.
+type updateSettingRequest struct {
+	Value         *tax.ConfigValue `json:"value" validate:"required"`
+	ExpectedValue *tax.ConfigValue `json:"expectedValue"`
+}
+
+func (a *AdminController) updateSetting(ctx echo.Context) error {
+	taxYear, err := bindTaxYearQueryParam(ctx)
+	if err != nil {
+		return err
+	}
+
+	var request updateSettingRequest
+	if err := ctx.Bind(&request); err != nil {
+		return err
+	}
+
+	if err := ctx.Validate(&request); err != nil {
+		return err
+	}
+
+	settingValue, err := a.adminService.UpdateSetting(ctx.Request().Context(), auditActor(ctx), taxYear, ctx.Param("key"), *request.Value, request.ExpectedValue)
+	if err != nil {
+		return configError(fmt.Errorf("update setting: %w", err))
+	}
+
+	return ctx.JSON(http.StatusOK, newSettingResponse(settingValue))
+}
+
 type updatePersonalDeductionRequest struct {
-	Amount float64 `json:"amount" validate:"required,lte=100000,gte=10000"`
+	TaxYear int          `json:"taxYear" validate:"omitempty,gt=0"`
+	Amount  common.Money `json:"amount" validate:"required,lte=100000,gte=10000"`
 }
 
 type updatePersonalDeductionResponse struct {
-	PersonalDeduction common.Float64 `json:"personalDeduction"`
+	PersonalDeduction common.Money `json:"personalDeduction"`
 }
 
 func (a *AdminController) updatePersonalDeduction(ctx echo.Context) error {
 	var request updatePersonalDeductionRequest
 	if err := ctx.Bind(&request); err != nil {
-		ctx.JSON(http.StatusBadRequest, common.ErrorResponse{
-			Message: err.Error(),
-		})
 		return err
 	}
 
 	if err := ctx.Validate(&request); err != nil {
-		ctx.JSON(http.StatusBadRequest, common.ErrorResponse{
-			Message: err.Error(),
-		})
 		return err
 	}
 
-	updatedPersonalDeduction, err := a.adminService.UpdatePersonalDeduction(ctx.Request().Context(), request.Amount)
+	settingValue, err := a.adminService.UpdateSetting(ctx.Request().Context(), auditActor(ctx), taxYearOrDefault(request.TaxYear), tax.SettingPersonalDeduction.Key, tax.ConfigValueOf(request.Amount), nil)
 	if err != nil {
-		slog.Error("Failed to update personal deduction", err)
-		ctx.NoContent(http.StatusInternalServerError)
-		return err
+		return configError(fmt.Errorf("update personal deduction: %w", err))
 	}
 
 	response := updatePersonalDeductionResponse{
-		PersonalDeduction: common.Float64(updatedPersonalDeduction),
+		PersonalDeduction: settingValue.Value.Money(),
 	}
 
 	return ctx.JSON(http.StatusOK, response)
 }
 
 type updateKReceiptDeductionRequest struct {
-	Amount float64 `json:"amount" validate:"required,lte=100000,gte=0"`
+	TaxYear int          `json:"taxYear" validate:"omitempty,gt=0"`
+	Amount  common.Money `json:"amount" validate:"required,lte=100000,gte=0"`
 }
 
 type updateKReceiptDeductionResponse struct {
-	KReceipt common.Float64 `json:"kReceipt"`
+	KReceipt common.Money `json:"kReceipt"`
 }
 
 func (a *AdminController) updateKReceiptDeduction(ctx echo.Context) error {
 	var request updateKReceiptDeductionRequest
 	if err := ctx.Bind(&request); err != nil {
-		ctx.JSON(http.StatusBadRequest, common.ErrorResponse{
-			Message: err.Error(),
+		return err
+	}
+
+	if err := ctx.Validate(&request); err != nil {
+		return err
+	}
+
+	settingValue, err := a.adminService.UpdateSetting(ctx.Request().Context(), auditActor(ctx), taxYearOrDefault(request.TaxYear), tax.SettingKReceiptDeduction.Key, tax.ConfigValueOf(request.Amount), nil)
+	if err != nil {
+		return configError(fmt.Errorf("update k-receipt deduction: %w", err))
+	}
+
+	response := updateKReceiptDeductionResponse{
+		KReceipt: settingValue.Value.Money(),
+	}
+
+	return ctx.JSON(http.StatusOK, response)
+}
+
+// maxRateDecimalPlaces is the scale of the rate column of tax_brackets, which
+// would otherwise round a finer rate silently.
+const maxRateDecimalPlaces = 4
+
+type taxBracketRequest struct {
+	LowerBound common.Money    `json:"lowerBound" validate:"gte=0,lte=1000000000000"`
+	UpperBound *common.Money   `json:"upperBound" validate:"omitempty,gtfield=LowerBound,lte=1000000000000"`
+	Rate       decimal.Decimal `json:"rate"`
+}
+
+type replaceTaxBracketsRequest struct {
+	Brackets []taxBracketRequest `json:"brackets" validate:"required,min=1,dive"`
+}
+
+// Validate checks the rates of the brackets, which tags cannot compare as
+// decimals.
+func (r replaceTaxBracketsRequest) Validate() error {
+	violations := make([]common.Violation, 0)
+	for i, v := range r.Brackets {
+		field := fmt.Sprintf("brackets[%d].rate", i)
+		switch {
+		case v.Rate.IsNegative():
+			violations = append(violations, common.Violation{Field: field, Rule: "gte", Param: "0"})
+		case v.Rate.GreaterThan(decimal.NewFromInt(1)):
+			violations = append(violations, common.Violation{Field: field, Rule: "lte", Param: "1"})
+		case !v.Rate.Equal(v.Rate.Round(maxRateDecimalPlaces)):
+			violations = append(violations, common.Violation{Field: field, Rule: "max_decimal_places", Param: strconv.Itoa(maxRateDecimalPlaces)})
+		}
+	}
+
+	if len(violations) > 0 {
+		return common.NewValidationError(violations...)
+	}
+
+	return nil
+}
+
+type taxBracketsResponse struct {
+	TaxYear  int                  `json:"taxYear"`
+	Brackets []taxBracketResponse `json:"brackets"`
+}
+
+type taxBracketResponse struct {
+	Level      string          `json:"level"`
+	LowerBound common.Money    `json:"lowerBound"`
+	UpperBound *common.Money   `json:"upperBound"`
+	Rate       decimal.Decimal `json:"rate"`
+}
+
+func newTaxBracketsResponse(taxYear int, brackets []tax.TaxBracket) taxBracketsResponse {
+	response := taxBracketsResponse{
+		TaxYear:  taxYear,
+		Brackets: make([]taxBracketResponse, 0, len(brackets)),
+	}
+
+	for _, v := range brackets {
+		response.Brackets = append(response.Brackets, taxBracketResponse{
+			Level:      v.Level(),
+			LowerBound: v.LowerBound,
+			UpperBound: v.UpperBound,
+			Rate:       v.Rate,
 		})
+	}
+
+	return response
+}
+
+func (a *AdminController) getTaxBrackets(ctx echo.Context) error {
+	taxYear, err := bindTaxYearQueryParam(ctx)
+	if err != nil {
+		return err
+	}
+
+	brackets, err := a.adminService.FindTaxBrackets(ctx.Request().Context(), taxYear)
+	if err != nil {
+		return fmt.Errorf("get tax brackets: %w", err)
+	}
+
+	return ctx.JSON(http.StatusOK, newTaxBracketsResponse(taxYear, brackets))
+}
+
+func (a *AdminController) replaceTaxBrackets(ctx echo.Context) error {
+	taxYear, err := bindTaxYearQueryParam(ctx)
+	if err != nil {
+		return err
+	}
+
+	var request replaceTaxBracketsRequest
+	if err := ctx.Bind(&request); err != nil {
 		return err
 	}
 
 	if err := ctx.Validate(&request); err != nil {
-		ctx.JSON(http.StatusBadRequest, common.ErrorResponse{
-			Message: err.Error(),
+		return err
+	}
+
+	brackets := make([]tax.TaxBracket, 0, len(request.Brackets))
+	for _, v := range request.Brackets {
+		brackets = append(brackets, tax.TaxBracket{
+			LowerBound: v.LowerBound,
+			UpperBound: v.UpperBound,
+			Rate:       v.Rate,
 		})
+	}
+
+	replacedBrackets, err := a.adminService.ReplaceTaxBrackets(ctx.Request().Context(), auditActor(ctx), taxYear, brackets)
+	if errors.Is(err, tax.ErrInvalidTaxBrackets) {
+		return common.NewError(http.StatusBadRequest, ErrorCodeInvalidTaxBrackets, err).
+			WithMessageTH("ขั้นบันไดภาษีไม่ถูกต้อง")
+	}
+	if err != nil {
+		return fmt.Errorf("replace tax brackets: %w", err)
+	}
+
+	return ctx.JSON(http.StatusOK, newTaxBracketsResponse(taxYear, replacedBrackets))
+}
+
+type getAuditLogRequest struct {
+	Key    string `query:"key"`
+	From   string `query:"from"`
+	To     string `query:"to"`
+	Limit  int    `query:"limit" validate:"gte=0,lte=100"`
+	Offset int    `query:"offset" validate:"gte=0"`
+}
+
+func (a *AdminController) getAuditLog(ctx echo.Context) error {
+	var request getAuditLogRequest
+	if err := ctx.Bind(&request); err != nil {
+		return err
+	}
+
+	if err := ctx.Validate(&request); err != nil {
 		return err
 	}
 
-	updatedKReceiptDeduction, err := a.adminService.UpdateKReceiptDeduction(ctx.Request().Context(), request.Amount)
+	from, err := parseAuditTime("from", request.From, false)
 	if err != nil {
-		slog.Error("Failed to update personal deduction", err)
-		ctx.NoContent(http.StatusInternalServerError)
 		return err
 	}
 
-	response := updateKReceiptDeductionResponse{
-		KReceipt: common.Float64(updatedKReceiptDeduction),
+	to, err := parseAuditTime("to", request.To, true)
+	if err != nil {
+		return err
 	}
 
-	return ctx.JSON(http.StatusOK, response)
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return common.NewValidationError(common.Violation{Field: "to", Rule: "gtfield", Param: "from"})
+	}
+
+	page, err := a.adminService.FindAuditLog(ctx.Request().Context(), AuditFilter{
+		Key:    request.Key,
+		From:   from,
+		To:     to,
+		Limit:  request.Limit,
+		Offset: request.Offset,
+	})
+	if err != nil {
+		return fmt.Errorf("get audit log: %w", err)
+	}
+
+	return ctx.JSON(http.StatusOK, page)
+}
+
+// parseAuditTime parses a bound of the date range of the audit log, either a
+// RFC 3339 time or a date. A date as the end of the range includes the whole day.
+func parseAuditTime(field string, value string, end bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, common.NewValidationError(common.Violation{Field: field, Rule: "datetime", Param: time.RFC3339})
+	}
+
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+
+	return t, nil
+}
+
+// auditActor returns who made the request, as recorded in the audit log.
+func auditActor(ctx echo.Context) AuditActor {
+	username, _ := ctx.Get(common.AdminUsernameKey).(string)
+	return AuditActor{
+		Username:  username,
+		ClientIP:  ctx.RealIP(),
+		RequestID: ctx.Response().Header().Get(echo.HeaderXRequestID),
+	}
+}
+
+// configError maps the errors of reading or changing a setting to the response
+// reported to the client. Other errors are returned as they are.
+func configError(err error) error {
+	var rangeErr *SettingRangeError
+	if errors.As(err, &rangeErr) {
+		violation := common.Violation{Field: "value", Rule: "gte", Param: rangeErr.Setting.Min.String()}
+		if rangeErr.Value > rangeErr.Setting.Max {
+			violation = common.Violation{Field: "value", Rule: "lte", Param: rangeErr.Setting.Max.String()}
+		}
+
+		return common.NewValidationError(violation)
+	}
+
+	switch {
+	case errors.Is(err, ErrUnknownSetting):
+		return common.NewError(http.StatusNotFound, common.ErrorCodeNotFound, err).
+			WithMessageTH("ไม่พบการตั้งค่าที่ต้องการ")
+	case errors.Is(err, ErrConfigNotFound):
+		return common.NewError(http.StatusNotFound, common.ErrorCodeNotFound, err).
+			WithMessageTH("ไม่พบการตั้งค่าภาษีของปีภาษีนี้")
+	case errors.Is(err, ErrConfigConflict):
+		return common.NewError(http.StatusConflict, ErrorCodeConfigConflict, err).
+			WithMessageTH("การตั้งค่าภาษีถูกแก้ไขไปแล้ว กรุณาอ่านค่าล่าสุดและลองใหม่อีกครั้ง")
+	}
+
+	return err
+}
+
+func taxYearOrDefault(taxYear int) int {
+	if taxYear == 0 {
+		return tax.DefaultTaxYear
+	}
+
+	return taxYear
+}
+
+func bindTaxYearQueryParam(ctx echo.Context) (int, error) {
+	var taxYear int
+	if err := echo.QueryParamsBinder(ctx).Int("taxYear", &taxYear).BindError(); err != nil {
+		return 0, err
+	}
+
+	if taxYear < 0 {
+		return 0, &common.Error{
+			Status:     http.StatusBadRequest,
+			Code:       common.ErrorCodeValidationFailed,
+			Message:    "taxYear must be a positive number",
+			Violations: []common.Violation{{Field: "taxYear", Rule: "gt", Param: "0"}},
+		}
+	}
+
+	return taxYearOrDefault(taxYear), nil
 }
@@ -2,14 +2,20 @@ package admin
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/chuckboliver/assessment-tax/common"
+	"github.com/chuckboliver/assessment-tax/tax"
 	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/require"
 )
 
@@ -29,7 +35,7 @@ func TestPostUpdatePersonalDeduction(t *testing.T) {
 				}
 			`,
 			adminServiceStub: func(adminService *MockAdminService) {
-				adminService.EXPECT().UpdatePersonalDeduction(gomock.Any(), 100000.0).Times(1).Return(100000.0, nil)
+				adminService.EXPECT().UpdateSetting(gomock.Any(), gomock.Any(), tax.DefaultTaxYear, tax.SettingPersonalDeduction.Key, tax.NewConfigValue(100000), nil).Times(1).Return(SettingValue{Setting: tax.SettingPersonalDeduction, TaxYear: tax.DefaultTaxYear, Value: tax.NewConfigValue(100000), Source: tax.ConfigSourceDatabase}, nil)
 			},
 			expectedStatusCode: http.StatusOK,
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
@@ -40,9 +46,23 @@ func TestPostUpdatePersonalDeduction(t *testing.T) {
 				err = json.Unmarshal(responseBody, &actualResponse)
 				require.NoError(t, err)
 
-				require.Equal(t, common.Float64(100000.0), actualResponse.PersonalDeduction)
+				require.Equal(t, common.Baht(100000), actualResponse.PersonalDeduction)
 			},
 		},
+		{
+			name: "Should response with 200 status code, given valid request with tax year",
+			body: `
+				{
+					"taxYear": 2566,
+					"amount": 50000.0
+				}
+			`,
+			adminServiceStub: func(adminService *MockAdminService) {
+				adminService.EXPECT().UpdateSetting(gomock.Any(), gomock.Any(), 2566, tax.SettingPersonalDeduction.Key, tax.NewConfigValue(50000), nil).Times(1).Return(SettingValue{Setting: tax.SettingPersonalDeduction, TaxYear: 2566, Value: tax.NewConfigValue(50000), Source: tax.ConfigSourceDatabase}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			checkResponse:      func(t *testing.T, recorder *httptest.ResponseRecorder) {},
+		},
 		{
 			name: "Should response with 400 status code, given invalid request",
 			body: `
@@ -51,7 +71,7 @@ func TestPostUpdatePersonalDeduction(t *testing.T) {
 				}
 			`,
 			adminServiceStub: func(adminService *MockAdminService) {
-				adminService.EXPECT().UpdatePersonalDeduction(gomock.Any(), gomock.Any()).Times(0)
+				adminService.EXPECT().UpdateSetting(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
 			},
 			expectedStatusCode: http.StatusBadRequest,
 			checkResponse:      func(t *testing.T, recorder *httptest.ResponseRecorder) {},
@@ -64,10 +84,37 @@ func TestPostUpdatePersonalDeduction(t *testing.T) {
 				}
 			`,
 			adminServiceStub: func(adminService *MockAdminService) {
-				adminService.EXPECT().UpdatePersonalDeduction(gomock.Any(), gomock.Any()).Times(0)
+				adminService.EXPECT().UpdateSetting(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
 			},
 			expectedStatusCode: http.StatusBadRequest,
-			checkResponse:      func(t *testing.T, recorder *httptest.ResponseRecorder) {},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				var response common.ErrorResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &response)
+				require.NoError(t, err)
+
+				require.Equal(t, common.ErrorCodeValidationFailed, response.Code)
+				require.Equal(t, []common.Violation{{Field: "amount", Rule: "lte", Param: "100000"}}, response.Violations)
+			},
+		},
+		{
+			name: "Should response with 404 status code, given tax year without tax config",
+			body: `
+				{
+					"taxYear": 2570,
+					"amount": 50000.0
+				}
+			`,
+			adminServiceStub: func(adminService *MockAdminService) {
+				adminService.EXPECT().UpdateSetting(gomock.Any(), gomock.Any(), 2570, tax.SettingPersonalDeduction.Key, tax.NewConfigValue(50000), nil).Times(1).Return(SettingValue{}, fmt.Errorf("%w: personal_deduction of tax year 2570", ErrConfigNotFound))
+			},
+			expectedStatusCode: http.StatusNotFound,
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				var response common.ErrorResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &response)
+				require.NoError(t, err)
+
+				require.Equal(t, common.ErrorCodeNotFound, response.Code)
+			},
 		},
 	}
 
@@ -122,7 +169,7 @@ func TestPostUpdateKReceiptDeduction(t *testing.T) {
 				}
 			`,
 			adminServiceStub: func(adminService *MockAdminService) {
-				adminService.EXPECT().UpdateKReceiptDeduction(gomock.Any(), 100000.0).Times(1).Return(100000.0, nil)
+				adminService.EXPECT().UpdateSetting(gomock.Any(), gomock.Any(), tax.DefaultTaxYear, tax.SettingKReceiptDeduction.Key, tax.NewConfigValue(100000), nil).Times(1).Return(SettingValue{Setting: tax.SettingKReceiptDeduction, TaxYear: tax.DefaultTaxYear, Value: tax.NewConfigValue(100000), Source: tax.ConfigSourceDatabase}, nil)
 			},
 			expectedStatusCode: http.StatusOK,
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
@@ -133,7 +180,7 @@ func TestPostUpdateKReceiptDeduction(t *testing.T) {
 				err = json.Unmarshal(responseBody, &actualResponse)
 				require.NoError(t, err)
 
-				require.Equal(t, common.Float64(100000.0), actualResponse.KReceipt)
+				require.Equal(t, common.Baht(100000), actualResponse.KReceipt)
 			},
 		},
 		{
@@ -144,7 +191,7 @@ func TestPostUpdateKReceiptDeduction(t *testing.T) {
 				}
 			`,
 			adminServiceStub: func(adminService *MockAdminService) {
-				adminService.EXPECT().UpdateKReceiptDeduction(gomock.Any(), gomock.Any()).Times(0)
+				adminService.EXPECT().UpdateSetting(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
 			},
 			expectedStatusCode: http.StatusBadRequest,
 			checkResponse:      func(t *testing.T, recorder *httptest.ResponseRecorder) {},
@@ -157,11 +204,25 @@ func TestPostUpdateKReceiptDeduction(t *testing.T) {
 				}
 			`,
 			adminServiceStub: func(adminService *MockAdminService) {
-				adminService.EXPECT().UpdateKReceiptDeduction(gomock.Any(), gomock.Any()).Times(0)
+				adminService.EXPECT().UpdateSetting(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
 			},
 			expectedStatusCode: http.StatusBadRequest,
 			checkResponse:      func(t *testing.T, recorder *httptest.ResponseRecorder) {},
 		},
+		{
+			name: "Should response with 404 status code, given tax year without tax config",
+			body: `
+				{
+					"taxYear": 2570,
+					"amount": 50000.0
+				}
+			`,
+			adminServiceStub: func(adminService *MockAdminService) {
+				adminService.EXPECT().UpdateSetting(gomock.Any(), gomock.Any(), 2570, tax.SettingKReceiptDeduction.Key, tax.NewConfigValue(50000), nil).Times(1).Return(SettingValue{}, ErrConfigNotFound)
+			},
+			expectedStatusCode: http.StatusNotFound,
+			checkResponse:      func(t *testing.T, recorder *httptest.ResponseRecorder) {},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -198,3 +259,543 @@ func TestPostUpdateKReceiptDeduction(t *testing.T) {
 		})
 	}
 }
+
+func TestGetTaxBrackets(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	appConfig := common.AppConfig{
+		AdminUsername: "admin",
+		AdminPassword: "P@ssw0rd",
+	}
+	adminService := NewMockAdminService(ctrl)
+	adminController := NewAdminController(adminService, appConfig)
+
+	upperBound := common.Baht(150000)
+	adminService.EXPECT().FindTaxBrackets(gomock.Any(), 2566).Times(1).Return([]tax.TaxBracket{
+		{LowerBound: 0, UpperBound: &upperBound, Rate: decimal.RequireFromString("0")},
+		{LowerBound: common.Baht(150000), UpperBound: nil, Rate: decimal.RequireFromString("0.1")},
+	}, nil)
+
+	e := common.NewConfiguredEcho()
+
+	adminController.RouteConfig(e)
+
+	request, err := http.NewRequest(http.MethodGet, "/admin/brackets?taxYear=2566", nil)
+	require.NoError(t, err)
+
+	request.SetBasicAuth("admin", "P@ssw0rd")
+
+	recorder := httptest.NewRecorder()
+	e.ServeHTTP(recorder, request)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var actualResponse taxBracketsResponse
+	err = json.Unmarshal(recorder.Body.Bytes(), &actualResponse)
+	require.NoError(t, err)
+
+	require.Equal(t, 2566, actualResponse.TaxYear)
+	require.Equal(t, []taxBracketResponse{
+		{Level: "0-150,000", LowerBound: 0, UpperBound: &upperBound, Rate: decimal.RequireFromString("0")},
+		{Level: "150,001 ขึ้นไป", LowerBound: common.Baht(150000), UpperBound: nil, Rate: decimal.RequireFromString("0.1")},
+	}, actualResponse.Brackets)
+}
+
+func TestPutReplaceTaxBrackets(t *testing.T) {
+	testCases := []struct {
+		name               string
+		body               string
+		adminServiceStub   func(adminService *MockAdminService)
+		expectedStatusCode int
+	}{
+		{
+			name: "Should response with 200 status code, given valid brackets",
+			body: `
+				{
+					"brackets": [
+						{ "lowerBound": 0, "upperBound": 150000, "rate": 0 },
+						{ "lowerBound": 150000, "upperBound": null, "rate": 0.1 }
+					]
+				}
+			`,
+			adminServiceStub: func(adminService *MockAdminService) {
+				adminService.EXPECT().ReplaceTaxBrackets(gomock.Any(), gomock.Any(), tax.DefaultTaxYear, gomock.Len(2)).Times(1).DoAndReturn(
+					func(_ context.Context, _ AuditActor, _ int, brackets []tax.TaxBracket) ([]tax.TaxBracket, error) {
+						return brackets, nil
+					},
+				)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name: "Should keep rate exact, given rate with 4 decimal places",
+			body: `
+				{
+					"brackets": [
+						{ "lowerBound": 0, "upperBound": null, "rate": 0.1234 }
+					]
+				}
+			`,
+			adminServiceStub: func(adminService *MockAdminService) {
+				adminService.EXPECT().ReplaceTaxBrackets(gomock.Any(), gomock.Any(), tax.DefaultTaxYear, []tax.TaxBracket{
+					{LowerBound: 0, Rate: decimal.RequireFromString("0.1234")},
+				}).Times(1).DoAndReturn(
+					func(_ context.Context, _ AuditActor, _ int, brackets []tax.TaxBracket) ([]tax.TaxBracket, error) {
+						return brackets, nil
+					},
+				)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name: "Should response with 400 status code, given rate with more than 4 decimal places",
+			body: `
+				{
+					"brackets": [
+						{ "lowerBound": 0, "upperBound": null, "rate": 0.12345 }
+					]
+				}
+			`,
+			adminServiceStub: func(adminService *MockAdminService) {
+				adminService.EXPECT().ReplaceTaxBrackets(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "Should response with 400 status code, given empty brackets",
+			body: `
+				{
+					"brackets": []
+				}
+			`,
+			adminServiceStub: func(adminService *MockAdminService) {
+				adminService.EXPECT().ReplaceTaxBrackets(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "Should response with 400 status code, given rate greater than 1",
+			body: `
+				{
+					"brackets": [
+						{ "lowerBound": 0, "upperBound": null, "rate": 35 }
+					]
+				}
+			`,
+			adminServiceStub: func(adminService *MockAdminService) {
+				adminService.EXPECT().ReplaceTaxBrackets(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "Should response with 400 status code, given non-contiguous brackets",
+			body: `
+				{
+					"brackets": [
+						{ "lowerBound": 0, "upperBound": 150000, "rate": 0 },
+						{ "lowerBound": 200000, "upperBound": null, "rate": 0.1 }
+					]
+				}
+			`,
+			adminServiceStub: func(adminService *MockAdminService) {
+				adminService.EXPECT().ReplaceTaxBrackets(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).
+					Return(nil, fmt.Errorf("%w: gap between brackets", tax.ErrInvalidTaxBrackets))
+			},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			appConfig := common.AppConfig{
+				AdminUsername: "admin",
+				AdminPassword: "P@ssw0rd",
+			}
+			adminService := NewMockAdminService(ctrl)
+			adminController := NewAdminController(adminService, appConfig)
+
+			tc.adminServiceStub(adminService)
+
+			e := common.NewConfiguredEcho()
+
+			adminController.RouteConfig(e)
+
+			url := "/admin/brackets"
+			request, err := http.NewRequest(http.MethodPut, url, bytes.NewReader([]byte(tc.body)))
+			require.NoError(t, err)
+
+			request.SetBasicAuth("admin", "P@ssw0rd")
+			request.Header.Set("Content-Type", "application/json")
+
+			recorder := httptest.NewRecorder()
+			e.ServeHTTP(recorder, request)
+
+			require.Equal(t, tc.expectedStatusCode, recorder.Code)
+		})
+	}
+}
+
+func TestGetSettings(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	appConfig := common.AppConfig{
+		AdminUsername: "admin",
+		AdminPassword: "P@ssw0rd",
+	}
+	adminService := NewMockAdminService(ctrl)
+	adminController := NewAdminController(adminService, appConfig)
+
+	adminService.EXPECT().FindSettings(gomock.Any(), 2566).Times(1).Return([]SettingValue{
+		{Setting: tax.SettingPersonalDeduction, TaxYear: 2566, Value: tax.NewConfigValue(70000), Source: tax.ConfigSourceDatabase},
+		{Setting: tax.SettingKReceiptDeduction, TaxYear: 2566, Value: tax.NewConfigValue(50000), Source: tax.ConfigSourceDefault},
+		{Setting: tax.SettingDonationIncomeRate, TaxYear: 2566, Value: tax.NewConfigValue(10), Source: tax.ConfigSourceDefault},
+	}, nil)
+
+	e := common.NewConfiguredEcho()
+
+	adminController.RouteConfig(e)
+
+	request, err := http.NewRequest(http.MethodGet, "/admin/settings?taxYear=2566", nil)
+	require.NoError(t, err)
+
+	request.SetBasicAuth("admin", "P@ssw0rd")
+
+	recorder := httptest.NewRecorder()
+	e.ServeHTTP(recorder, request)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	expected := `
+		{
+			"taxYear": 2566,
+			"settings": [
+				{"key": "personal_deduction", "taxYear": 2566, "value": 70000.0, "source": "database", "unit": "THB", "description": "Personal deduction of every taxpayer", "min": 10000.0, "max": 100000.0},
+				{"key": "kreceipt_deduction", "taxYear": 2566, "value": 50000.0, "source": "default", "unit": "THB", "description": "Maximum deduction of k-receipt allowances", "min": 0.0, "max": 100000.0},
+				{"key": "donation_income_rate", "taxYear": 2566, "value": 10, "source": "default", "unit": "percent", "description": "Maximum deduction of donation allowances as a percentage of income after every other deduction", "min": 0, "max": 100}
+			]
+		}
+	`
+	require.JSONEq(t, expected, recorder.Body.String())
+}
+
+func TestGetSetting(t *testing.T) {
+	testCases := []struct {
+		name               string
+		key                string
+		adminServiceStub   func(adminService *MockAdminService)
+		expectedStatusCode int
+	}{
+		{
+			name: "Should response with 200 status code, given known key",
+			key:  "kreceipt_deduction",
+			adminServiceStub: func(adminService *MockAdminService) {
+				adminService.EXPECT().FindSetting(gomock.Any(), tax.DefaultTaxYear, "kreceipt_deduction").Times(1).Return(SettingValue{Setting: tax.SettingKReceiptDeduction, TaxYear: tax.DefaultTaxYear, Value: tax.NewConfigValue(50000), Source: tax.ConfigSourceDatabase}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name: "Should response with 404 status code, given unknown key",
+			key:  "unknown",
+			adminServiceStub: func(adminService *MockAdminService) {
+				adminService.EXPECT().FindSetting(gomock.Any(), tax.DefaultTaxYear, "unknown").Times(1).Return(SettingValue{}, ErrUnknownSetting)
+			},
+			expectedStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			appConfig := common.AppConfig{
+				AdminUsername: "admin",
+				AdminPassword: "P@ssw0rd",
+			}
+			adminService := NewMockAdminService(ctrl)
+			adminController := NewAdminController(adminService, appConfig)
+
+			tc.adminServiceStub(adminService)
+
+			e := common.NewConfiguredEcho()
+
+			adminController.RouteConfig(e)
+
+			request, err := http.NewRequest(http.MethodGet, "/admin/settings/"+tc.key, nil)
+			require.NoError(t, err)
+
+			request.SetBasicAuth("admin", "P@ssw0rd")
+
+			recorder := httptest.NewRecorder()
+			e.ServeHTTP(recorder, request)
+
+			require.Equal(t, tc.expectedStatusCode, recorder.Code)
+		})
+	}
+}
+
+func TestPutSetting(t *testing.T) {
+	testCases := []struct {
+		name               string
+		method             string
+		url                string
+		body               string
+		adminServiceStub   func(adminService *MockAdminService)
+		expectedStatusCode int
+		checkResponse      func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "Should response with 200 status code, given PUT with valid value",
+			method: http.MethodPut,
+			url:    "/admin/settings/donation_deduction?taxYear=2566",
+			body:   `{"value": 200000.0}`,
+			adminServiceStub: func(adminService *MockAdminService) {
+				adminService.EXPECT().UpdateSetting(gomock.Any(), gomock.Any(), 2566, "donation_deduction", tax.NewConfigValue(200000), nil).Times(1).Return(SettingValue{Setting: tax.SettingDonationDeduction, TaxYear: 2566, Value: tax.NewConfigValue(200000), Source: tax.ConfigSourceDatabase}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				var response settingResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &response)
+				require.NoError(t, err)
+
+				require.Equal(t, "donation_deduction", response.Key)
+				require.Equal(t, 2566, response.TaxYear)
+				require.Equal(t, tax.NewConfigValue(200000), response.Value)
+			},
+		},
+		{
+			name:   "Should response with 200 status code, given PATCH with zero value",
+			method: http.MethodPatch,
+			url:    "/admin/settings/kreceipt_deduction",
+			body:   `{"value": 0}`,
+			adminServiceStub: func(adminService *MockAdminService) {
+				adminService.EXPECT().UpdateSetting(gomock.Any(), gomock.Any(), tax.DefaultTaxYear, "kreceipt_deduction", tax.ConfigValue(0), nil).Times(1).Return(SettingValue{Setting: tax.SettingKReceiptDeduction, TaxYear: tax.DefaultTaxYear, Value: 0, Source: tax.ConfigSourceDatabase}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			checkResponse:      func(t *testing.T, recorder *httptest.ResponseRecorder) {},
+		},
+		{
+			name:   "Should response with 400 status code, given missing value",
+			method: http.MethodPut,
+			url:    "/admin/settings/kreceipt_deduction",
+			body:   `{}`,
+			adminServiceStub: func(adminService *MockAdminService) {
+				adminService.EXPECT().UpdateSetting(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			checkResponse:      func(t *testing.T, recorder *httptest.ResponseRecorder) {},
+		},
+		{
+			name:   "Should response with 400 status code, given value out of range",
+			method: http.MethodPut,
+			url:    "/admin/settings/personal_deduction",
+			body:   `{"value": 100000.01}`,
+			adminServiceStub: func(adminService *MockAdminService) {
+				adminService.EXPECT().UpdateSetting(gomock.Any(), gomock.Any(), tax.DefaultTaxYear, "personal_deduction", tax.ConfigValue(10000001), nil).Times(1).Return(SettingValue{}, &SettingRangeError{Setting: tax.SettingPersonalDeduction, Value: tax.ConfigValue(10000001)})
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				var response common.ErrorResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &response)
+				require.NoError(t, err)
+
+				require.Equal(t, common.ErrorCodeValidationFailed, response.Code)
+				require.Equal(t, []common.Violation{{Field: "value", Rule: "lte", Param: tax.SettingPersonalDeduction.Max.String()}}, response.Violations)
+			},
+		},
+		{
+			name:   "Should response with 200 status code, given expected value",
+			method: http.MethodPut,
+			url:    "/admin/settings/personal_deduction",
+			body:   `{"value": 70000.0, "expectedValue": 60000.0}`,
+			adminServiceStub: func(adminService *MockAdminService) {
+				expectedValue := tax.NewConfigValue(60000)
+				adminService.EXPECT().UpdateSetting(gomock.Any(), gomock.Any(), tax.DefaultTaxYear, "personal_deduction", tax.NewConfigValue(70000), &expectedValue).Times(1).Return(SettingValue{Setting: tax.SettingPersonalDeduction, TaxYear: tax.DefaultTaxYear, Value: tax.NewConfigValue(70000), Source: tax.ConfigSourceDatabase}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			checkResponse:      func(t *testing.T, recorder *httptest.ResponseRecorder) {},
+		},
+		{
+			name:   "Should response with 409 status code, given expected value that is no longer the value",
+			method: http.MethodPut,
+			url:    "/admin/settings/personal_deduction",
+			body:   `{"value": 70000.0, "expectedValue": 60000.0}`,
+			adminServiceStub: func(adminService *MockAdminService) {
+				expectedValue := tax.NewConfigValue(60000)
+				adminService.EXPECT().UpdateSetting(gomock.Any(), gomock.Any(), tax.DefaultTaxYear, "personal_deduction", tax.NewConfigValue(70000), &expectedValue).Times(1).Return(SettingValue{}, fmt.Errorf("%w: personal_deduction of tax year 2567 is 65000, not 60000", ErrConfigConflict))
+			},
+			expectedStatusCode: http.StatusConflict,
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				var response common.ErrorResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &response)
+				require.NoError(t, err)
+
+				require.Equal(t, ErrorCodeConfigConflict, response.Code)
+			},
+		},
+		{
+			name:   "Should response with 404 status code, given unknown key",
+			method: http.MethodPut,
+			url:    "/admin/settings/unknown",
+			body:   `{"value": 1000.0}`,
+			adminServiceStub: func(adminService *MockAdminService) {
+				adminService.EXPECT().UpdateSetting(gomock.Any(), gomock.Any(), tax.DefaultTaxYear, "unknown", tax.NewConfigValue(1000), nil).Times(1).Return(SettingValue{}, ErrUnknownSetting)
+			},
+			expectedStatusCode: http.StatusNotFound,
+			checkResponse:      func(t *testing.T, recorder *httptest.ResponseRecorder) {},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			appConfig := common.AppConfig{
+				AdminUsername: "admin",
+				AdminPassword: "P@ssw0rd",
+			}
+			adminService := NewMockAdminService(ctrl)
+			adminController := NewAdminController(adminService, appConfig)
+
+			tc.adminServiceStub(adminService)
+
+			e := common.NewConfiguredEcho()
+
+			adminController.RouteConfig(e)
+
+			request, err := http.NewRequest(tc.method, tc.url, bytes.NewReader([]byte(tc.body)))
+			require.NoError(t, err)
+
+			request.SetBasicAuth("admin", "P@ssw0rd")
+			request.Header.Set("Content-Type", "application/json")
+
+			recorder := httptest.NewRecorder()
+			e.ServeHTTP(recorder, request)
+
+			require.Equal(t, tc.expectedStatusCode, recorder.Code)
+
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestPutSettingWithAuditActor(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	appConfig := common.AppConfig{
+		AdminUsername: "admin",
+		AdminPassword: "P@ssw0rd",
+	}
+	adminService := NewMockAdminService(ctrl)
+	adminController := NewAdminController(adminService, appConfig)
+
+	actor := AuditActor{Username: "admin", ClientIP: "192.0.2.1", RequestID: "request-1"}
+	adminService.EXPECT().UpdateSetting(gomock.Any(), actor, tax.DefaultTaxYear, "personal_deduction", tax.NewConfigValue(70000), nil).Times(1).
+		Return(SettingValue{Setting: tax.SettingPersonalDeduction, TaxYear: tax.DefaultTaxYear, Value: tax.NewConfigValue(70000), Source: tax.ConfigSourceDatabase}, nil)
+
+	e := common.NewConfiguredEcho()
+
+	adminController.RouteConfig(e)
+
+	request := httptest.NewRequest(http.MethodPut, "/admin/settings/personal_deduction", bytes.NewReader([]byte(`{"value": 70000.0}`)))
+	request.SetBasicAuth("admin", "P@ssw0rd")
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(echo.HeaderXRequestID, "request-1")
+
+	recorder := httptest.NewRecorder()
+	e.ServeHTTP(recorder, request)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, "request-1", recorder.Header().Get(echo.HeaderXRequestID))
+}
+
+func TestGetAuditLog(t *testing.T) {
+	testCases := []struct {
+		name               string
+		query              string
+		adminServiceStub   func(adminService *MockAdminService)
+		expectedStatusCode int
+	}{
+		{
+			name:  "Should response with 200 status code, given key and dates",
+			query: "?key=personal_deduction&from=2024-05-01&to=2024-05-31&limit=10&offset=20",
+			adminServiceStub: func(adminService *MockAdminService) {
+				adminService.EXPECT().FindAuditLog(gomock.Any(), AuditFilter{
+					Key:    "personal_deduction",
+					From:   time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+					To:     time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+					Limit:  10,
+					Offset: 20,
+				}).Times(1).Return(AuditPage{Entries: []AuditEntry{}, Limit: 10, Offset: 20}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:  "Should response with 200 status code, given RFC 3339 times",
+			query: "?from=2024-05-01T08:00:00%2B07:00&to=2024-05-01T09:00:00%2B07:00",
+			adminServiceStub: func(adminService *MockAdminService) {
+				adminService.EXPECT().FindAuditLog(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(
+					func(_ context.Context, filter AuditFilter) (AuditPage, error) {
+						require.True(t, filter.From.Equal(time.Date(2024, 5, 1, 1, 0, 0, 0, time.UTC)))
+						require.True(t, filter.To.Equal(time.Date(2024, 5, 1, 2, 0, 0, 0, time.UTC)))
+						return AuditPage{Entries: []AuditEntry{}}, nil
+					},
+				)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:  "Should response with 400 status code, given invalid date",
+			query: "?from=yesterday",
+			adminServiceStub: func(adminService *MockAdminService) {
+				adminService.EXPECT().FindAuditLog(gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:  "Should response with 400 status code, given end before start",
+			query: "?from=2024-05-31&to=2024-05-01",
+			adminServiceStub: func(adminService *MockAdminService) {
+				adminService.EXPECT().FindAuditLog(gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			appConfig := common.AppConfig{
+				AdminUsername: "admin",
+				AdminPassword: "P@ssw0rd",
+			}
+			adminService := NewMockAdminService(ctrl)
+			adminController := NewAdminController(adminService, appConfig)
+
+			tc.adminServiceStub(adminService)
+
+			e := common.NewConfiguredEcho()
+
+			adminController.RouteConfig(e)
+
+			request, err := http.NewRequest(http.MethodGet, "/admin/audit-log"+tc.query, nil)
+			require.NoError(t, err)
+
+			request.SetBasicAuth("admin", "P@ssw0rd")
+
+			recorder := httptest.NewRecorder()
+			e.ServeHTTP(recorder, request)
+
+			require.Equal(t, tc.expectedStatusCode, recorder.Code)
+		})
+	}
+}
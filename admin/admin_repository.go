@@ -2,55 +2,316 @@ package admin
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
 
+	"github.com/chuckboliver/assessment-tax/tax"
 	"github.com/jmoiron/sqlx"
 )
 
+type database interface {
+	sqlx.ExtContext
+	BeginTxx(ctx context.Context, opts *sql.TxOptions) (*sqlx.Tx, error)
+}
+
 type adminRepository struct {
-	db sqlx.ExtContext
+	db database
 }
 
 var _ AdminRepository = (*adminRepository)(nil)
 
-func NewAdminRepository(db sqlx.ExtContext) AdminRepository {
+func NewAdminRepository(db database) AdminRepository {
 	return &adminRepository{
 		db: db,
 	}
 }
 
-func (r *adminRepository) UpdatePersonalDeduction(ctx context.Context, personalDeduction float64) (float64, error) {
+func (r *adminRepository) FindSettings(ctx context.Context, taxYear int) (map[string]tax.ConfigValue, error) {
 	sql := `
-		UPDATE tax_config
+		SELECT name, value
+		FROM tax_config
+		WHERE tax_year = $1
+	`
+
+	var rows []struct {
+		Name  string          `db:"name"`
+		Value tax.ConfigValue `db:"value"`
+	}
+	if err := sqlx.SelectContext(ctx, r.db, &rows, sql, taxYear); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]tax.ConfigValue, len(rows))
+	for _, v := range rows {
+		values[v.Name] = v.Value
+	}
+
+	return values, nil
+}
+
+func (r *adminRepository) UpdateSetting(ctx context.Context, actor AuditActor, taxYear int, setting tax.Setting, value tax.ConfigValue, expectedValue *tax.ConfigValue) (tax.ConfigValue, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if err := lockConfigVersions(ctx, tx); err != nil {
+		return 0, err
+	}
+
+	// The lock makes the value read here the one that is changed.
+	var oldValue any
+	selectSQL := `
+		SELECT value
+		FROM tax_config
+		WHERE tax_year = $1 AND name = $2
+	`
+	var storedValue tax.ConfigValue
+	currentValue := setting.Default
+	err = tx.QueryRowxContext(ctx, selectSQL, taxYear, setting.Key).Scan(&storedValue)
+	switch {
+	case err == nil:
+		oldValue = storedValue
+		currentValue = storedValue
+	case !errors.Is(err, sql.ErrNoRows):
+		return 0, err
+	}
+
+	if expectedValue != nil && *expectedValue != currentValue {
+		return 0, fmt.Errorf("%w: %s of tax year %d is %s, not %s", ErrConfigConflict, setting.Key, taxYear, currentValue, *expectedValue)
+	}
+
+	// A key missing from the tax year, e.g. one that was never seeded, is
+	// created, as long as the tax year has brackets.
+	upsertSQL := `
+		INSERT INTO tax_config (tax_year, name, value)
+		SELECT $1::int4, $2::varchar, $3::numeric
+		WHERE EXISTS (SELECT 1 FROM tax_bracket WHERE tax_year = $1)
+		ON CONFLICT (tax_year, name) DO UPDATE
 		SET
-			value = $1
-		WHERE name = 'personal_deduction'
+			value = EXCLUDED.value
 		RETURNING value
 	`
 
-	row := r.db.QueryRowxContext(ctx, sql, personalDeduction)
+	row := tx.QueryRowxContext(ctx, upsertSQL, taxYear, setting.Key, value)
 
-	var updatedPersonalDeduction float64
-	if err := row.Scan(&updatedPersonalDeduction); err != nil {
+	var updatedValue tax.ConfigValue
+	if err := row.Scan(&updatedValue); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("%w: %s of tax year %d", ErrConfigNotFound, setting.Key, taxYear)
+		}
 		return 0, err
 	}
-	return updatedPersonalDeduction, nil
+
+	if err := saveConfigVersion(ctx, tx, taxYear); err != nil {
+		return 0, err
+	}
+
+	if err := saveAuditLog(ctx, tx, actor, setting.Key, taxYear, oldValue, updatedValue); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return updatedValue, nil
+}
+
+func (r *adminRepository) FindTaxBrackets(ctx context.Context, taxYear int) ([]tax.TaxBracket, error) {
+	return findTaxBrackets(ctx, r.db, taxYear)
 }
 
-// UpdateKReceiptDeduction implements AdminRepository.
-func (r *adminRepository) UpdateKReceiptDeduction(ctx context.Context, kReceiptDeduction float64) (float64, error) {
+func findTaxBrackets(ctx context.Context, db sqlx.QueryerContext, taxYear int) ([]tax.TaxBracket, error) {
 	sql := `
-		UPDATE tax_config
-		SET
-			value = $1
-		WHERE name = 'kreceipt_deduction'
-		RETURNING value
+		SELECT lower_bound, upper_bound, rate
+		FROM tax_bracket
+		WHERE tax_year = $1
+		ORDER BY lower_bound
 	`
 
-	row := r.db.QueryRowxContext(ctx, sql, kReceiptDeduction)
+	brackets := make([]tax.TaxBracket, 0)
+	if err := sqlx.SelectContext(ctx, db, &brackets, sql, taxYear); err != nil {
+		return nil, err
+	}
 
-	var updatedKReceiptDeduction float64
-	if err := row.Scan(&updatedKReceiptDeduction); err != nil {
-		return 0, err
+	return brackets, nil
+}
+
+func (r *adminRepository) ReplaceTaxBrackets(ctx context.Context, actor AuditActor, taxYear int, brackets []tax.TaxBracket) ([]tax.TaxBracket, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := lockConfigVersions(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	var oldBrackets any
+	currentBrackets, err := findTaxBrackets(ctx, tx, taxYear)
+	if err != nil {
+		return nil, err
 	}
-	return updatedKReceiptDeduction, nil
+	if len(currentBrackets) > 0 {
+		oldBrackets = currentBrackets
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM tax_bracket WHERE tax_year = $1`, taxYear); err != nil {
+		return nil, err
+	}
+
+	insertSQL := `
+		INSERT INTO tax_bracket (tax_year, lower_bound, upper_bound, rate)
+		VALUES ($1, $2, $3, $4)
+	`
+	for _, bracket := range brackets {
+		if _, err := tx.ExecContext(ctx, insertSQL, taxYear, bracket.LowerBound, bracket.UpperBound, bracket.Rate); err != nil {
+			return nil, err
+		}
+	}
+
+	replacedBrackets, err := findTaxBrackets(ctx, tx, taxYear)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := saveConfigVersion(ctx, tx, taxYear); err != nil {
+		return nil, err
+	}
+
+	if err := saveAuditLog(ctx, tx, actor, AuditKeyBrackets, taxYear, oldBrackets, replacedBrackets); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return replacedBrackets, nil
+}
+
+// FindAuditLog returns a page of the audit log, newest first, and the number of
+// entries matching the filter.
+func (r *adminRepository) FindAuditLog(ctx context.Context, filter AuditFilter) ([]AuditEntry, int, error) {
+	from := sql.NullTime{Time: filter.From, Valid: !filter.From.IsZero()}
+	to := sql.NullTime{Time: filter.To, Valid: !filter.To.IsZero()}
+
+	where := `
+		WHERE ($1 = '' OR config_key = $1)
+			AND ($2::timestamptz IS NULL OR created_at >= $2)
+			AND ($3::timestamptz IS NULL OR created_at < $3)
+	`
+
+	var total int
+	if err := sqlx.GetContext(ctx, r.db, &total, `SELECT count(*) FROM admin_audit_log`+where, filter.Key, from, to); err != nil {
+		return nil, 0, err
+	}
+
+	selectSQL := `
+		SELECT id, config_key, tax_year, old_value, new_value, username, client_ip, request_id, created_at
+		FROM admin_audit_log
+	` + where + `
+		ORDER BY created_at DESC, id DESC
+		LIMIT $4 OFFSET $5
+	`
+
+	var rows []struct {
+		ID        int64     `db:"id"`
+		Key       string    `db:"config_key"`
+		TaxYear   int       `db:"tax_year"`
+		OldValue  []byte    `db:"old_value"`
+		NewValue  []byte    `db:"new_value"`
+		Username  string    `db:"username"`
+		ClientIP  string    `db:"client_ip"`
+		RequestID string    `db:"request_id"`
+		CreatedAt time.Time `db:"created_at"`
+	}
+	if err := sqlx.SelectContext(ctx, r.db, &rows, selectSQL, filter.Key, from, to, filter.Limit, filter.Offset); err != nil {
+		return nil, 0, err
+	}
+
+	entries := make([]AuditEntry, 0, len(rows))
+	for _, v := range rows {
+		oldValue := json.RawMessage("null")
+		if v.OldValue != nil {
+			oldValue = v.OldValue
+		}
+
+		entries = append(entries, AuditEntry{
+			ID:        v.ID,
+			Key:       v.Key,
+			TaxYear:   v.TaxYear,
+			OldValue:  oldValue,
+			NewValue:  v.NewValue,
+			Username:  v.Username,
+			ClientIP:  v.ClientIP,
+			RequestID: v.RequestID,
+			CreatedAt: v.CreatedAt,
+		})
+	}
+
+	return entries, total, nil
+}
+
+// saveAuditLog records the change of key by actor, as made by tx. A nil oldValue
+// records that key was not configured before.
+func saveAuditLog(ctx context.Context, tx *sqlx.Tx, actor AuditActor, key string, taxYear int, oldValue any, newValue any) error {
+	var oldJSON any
+	if oldValue != nil {
+		b, err := json.Marshal(oldValue)
+		if err != nil {
+			return err
+		}
+		oldJSON = b
+	}
+
+	newJSON, err := json.Marshal(newValue)
+	if err != nil {
+		return err
+	}
+
+	insertSQL := `
+		INSERT INTO admin_audit_log (config_key, tax_year, old_value, new_value, username, client_ip, request_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err = tx.ExecContext(ctx, insertSQL, key, taxYear, oldJSON, newJSON, actor.Username, actor.ClientIP, actor.RequestID)
+	return err
+}
+
+// lockConfigVersions makes changes of tax config wait for each other, so that
+// config versions are committed in the order of their numbers and a version
+// never appears below one that has already been read. Reads are not blocked.
+func lockConfigVersions(ctx context.Context, tx *sqlx.Tx) error {
+	_, err := tx.ExecContext(ctx, `LOCK TABLE tax_config_versions IN EXCLUSIVE MODE`)
+	return err
+}
+
+// saveConfigVersion records the config of taxYear, as changed by tx, as a new
+// config version.
+func saveConfigVersion(ctx context.Context, tx *sqlx.Tx, taxYear int) error {
+	insertSQL := `
+		INSERT INTO tax_config_versions (tax_year, config, brackets)
+		SELECT
+			$1,
+			COALESCE((
+				SELECT jsonb_object_agg(name, value)
+				FROM tax_config
+				WHERE tax_year = $1
+			), '{}'),
+			COALESCE((
+				SELECT jsonb_agg(jsonb_build_object('lowerBound', lower_bound, 'upperBound', upper_bound, 'rate', rate) ORDER BY lower_bound)
+				FROM tax_bracket
+				WHERE tax_year = $1
+			), '[]')
+	`
+
+	_, err := tx.ExecContext(ctx, insertSQL, taxYear)
+	return err
 }
@@ -2,32 +2,256 @@ package admin
 
 import (
 	"context"
+	"fmt"
 	"testing"
 
+	"github.com/chuckboliver/assessment-tax/common"
+	"github.com/chuckboliver/assessment-tax/tax"
 	"github.com/golang/mock/gomock"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/require"
 )
 
-func TestUpdatePersonalDeduction(t *testing.T) {
-	ctrl := gomock.NewController(t)
-	adminRepo := NewMockAdminRepository(ctrl)
-	adminService := NewAdminService(adminRepo)
+var testAuditActor = AuditActor{Username: "adminTax", ClientIP: "192.0.2.1", RequestID: "request-1"}
+
+func configValuePtr(value tax.ConfigValue) *tax.ConfigValue {
+	return &value
+}
 
-	adminRepo.EXPECT().UpdatePersonalDeduction(gomock.Any(), 20000.0).Times(1).Return(20000.0, nil)
+func TestUpdateSetting(t *testing.T) {
+	testCases := []struct {
+		name          string
+		key           string
+		value         tax.ConfigValue
+		expectedValue *tax.ConfigValue
+		repoStub      func(adminRepo *MockAdminRepository)
+		expected      SettingValue
+		expectedError error
+		isValid       bool
+	}{
+		{
+			name:  "Should update personal deduction",
+			key:   "personal_deduction",
+			value: tax.NewConfigValue(20000),
+			repoStub: func(adminRepo *MockAdminRepository) {
+				adminRepo.EXPECT().UpdateSetting(gomock.Any(), testAuditActor, 2567, tax.SettingPersonalDeduction, tax.NewConfigValue(20000), nil).Times(1).Return(tax.NewConfigValue(20000), nil)
+			},
+			expected: SettingValue{Setting: tax.SettingPersonalDeduction, TaxYear: 2567, Value: tax.NewConfigValue(20000), Source: tax.ConfigSourceDatabase},
+			isValid:  true,
+		},
+		{
+			name:  "Should update k-receipt deduction",
+			key:   "kreceipt_deduction",
+			value: tax.NewConfigValue(30000),
+			repoStub: func(adminRepo *MockAdminRepository) {
+				adminRepo.EXPECT().UpdateSetting(gomock.Any(), testAuditActor, 2567, tax.SettingKReceiptDeduction, tax.NewConfigValue(30000), nil).Times(1).Return(tax.NewConfigValue(30000), nil)
+			},
+			expected: SettingValue{Setting: tax.SettingKReceiptDeduction, TaxYear: 2567, Value: tax.NewConfigValue(30000), Source: tax.ConfigSourceDatabase},
+			isValid:  true,
+		},
+		{
+			name:          "Should update personal deduction, given expected value",
+			key:           "personal_deduction",
+			value:         tax.NewConfigValue(20000),
+			expectedValue: configValuePtr(tax.NewConfigValue(60000)),
+			repoStub: func(adminRepo *MockAdminRepository) {
+				adminRepo.EXPECT().UpdateSetting(gomock.Any(), testAuditActor, 2567, tax.SettingPersonalDeduction, tax.NewConfigValue(20000), configValuePtr(tax.NewConfigValue(60000))).Times(1).Return(tax.NewConfigValue(20000), nil)
+			},
+			expected: SettingValue{Setting: tax.SettingPersonalDeduction, TaxYear: 2567, Value: tax.NewConfigValue(20000), Source: tax.ConfigSourceDatabase},
+			isValid:  true,
+		},
+		{
+			name:          "Should not update, given expected value that is no longer the value",
+			key:           "personal_deduction",
+			value:         tax.NewConfigValue(20000),
+			expectedValue: configValuePtr(tax.NewConfigValue(60000)),
+			repoStub: func(adminRepo *MockAdminRepository) {
+				adminRepo.EXPECT().UpdateSetting(gomock.Any(), testAuditActor, 2567, tax.SettingPersonalDeduction, tax.NewConfigValue(20000), configValuePtr(tax.NewConfigValue(60000))).Times(1).Return(tax.ConfigValue(0), ErrConfigConflict)
+			},
+			expectedError: ErrConfigConflict,
+		},
+		{
+			name:  "Should not update, given unknown key",
+			key:   "unknown",
+			value: tax.NewConfigValue(20000),
+			repoStub: func(adminRepo *MockAdminRepository) {
+				adminRepo.EXPECT().UpdateSetting(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectedError: fmt.Errorf("%w: %q", ErrUnknownSetting, "unknown"),
+		},
+		{
+			name:  "Should not update, given value below minimum",
+			key:   "personal_deduction",
+			value: tax.NewConfigValue(9999),
+			repoStub: func(adminRepo *MockAdminRepository) {
+				adminRepo.EXPECT().UpdateSetting(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectedError: &SettingRangeError{Setting: tax.SettingPersonalDeduction, Value: tax.NewConfigValue(9999)},
+		},
+		{
+			name:  "Should not update, given value above maximum",
+			key:   "kreceipt_deduction",
+			value: tax.NewConfigValue(100001),
+			repoStub: func(adminRepo *MockAdminRepository) {
+				adminRepo.EXPECT().UpdateSetting(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectedError: &SettingRangeError{Setting: tax.SettingKReceiptDeduction, Value: tax.NewConfigValue(100001)},
+		},
+		{
+			name:  "Should update donation income rate",
+			key:   "donation_income_rate",
+			value: tax.NewConfigValue(15),
+			repoStub: func(adminRepo *MockAdminRepository) {
+				adminRepo.EXPECT().UpdateSetting(gomock.Any(), testAuditActor, 2567, tax.SettingDonationIncomeRate, tax.NewConfigValue(15), nil).Times(1).Return(tax.NewConfigValue(15), nil)
+			},
+			expected: SettingValue{Setting: tax.SettingDonationIncomeRate, TaxYear: 2567, Value: tax.NewConfigValue(15), Source: tax.ConfigSourceDatabase},
+			isValid:  true,
+		},
+		{
+			name:  "Should not update, given percentage above 100",
+			key:   "donation_income_rate",
+			value: tax.NewConfigValue(101),
+			repoStub: func(adminRepo *MockAdminRepository) {
+				adminRepo.EXPECT().UpdateSetting(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectedError: &SettingRangeError{Setting: tax.SettingDonationIncomeRate, Value: tax.NewConfigValue(101)},
+		},
+	}
 
-	updatePersonalDeduction, err := adminService.UpdatePersonalDeduction(context.Background(), 20000.0)
-	require.NoError(t, err)
-	require.Equal(t, 20000.0, updatePersonalDeduction)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			adminRepo := NewMockAdminRepository(ctrl)
+			adminService := NewAdminService(adminRepo)
+
+			tc.repoStub(adminRepo)
+
+			actual, err := adminService.UpdateSetting(context.Background(), testAuditActor, 2567, tc.key, tc.value, tc.expectedValue)
+			if !tc.isValid {
+				require.Error(t, err)
+				require.Equal(t, tc.expectedError.Error(), err.Error())
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, actual)
+		})
+	}
 }
 
-func TestUpdateKReceiptDeduction(t *testing.T) {
+func TestFindSettings(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	adminRepo := NewMockAdminRepository(ctrl)
 	adminService := NewAdminService(adminRepo)
 
-	adminRepo.EXPECT().UpdateKReceiptDeduction(gomock.Any(), 30000.0).Times(1).Return(30000.0, nil)
+	adminRepo.EXPECT().FindSettings(gomock.Any(), 2567).Times(1).Return(map[string]tax.ConfigValue{
+		"personal_deduction": tax.NewConfigValue(70000),
+	}, nil)
 
-	updateKReceiptDeduction, err := adminService.UpdateKReceiptDeduction(context.Background(), 30000.0)
+	settings, err := adminService.FindSettings(context.Background(), 2567)
 	require.NoError(t, err)
-	require.Equal(t, 30000.0, updateKReceiptDeduction)
+	require.Equal(t, []SettingValue{
+		{Setting: tax.SettingPersonalDeduction, TaxYear: 2567, Value: tax.NewConfigValue(70000), Source: tax.ConfigSourceDatabase},
+		{Setting: tax.SettingKReceiptDeduction, TaxYear: 2567, Value: tax.NewConfigValue(50000), Source: tax.ConfigSourceDefault},
+		{Setting: tax.SettingDonationDeduction, TaxYear: 2567, Value: tax.NewConfigValue(100000), Source: tax.ConfigSourceDefault},
+		{Setting: tax.SettingDonationIncomeRate, TaxYear: 2567, Value: tax.NewConfigValue(10), Source: tax.ConfigSourceDefault},
+	}, settings)
+}
+
+func TestFindSetting(t *testing.T) {
+	t.Run("Should find setting, given known key", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		adminRepo := NewMockAdminRepository(ctrl)
+		adminService := NewAdminService(adminRepo)
+
+		adminRepo.EXPECT().FindSettings(gomock.Any(), 2567).Times(1).Return(map[string]tax.ConfigValue{
+			"kreceipt_deduction": tax.NewConfigValue(40000),
+		}, nil)
+
+		setting, err := adminService.FindSetting(context.Background(), 2567, "kreceipt_deduction")
+		require.NoError(t, err)
+		require.Equal(t, SettingValue{Setting: tax.SettingKReceiptDeduction, TaxYear: 2567, Value: tax.NewConfigValue(40000), Source: tax.ConfigSourceDatabase}, setting)
+	})
+
+	t.Run("Should not find setting, given unknown key", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		adminRepo := NewMockAdminRepository(ctrl)
+		adminService := NewAdminService(adminRepo)
+
+		adminRepo.EXPECT().FindSettings(gomock.Any(), gomock.Any()).Times(0)
+
+		_, err := adminService.FindSetting(context.Background(), 2567, "unknown")
+		require.ErrorIs(t, err, ErrUnknownSetting)
+	})
+}
+
+func TestReplaceTaxBrackets(t *testing.T) {
+	upperBound := common.Baht(150000)
+
+	t.Run("Should replace tax brackets, given valid brackets", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		adminRepo := NewMockAdminRepository(ctrl)
+		adminService := NewAdminService(adminRepo)
+
+		brackets := []tax.TaxBracket{
+			{LowerBound: 0, UpperBound: &upperBound, Rate: decimal.RequireFromString("0")},
+			{LowerBound: common.Baht(150000), UpperBound: nil, Rate: decimal.RequireFromString("0.1")},
+		}
+
+		adminRepo.EXPECT().ReplaceTaxBrackets(gomock.Any(), testAuditActor, 2567, brackets).Times(1).Return(brackets, nil)
+
+		replacedBrackets, err := adminService.ReplaceTaxBrackets(context.Background(), testAuditActor, 2567, brackets)
+		require.NoError(t, err)
+		require.Equal(t, brackets, replacedBrackets)
+	})
+
+	t.Run("Should not replace tax brackets, given overlapping brackets", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		adminRepo := NewMockAdminRepository(ctrl)
+		adminService := NewAdminService(adminRepo)
+
+		brackets := []tax.TaxBracket{
+			{LowerBound: 0, UpperBound: &upperBound, Rate: decimal.RequireFromString("0")},
+			{LowerBound: common.Baht(100000), UpperBound: nil, Rate: decimal.RequireFromString("0.1")},
+		}
+
+		adminRepo.EXPECT().ReplaceTaxBrackets(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+		_, err := adminService.ReplaceTaxBrackets(context.Background(), testAuditActor, 2567, brackets)
+		require.ErrorIs(t, err, tax.ErrInvalidTaxBrackets)
+	})
+}
+
+func TestFindAuditLog(t *testing.T) {
+	testCases := []struct {
+		name     string
+		filter   AuditFilter
+		expected AuditFilter
+	}{
+		{
+			name:     "Should use default limit, given no limit",
+			filter:   AuditFilter{Key: "personal_deduction"},
+			expected: AuditFilter{Key: "personal_deduction", Limit: defaultAuditPageLimit},
+		},
+		{
+			name:     "Should cap limit at maximum",
+			filter:   AuditFilter{Limit: 1000, Offset: 40},
+			expected: AuditFilter{Limit: maxAuditPageLimit, Offset: 40},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			adminRepo := NewMockAdminRepository(ctrl)
+			adminService := NewAdminService(adminRepo)
+
+			entries := []AuditEntry{{ID: 1, Key: "personal_deduction", TaxYear: 2567}}
+			adminRepo.EXPECT().FindAuditLog(gomock.Any(), tc.expected).Times(1).Return(entries, 1, nil)
+
+			page, err := adminService.FindAuditLog(context.Background(), tc.filter)
+			require.NoError(t, err)
+			require.Equal(t, AuditPage{Entries: entries, Total: 1, Limit: tc.expected.Limit, Offset: tc.expected.Offset}, page)
+		})
+	}
 }
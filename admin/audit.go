@@ -0,0 +1,54 @@
+package admin
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	defaultAuditPageLimit = 20
+	maxAuditPageLimit     = 100
+)
+
+// AuditKeyBrackets is the key the changes of the tax brackets of a tax year are
+// recorded under. Changes of a setting are recorded under the key of the setting.
+const AuditKeyBrackets = "brackets"
+
+// AuditActor is who made a change of config, as told by the request.
+type AuditActor struct {
+	Username  string
+	ClientIP  string
+	RequestID string
+}
+
+// AuditEntry is a change of config recorded in the audit log. OldValue is null
+// when the key was not configured before.
+type AuditEntry struct {
+	ID        int64           `json:"id"`
+	Key       string          `json:"key"`
+	TaxYear   int             `json:"taxYear"`
+	OldValue  json.RawMessage `json:"oldValue"`
+	NewValue  json.RawMessage `json:"newValue"`
+	Username  string          `json:"username"`
+	ClientIP  string          `json:"clientIp"`
+	RequestID string          `json:"requestId"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+// AuditFilter selects a page of the audit log, newest first. An empty Key selects
+// every key, and a zero From or To leaves the date range open on that side. From
+// is inclusive and To exclusive.
+type AuditFilter struct {
+	Key    string
+	From   time.Time
+	To     time.Time
+	Limit  int
+	Offset int
+}
+
+type AuditPage struct {
+	Entries []AuditEntry `json:"entries"`
+	Total   int          `json:"total"`
+	Limit   int          `json:"limit"`
+	Offset  int          `json:"offset"`
+}
@@ -8,6 +8,7 @@ import (
 	context "context"
 	reflect "reflect"
 
+	tax "github.com/chuckboliver/assessment-tax/tax"
 	gomock "github.com/golang/mock/gomock"
 )
 
@@ -34,34 +35,80 @@ func (m *MockAdminRepository) EXPECT() *MockAdminRepositoryMockRecorder {
 	return m.recorder
 }
 
-// UpdateKReceiptDeduction mocks base method.
-func (m *MockAdminRepository) UpdateKReceiptDeduction(ctx context.Context, kReceiptDeduction float64) (float64, error) {
+// FindAuditLog mocks base method.
+func (m *MockAdminRepository) FindAuditLog(ctx context.Context, filter AuditFilter) ([]AuditEntry, int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateKReceiptDeduction", ctx, kReceiptDeduction)
-	ret0, _ := ret[0].(float64)
+	ret := m.ctrl.Call(m, "FindAuditLog", ctx, filter)
+	ret0, _ := ret[0].([]AuditEntry)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// FindAuditLog indicates an expected call of FindAuditLog.
+func (mr *MockAdminRepositoryMockRecorder) FindAuditLog(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAuditLog", reflect.TypeOf((*MockAdminRepository)(nil).FindAuditLog), ctx, filter)
+}
+
+// FindSettings mocks base method.
+func (m *MockAdminRepository) FindSettings(ctx context.Context, taxYear int) (map[string]tax.ConfigValue, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindSettings", ctx, taxYear)
+	ret0, _ := ret[0].(map[string]tax.ConfigValue)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindSettings indicates an expected call of FindSettings.
+func (mr *MockAdminRepositoryMockRecorder) FindSettings(ctx, taxYear interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindSettings", reflect.TypeOf((*MockAdminRepository)(nil).FindSettings), ctx, taxYear)
+}
+
+// FindTaxBrackets mocks base method.
+func (m *MockAdminRepository) FindTaxBrackets(ctx context.Context, taxYear int) ([]tax.TaxBracket, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindTaxBrackets", ctx, taxYear)
+	ret0, _ := ret[0].([]tax.TaxBracket)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// UpdateKReceiptDeduction indicates an expected call of UpdateKReceiptDeduction.
-func (mr *MockAdminRepositoryMockRecorder) UpdateKReceiptDeduction(ctx, kReceiptDeduction interface{}) *gomock.Call {
+// FindTaxBrackets indicates an expected call of FindTaxBrackets.
+func (mr *MockAdminRepositoryMockRecorder) FindTaxBrackets(ctx, taxYear interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateKReceiptDeduction", reflect.TypeOf((*MockAdminRepository)(nil).UpdateKReceiptDeduction), ctx, kReceiptDeduction)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindTaxBrackets", reflect.TypeOf((*MockAdminRepository)(nil).FindTaxBrackets), ctx, taxYear)
 }
 
-// UpdatePersonalDeduction mocks base method.
-func (m *MockAdminRepository) UpdatePersonalDeduction(ctx context.Context, personalDeduction float64) (float64, error) {
+// ReplaceTaxBrackets mocks base method.
+func (m *MockAdminRepository) ReplaceTaxBrackets(ctx context.Context, actor AuditActor, taxYear int, brackets []tax.TaxBracket) ([]tax.TaxBracket, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdatePersonalDeduction", ctx, personalDeduction)
-	ret0, _ := ret[0].(float64)
+	ret := m.ctrl.Call(m, "ReplaceTaxBrackets", ctx, actor, taxYear, brackets)
+	ret0, _ := ret[0].([]tax.TaxBracket)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// UpdatePersonalDeduction indicates an expected call of UpdatePersonalDeduction.
-func (mr *MockAdminRepositoryMockRecorder) UpdatePersonalDeduction(ctx, personalDeduction interface{}) *gomock.Call {
+// ReplaceTaxBrackets indicates an expected call of ReplaceTaxBrackets.
+func (mr *MockAdminRepositoryMockRecorder) ReplaceTaxBrackets(ctx, actor, taxYear, brackets interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePersonalDeduction", reflect.TypeOf((*MockAdminRepository)(nil).UpdatePersonalDeduction), ctx, personalDeduction)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplaceTaxBrackets", reflect.TypeOf((*MockAdminRepository)(nil).ReplaceTaxBrackets), ctx, actor, taxYear, brackets)
+}
+
+// UpdateSetting mocks base method.
+func (m *MockAdminRepository) UpdateSetting(ctx context.Context, actor AuditActor, taxYear int, setting tax.Setting, value tax.ConfigValue, expectedValue *tax.ConfigValue) (tax.ConfigValue, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateSetting", ctx, actor, taxYear, setting, value, expectedValue)
+	ret0, _ := ret[0].(tax.ConfigValue)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateSetting indicates an expected call of UpdateSetting.
+func (mr *MockAdminRepositoryMockRecorder) UpdateSetting(ctx, actor, taxYear, setting, value, expectedValue interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSetting", reflect.TypeOf((*MockAdminRepository)(nil).UpdateSetting), ctx, actor, taxYear, setting, value, expectedValue)
 }
 
 // MockAdminService is a mock of AdminService interface.
@@ -87,32 +134,92 @@ func (m *MockAdminService) EXPECT() *MockAdminServiceMockRecorder {
 	return m.recorder
 }
 
-// UpdateKReceiptDeduction mocks base method.
-func (m *MockAdminService) UpdateKReceiptDeduction(ctx context.Context, kReceiptDeduction float64) (float64, error) {
+// FindAuditLog mocks base method.
+func (m *MockAdminService) FindAuditLog(ctx context.Context, filter AuditFilter) (AuditPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindAuditLog", ctx, filter)
+	ret0, _ := ret[0].(AuditPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindAuditLog indicates an expected call of FindAuditLog.
+func (mr *MockAdminServiceMockRecorder) FindAuditLog(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAuditLog", reflect.TypeOf((*MockAdminService)(nil).FindAuditLog), ctx, filter)
+}
+
+// FindSetting mocks base method.
+func (m *MockAdminService) FindSetting(ctx context.Context, taxYear int, key string) (SettingValue, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindSetting", ctx, taxYear, key)
+	ret0, _ := ret[0].(SettingValue)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindSetting indicates an expected call of FindSetting.
+func (mr *MockAdminServiceMockRecorder) FindSetting(ctx, taxYear, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindSetting", reflect.TypeOf((*MockAdminService)(nil).FindSetting), ctx, taxYear, key)
+}
+
+// FindSettings mocks base method.
+func (m *MockAdminService) FindSettings(ctx context.Context, taxYear int) ([]SettingValue, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindSettings", ctx, taxYear)
+	ret0, _ := ret[0].([]SettingValue)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindSettings indicates an expected call of FindSettings.
+func (mr *MockAdminServiceMockRecorder) FindSettings(ctx, taxYear interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindSettings", reflect.TypeOf((*MockAdminService)(nil).FindSettings), ctx, taxYear)
+}
+
+// FindTaxBrackets mocks base method.
+func (m *MockAdminService) FindTaxBrackets(ctx context.Context, taxYear int) ([]tax.TaxBracket, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindTaxBrackets", ctx, taxYear)
+	ret0, _ := ret[0].([]tax.TaxBracket)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindTaxBrackets indicates an expected call of FindTaxBrackets.
+func (mr *MockAdminServiceMockRecorder) FindTaxBrackets(ctx, taxYear interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindTaxBrackets", reflect.TypeOf((*MockAdminService)(nil).FindTaxBrackets), ctx, taxYear)
+}
+
+// ReplaceTaxBrackets mocks base method.
+func (m *MockAdminService) ReplaceTaxBrackets(ctx context.Context, actor AuditActor, taxYear int, brackets []tax.TaxBracket) ([]tax.TaxBracket, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateKReceiptDeduction", ctx, kReceiptDeduction)
-	ret0, _ := ret[0].(float64)
+	ret := m.ctrl.Call(m, "ReplaceTaxBrackets", ctx, actor, taxYear, brackets)
+	ret0, _ := ret[0].([]tax.TaxBracket)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// UpdateKReceiptDeduction indicates an expected call of UpdateKReceiptDeduction.
-func (mr *MockAdminServiceMockRecorder) UpdateKReceiptDeduction(ctx, kReceiptDeduction interface{}) *gomock.Call {
+// ReplaceTaxBrackets indicates an expected call of ReplaceTaxBrackets.
+func (mr *MockAdminServiceMockRecorder) ReplaceTaxBrackets(ctx, actor, taxYear, brackets interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateKReceiptDeduction", reflect.TypeOf((*MockAdminService)(nil).UpdateKReceiptDeduction), ctx, kReceiptDeduction)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplaceTaxBrackets", reflect.TypeOf((*MockAdminService)(nil).ReplaceTaxBrackets), ctx, actor, taxYear, brackets)
 }
 
-// UpdatePersonalDeduction mocks base method.
-func (m *MockAdminService) UpdatePersonalDeduction(ctx context.Context, personalDeduction float64) (float64, error) {
+// UpdateSetting mocks base method.
+func (m *MockAdminService) UpdateSetting(ctx context.Context, actor AuditActor, taxYear int, key string, value tax.ConfigValue, expectedValue *tax.ConfigValue) (SettingValue, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdatePersonalDeduction", ctx, personalDeduction)
-	ret0, _ := ret[0].(float64)
+	ret := m.ctrl.Call(m, "UpdateSetting", ctx, actor, taxYear, key, value, expectedValue)
+	ret0, _ := ret[0].(SettingValue)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// UpdatePersonalDeduction indicates an expected call of UpdatePersonalDeduction.
-func (mr *MockAdminServiceMockRecorder) UpdatePersonalDeduction(ctx, personalDeduction interface{}) *gomock.Call {
+// UpdateSetting indicates an expected call of UpdateSetting.
+func (mr *MockAdminServiceMockRecorder) UpdateSetting(ctx, actor, taxYear, key, value, expectedValue interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePersonalDeduction", reflect.TypeOf((*MockAdminService)(nil).UpdatePersonalDeduction), ctx, personalDeduction)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSetting", reflect.TypeOf((*MockAdminService)(nil).UpdateSetting), ctx, actor, taxYear, key, value, expectedValue)
 }
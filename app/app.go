@@ -1,10 +1,12 @@
 package app
 
 import (
+	"context"
 	"log/slog"
 
 	"github.com/chuckboliver/assessment-tax/admin"
 	"github.com/chuckboliver/assessment-tax/common"
+	"github.com/chuckboliver/assessment-tax/migrate"
 	"github.com/chuckboliver/assessment-tax/postgres"
 	"github.com/chuckboliver/assessment-tax/tax"
 	"github.com/labstack/echo/v4"
@@ -13,21 +15,39 @@ import (
 func New(config common.AppConfig) (*echo.Echo, error) {
 	db, err := postgres.New(config.DatabaseURL)
 	if err != nil {
-		slog.Error("Failed to connect to postgres", err)
+		slog.Error("Failed to connect to postgres", "err", err)
+		return nil, err
+	}
+
+	migrator, err := migrate.New(db)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := migrator.Up(context.Background()); err != nil {
+		slog.Error("Failed to migrate database", "err", err)
 		return nil, err
 	}
 
 	taxConfigRepo := tax.NewTaxConfigPostgresRepository(db)
-	taxCalculator := tax.NewCalculator(taxConfigRepo)
-	taxController := tax.NewTaxController(taxCalculator)
+	configFallback := tax.ConfigFallback(config.ConfigFallback)
+	taxCalculator := tax.NewCalculator(taxConfigRepo, configFallback)
+	calculationHistoryRepo := tax.NewCalculationHistoryPostgresRepository(db)
+	calculationHistoryService := tax.NewCalculationHistoryService(calculationHistoryRepo, tax.HistoryFailure(config.HistoryFailure))
+	taxController := tax.NewTaxController(taxCalculator, calculationHistoryService, config)
+
+	e := common.NewConfiguredEcho()
+
+	calculationJobRepo := tax.NewCalculationJobPostgresRepository(db)
+	calculationJobService := tax.NewCalculationJobService(calculationJobRepo, taxCalculator, calculationHistoryService, e.Validator, config.MaxUploadRows, config.MaxUploadBytes())
+	jobController := tax.NewJobController(calculationJobService, config)
+	tax.NewJobWorkerPool(calculationJobService, config.JobWorkers).Start(context.Background())
 
 	adminRepo := admin.NewAdminRepository(db)
 	adminService := admin.NewAdminService(adminRepo)
 	adminController := admin.NewAdminController(adminService, config)
 
-	e := common.NewConfiguredEcho()
-
-	configureController(e, &taxController, &adminController)
+	configureController(e, &taxController, &jobController, &adminController)
 
 	return e, nil
 }
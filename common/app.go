@@ -1,16 +1,57 @@
 package common
 
 import (
-	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
 )
 
+// maxRequestIDLength is the longest X-Request-Id accepted from a client, as
+// stored in the admin audit log.
+const maxRequestIDLength = 128
+
 type Controller interface {
 	RouteConfig(e *echo.Echo)
 }
 
 func NewConfiguredEcho() *echo.Echo {
 	e := echo.New()
-	e.Validator = &EchoValidator{Validator: validator.New()}
+	e.Validator = &EchoValidator{Validator: NewValidator()}
+	e.HTTPErrorHandler = HTTPErrorHandler
+	// The client IP is the address of the connection, as headers such as
+	// X-Forwarded-For are sent by the client at will. A server behind a proxy has
+	// to trust the headers of the proxy instead.
+	e.IPExtractor = echo.ExtractIPDirect()
+	// Every response carries the X-Request-Id of its request, generated when the
+	// client sends none or an invalid one, so that it can be found in the admin
+	// audit log.
+	e.Use(dropInvalidRequestID, middleware.RequestID())
 	return e
 }
+
+// dropInvalidRequestID removes an X-Request-Id that is too long or has other
+// than visible ASCII characters from the request, for one to be generated in its
+// place.
+func dropInvalidRequestID(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		header := c.Request().Header
+		if requestID := header.Get(echo.HeaderXRequestID); requestID != "" && !isValidRequestID(requestID) {
+			header.Del(echo.HeaderXRequestID)
+		}
+
+		return next(c)
+	}
+}
+
+func isValidRequestID(requestID string) bool {
+	if len(requestID) > maxRequestIDLength {
+		return false
+	}
+
+	for i := 0; i < len(requestID); i++ {
+		if requestID[i] < '!' || requestID[i] > '~' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,83 @@
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewConfiguredEchoRequestID(t *testing.T) {
+	testCases := []struct {
+		name       string
+		requestID  string
+		isReplaced bool
+	}{
+		{
+			name:       "Should keep request id, given valid request id",
+			requestID:  "7b5c2a1e-request-1",
+			isReplaced: false,
+		},
+		{
+			name:       "Should generate request id, given no request id",
+			requestID:  "",
+			isReplaced: true,
+		},
+		{
+			name:       "Should generate request id, given request id over maximum length",
+			requestID:  strings.Repeat("a", maxRequestIDLength+1),
+			isReplaced: true,
+		},
+		{
+			name:       "Should generate request id, given request id with spaces",
+			requestID:  "request 1",
+			isReplaced: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := NewConfiguredEcho()
+			e.GET("/", func(c echo.Context) error {
+				return c.NoContent(http.StatusNoContent)
+			})
+
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.requestID != "" {
+				request.Header.Set(echo.HeaderXRequestID, tc.requestID)
+			}
+
+			recorder := httptest.NewRecorder()
+			e.ServeHTTP(recorder, request)
+
+			requestID := recorder.Header().Get(echo.HeaderXRequestID)
+			require.NotEmpty(t, requestID)
+			require.LessOrEqual(t, len(requestID), maxRequestIDLength)
+			if !tc.isReplaced {
+				require.Equal(t, tc.requestID, requestID)
+			} else {
+				require.NotEqual(t, tc.requestID, requestID)
+			}
+		})
+	}
+}
+
+func TestNewConfiguredEchoRealIP(t *testing.T) {
+	e := NewConfiguredEcho()
+	e.GET("/", func(c echo.Context) error {
+		return c.String(http.StatusOK, c.RealIP())
+	})
+
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.RemoteAddr = "192.0.2.1:1234"
+	request.Header.Set(echo.HeaderXForwardedFor, "203.0.113.7")
+	request.Header.Set(echo.HeaderXRealIP, "203.0.113.8")
+
+	recorder := httptest.NewRecorder()
+	e.ServeHTTP(recorder, request)
+
+	require.Equal(t, "192.0.2.1", recorder.Body.String())
+}
@@ -0,0 +1,27 @@
+package common
+
+import (
+	"crypto/subtle"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// AdminUsernameKey is the key of the echo context holding the username of an
+// authenticated admin.
+const AdminUsernameKey = "adminUsername"
+
+// AdminBasicAuth returns a middleware admitting only requests authenticated with
+// the admin credentials of appConfig.
+func AdminBasicAuth(appConfig AppConfig) echo.MiddlewareFunc {
+	return middleware.BasicAuth(func(username, password string, c echo.Context) (bool, error) {
+		validUsername := subtle.ConstantTimeCompare([]byte(username), []byte(appConfig.AdminUsername)) == 1
+		validPassword := subtle.ConstantTimeCompare([]byte(password), []byte(appConfig.AdminPassword)) == 1
+		if !validUsername || !validPassword {
+			return false, nil
+		}
+
+		c.Set(AdminUsernameKey, username)
+		return true, nil
+	})
+}
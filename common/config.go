@@ -1,8 +1,38 @@
 package common
 
+import "github.com/labstack/gommon/bytes"
+
 type AppConfig struct {
 	Port          string
 	DatabaseURL   string
 	AdminUsername string
 	AdminPassword string
+	// MaxUploadSize is the largest request body accepted by the upload endpoints,
+	// e.g. "100M". An empty value puts no limit.
+	MaxUploadSize string
+	// MaxUploadRows is the most rows read from an uploaded file. Zero puts no limit.
+	MaxUploadRows int
+	// JobWorkers is the number of calculation jobs run at the same time.
+	JobWorkers int
+	// ConfigFallback is "strict" to fail calculations when the tax config cannot
+	// be read, or "lenient" to calculate with the built-in defaults instead.
+	ConfigFallback string
+	// HistoryFailure is "fail" to fail calculations when they cannot be recorded,
+	// or "log" to only log the error and serve them anyway.
+	HistoryFailure string
+}
+
+// MaxUploadBytes returns MaxUploadSize in bytes, or zero when it puts no limit.
+func (c AppConfig) MaxUploadBytes() int64 {
+	if c.MaxUploadSize == "" {
+		return 0
+	}
+
+	// An invalid size is rejected by the body limit of the upload endpoints.
+	size, err := bytes.Parse(c.MaxUploadSize)
+	if err != nil {
+		return 0
+	}
+
+	return size
 }
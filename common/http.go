@@ -1,5 +1,265 @@
 package common
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+// ErrorCode identifies the kind of an error so that clients do not have to parse
+// messages.
+type ErrorCode string
+
+const (
+	ErrorCodeMalformedRequest     ErrorCode = "MALFORMED_REQUEST"
+	ErrorCodeValidationFailed     ErrorCode = "VALIDATION_FAILED"
+	ErrorCodeUnauthorized         ErrorCode = "UNAUTHORIZED"
+	ErrorCodeForbidden            ErrorCode = "FORBIDDEN"
+	ErrorCodeNotFound             ErrorCode = "NOT_FOUND"
+	ErrorCodeMethodNotAllowed     ErrorCode = "METHOD_NOT_ALLOWED"
+	ErrorCodeRequestTooLarge      ErrorCode = "REQUEST_TOO_LARGE"
+	ErrorCodeUnsupportedMediaType ErrorCode = "UNSUPPORTED_MEDIA_TYPE"
+	ErrorCodeInternal             ErrorCode = "INTERNAL_ERROR"
+)
+
+var errorCodeByStatus = map[int]ErrorCode{
+	http.StatusBadRequest:            ErrorCodeMalformedRequest,
+	http.StatusUnauthorized:          ErrorCodeUnauthorized,
+	http.StatusForbidden:             ErrorCodeForbidden,
+	http.StatusNotFound:              ErrorCodeNotFound,
+	http.StatusMethodNotAllowed:      ErrorCodeMethodNotAllowed,
+	http.StatusRequestEntityTooLarge: ErrorCodeRequestTooLarge,
+	http.StatusUnsupportedMediaType:  ErrorCodeUnsupportedMediaType,
+	http.StatusInternalServerError:   ErrorCodeInternal,
+}
+
+var messageTHByErrorCode = map[ErrorCode]string{
+	ErrorCodeMalformedRequest:     "รูปแบบคำขอไม่ถูกต้อง",
+	ErrorCodeValidationFailed:     "ข้อมูลไม่ผ่านการตรวจสอบ",
+	ErrorCodeUnauthorized:         "กรุณายืนยันตัวตน",
+	ErrorCodeForbidden:            "ไม่มีสิทธิ์เข้าถึง",
+	ErrorCodeNotFound:             "ไม่พบข้อมูลที่ต้องการ",
+	ErrorCodeMethodNotAllowed:     "ไม่รองรับ method นี้",
+	ErrorCodeRequestTooLarge:      "คำขอมีขนาดใหญ่เกินกำหนด",
+	ErrorCodeUnsupportedMediaType: "ไม่รองรับชนิดข้อมูลนี้",
+	ErrorCodeInternal:             "เกิดข้อผิดพลาดภายในระบบ",
+}
+
 type ErrorResponse struct {
-	Message string `json:"message"`
+	Code       ErrorCode   `json:"code"`
+	Message    string      `json:"message"`
+	MessageTH  string      `json:"messageTh"`
+	Violations []Violation `json:"violations,omitempty"`
+	Details    any         `json:"details,omitempty"`
+}
+
+// Violation is a rule that a request field does not satisfy, e.g. field "amount"
+// failing rule "lte" with param "100000".
+type Violation struct {
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+	Param string `json:"param,omitempty"`
+}
+
+// Error is an error reported to the client with the given status and code. A
+// controller returns it and HTTPErrorHandler writes the response.
+type Error struct {
+	Status     int
+	Code       ErrorCode
+	Message    string
+	MessageTH  string
+	Violations []Violation
+	// Details is any further data about the error, e.g. a report of every failed
+	// row of an uploaded file.
+	Details any
+	Err     error
+}
+
+// NewError returns an Error with the message of err and the Thai message of code.
+func NewError(status int, code ErrorCode, err error) *Error {
+	return &Error{
+		Status:    status,
+		Code:      code,
+		Message:   err.Error(),
+		MessageTH: messageTHByErrorCode[code],
+		Err:       err,
+	}
+}
+
+// NewValidationError returns an Error for a request breaking the given rules.
+func NewValidationError(violations ...Violation) *Error {
+	return &Error{
+		Status:     http.StatusBadRequest,
+		Code:       ErrorCodeValidationFailed,
+		Message:    "request validation failed",
+		MessageTH:  messageTHByErrorCode[ErrorCodeValidationFailed],
+		Violations: violations,
+	}
+}
+
+// ViolationsOf returns the violations carried by a validation error, and false
+// when err is not a validation error.
+func ViolationsOf(err error) ([]Violation, bool) {
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		return toViolations(validationErrors), true
+	}
+
+	var e *Error
+	if errors.As(err, &e) && e.Code == ErrorCodeValidationFailed {
+		return e.Violations, true
+	}
+
+	return nil, false
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// WithMessageTH replaces the Thai message of e.
+func (e *Error) WithMessageTH(messageTH string) *Error {
+	e.MessageTH = messageTH
+	return e
+}
+
+// HTTPErrorHandler writes every error returned by a handler as an ErrorResponse.
+// Errors that are neither an Error, a validation error nor an echo.HTTPError are
+// logged and reported as an internal error without details.
+func HTTPErrorHandler(err error, ctx echo.Context) {
+	if ctx.Response().Committed {
+		return
+	}
+
+	e := toError(err)
+	if e.Status >= http.StatusInternalServerError {
+		slog.Error("Failed to handle request", "method", ctx.Request().Method, "path", ctx.Path(), "err", err)
+	}
+
+	response := ErrorResponse{
+		Code:       e.Code,
+		Message:    e.Message,
+		MessageTH:  e.MessageTH,
+		Violations: e.Violations,
+		Details:    e.Details,
+	}
+
+	var writeErr error
+	if ctx.Request().Method == http.MethodHead {
+		writeErr = ctx.NoContent(e.Status)
+	} else {
+		writeErr = ctx.JSON(e.Status, response)
+	}
+	if writeErr != nil {
+		slog.Error("Failed to write error response", "err", writeErr)
+	}
+}
+
+func toError(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		if e.MessageTH == "" {
+			e.MessageTH = messageTHByErrorCode[e.Code]
+		}
+		return e
+	}
+
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		e := NewValidationError(toViolations(validationErrors)...)
+		e.Err = err
+		return e
+	}
+
+	var bindingError *echo.BindingError
+	if errors.As(err, &bindingError) {
+		return &Error{
+			Status:     http.StatusBadRequest,
+			Code:       ErrorCodeMalformedRequest,
+			Message:    fmt.Sprintf("invalid value of %s", bindingError.Field),
+			MessageTH:  messageTHByErrorCode[ErrorCodeMalformedRequest],
+			Violations: []Violation{{Field: bindingError.Field, Rule: "type"}},
+			Err:        err,
+		}
+	}
+
+	var httpError *echo.HTTPError
+	if errors.As(err, &httpError) {
+		return fromHTTPError(httpError)
+	}
+
+	return &Error{
+		Status:    http.StatusInternalServerError,
+		Code:      ErrorCodeInternal,
+		Message:   "internal server error",
+		MessageTH: messageTHByErrorCode[ErrorCodeInternal],
+		Err:       err,
+	}
+}
+
+// fromHTTPError converts errors raised by echo itself, e.g. on an unknown route,
+// a failed basic auth or a request body that cannot be decoded.
+func fromHTTPError(httpError *echo.HTTPError) *Error {
+	code, ok := errorCodeByStatus[httpError.Code]
+	if !ok {
+		code = ErrorCode(strings.ToUpper(strings.ReplaceAll(http.StatusText(httpError.Code), " ", "_")))
+	}
+
+	e := &Error{
+		Status:    httpError.Code,
+		Code:      code,
+		Message:   strings.ToLower(http.StatusText(httpError.Code)),
+		MessageTH: messageTHByErrorCode[code],
+		Err:       httpError,
+	}
+
+	if httpError.Code >= http.StatusInternalServerError {
+		return e
+	}
+
+	if message, ok := httpError.Message.(string); ok {
+		e.Message = message
+	}
+
+	var unmarshalTypeError *json.UnmarshalTypeError
+	if errors.As(httpError.Internal, &unmarshalTypeError) {
+		e.Message = "request body has a field of the wrong type"
+		e.Violations = []Violation{{Field: unmarshalTypeError.Field, Rule: "type", Param: unmarshalTypeError.Type.String()}}
+	}
+
+	return e
+}
+
+func toViolations(validationErrors validator.ValidationErrors) []Violation {
+	violations := make([]Violation, 0, len(validationErrors))
+	for _, v := range validationErrors {
+		violations = append(violations, Violation{
+			Field: fieldPath(v),
+			Rule:  v.Tag(),
+			Param: v.Param(),
+		})
+	}
+
+	return violations
+}
+
+// fieldPath is the path of the field without the name of the top-level struct,
+// e.g. "brackets[0].rate".
+func fieldPath(fe validator.FieldError) string {
+	_, path, ok := strings.Cut(fe.Namespace(), ".")
+	if !ok {
+		return fe.Namespace()
+	}
+
+	return path
 }
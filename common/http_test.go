@@ -0,0 +1,126 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPErrorHandler(t *testing.T) {
+	type amountRequest struct {
+		TaxYear int   `json:"taxYear" validate:"omitempty,gt=0"`
+		Amount  Money `json:"amount" validate:"required,lte=100000"`
+	}
+
+	testCases := []struct {
+		name             string
+		body             string
+		handlerErr       error
+		expectedStatus   int
+		expectedResponse ErrorResponse
+	}{
+		{
+			name:           "Should report violations, given request failing validation",
+			body:           `{"taxYear": -1, "amount": 100000.01}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedResponse: ErrorResponse{
+				Code:      ErrorCodeValidationFailed,
+				Message:   "request validation failed",
+				MessageTH: "ข้อมูลไม่ผ่านการตรวจสอบ",
+				Violations: []Violation{
+					{Field: "taxYear", Rule: "gt", Param: "0"},
+					{Field: "amount", Rule: "lte", Param: "100000"},
+				},
+			},
+		},
+		{
+			name:           "Should report field of wrong type, given malformed body",
+			body:           `{"taxYear": "2567", "amount": 1000}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedResponse: ErrorResponse{
+				Code:       ErrorCodeMalformedRequest,
+				Message:    "request body has a field of the wrong type",
+				MessageTH:  "รูปแบบคำขอไม่ถูกต้อง",
+				Violations: []Violation{{Field: "taxYear", Rule: "type", Param: "int"}},
+			},
+		},
+		{
+			name:           "Should report Error as is",
+			body:           `{"amount": 1000}`,
+			handlerErr:     NewError(http.StatusNotFound, ErrorCodeNotFound, errors.New("calculation not found")).WithMessageTH("ไม่พบ"),
+			expectedStatus: http.StatusNotFound,
+			expectedResponse: ErrorResponse{
+				Code:      ErrorCodeNotFound,
+				Message:   "calculation not found",
+				MessageTH: "ไม่พบ",
+			},
+		},
+		{
+			name:           "Should hide details, given unexpected error",
+			body:           `{"amount": 1000}`,
+			handlerErr:     errors.New("pq: connection refused"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedResponse: ErrorResponse{
+				Code:      ErrorCodeInternal,
+				Message:   "internal server error",
+				MessageTH: "เกิดข้อผิดพลาดภายในระบบ",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := NewConfiguredEcho()
+			e.POST("/amounts", func(ctx echo.Context) error {
+				var request amountRequest
+				if err := ctx.Bind(&request); err != nil {
+					return err
+				}
+
+				if err := ctx.Validate(&request); err != nil {
+					return err
+				}
+
+				if tc.handlerErr != nil {
+					return tc.handlerErr
+				}
+
+				return ctx.NoContent(http.StatusOK)
+			})
+
+			request := httptest.NewRequest(http.MethodPost, "/amounts", strings.NewReader(tc.body))
+			request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+			recorder := httptest.NewRecorder()
+			e.ServeHTTP(recorder, request)
+
+			require.Equal(t, tc.expectedStatus, recorder.Code)
+
+			var response ErrorResponse
+			err := json.Unmarshal(recorder.Body.Bytes(), &response)
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedResponse, response)
+		})
+	}
+}
+
+func TestHTTPErrorHandlerWithUnknownRoute(t *testing.T) {
+	e := NewConfiguredEcho()
+
+	request := httptest.NewRequest(http.MethodGet, "/unknown", nil)
+	recorder := httptest.NewRecorder()
+	e.ServeHTTP(recorder, request)
+
+	require.Equal(t, http.StatusNotFound, recorder.Code)
+
+	var response ErrorResponse
+	err := json.Unmarshal(recorder.Body.Bytes(), &response)
+	require.NoError(t, err)
+	require.Equal(t, ErrorCodeNotFound, response.Code)
+}
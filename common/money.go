@@ -0,0 +1,117 @@
+package common
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+const satangPerBaht = 100
+
+// Money is a fixed-point amount of Thai baht stored as a whole number of satang.
+//
+// Arithmetic on Money is exact. Amounts that can carry fractions of a satang, such
+// as income multiplied by a tax rate, must be computed with decimal.Decimal and
+// converted back with RoundMoney, which rounds half-up to the nearest satang.
+type Money int64
+
+func Baht(baht int64) Money {
+	return Money(baht * satangPerBaht)
+}
+
+// RoundMoney rounds d half-up (away from zero) to the nearest satang.
+func RoundMoney(d decimal.Decimal) Money {
+	return Money(d.Round(2).Shift(2).IntPart())
+}
+
+func ParseMoney(s string) (Money, error) {
+	d, err := decimal.NewFromString(strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+
+	return moneyFromDecimal(d)
+}
+
+func moneyFromDecimal(d decimal.Decimal) (Money, error) {
+	if !d.Equal(d.Round(2)) {
+		return 0, fmt.Errorf("amount %s has more than 2 decimal places", d.String())
+	}
+
+	satang := d.Shift(2)
+	if satang.GreaterThan(decimal.NewFromInt(math.MaxInt64)) || satang.LessThan(decimal.NewFromInt(math.MinInt64)) {
+		return 0, fmt.Errorf("amount %s is out of range", d.String())
+	}
+
+	return Money(satang.IntPart()), nil
+}
+
+func (m Money) Decimal() decimal.Decimal {
+	return decimal.New(int64(m), -2)
+}
+
+// Float64 returns the amount in baht. It is meant for validation and
+// presentation only, never for further arithmetic.
+func (m Money) Float64() float64 {
+	return m.Decimal().InexactFloat64()
+}
+
+// String formats the amount in baht with at least one and at most two decimal places,
+// e.g. "29000.0" or "5321.25".
+func (m Money) String() string {
+	s := m.Decimal().StringFixed(2)
+	return strings.TrimSuffix(s, "0")
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "null" {
+		return nil
+	}
+
+	parsed, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+
+	*m = parsed
+	return nil
+}
+
+func (m *Money) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return m.scanString(string(v))
+	case string:
+		return m.scanString(v)
+	case int64:
+		*m = Baht(v)
+		return nil
+	case float64:
+		*m = RoundMoney(decimal.NewFromFloat(v))
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into Money", src)
+	}
+}
+
+func (m *Money) scanString(s string) error {
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		return err
+	}
+
+	*m = RoundMoney(d)
+	return nil
+}
+
+func (m Money) Value() (driver.Value, error) {
+	return m.Decimal().StringFixed(2), nil
+}
@@ -0,0 +1,94 @@
+package common
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMoney(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Money
+		isValid  bool
+	}{
+		{input: "500000", expected: Baht(500000), isValid: true},
+		{input: "500000.5", expected: Money(50000050), isValid: true},
+		{input: "0.01", expected: Money(1), isValid: true},
+		{input: " 12.30 ", expected: Money(1230), isValid: true},
+		{input: "12.345", isValid: false},
+		{input: "abc", isValid: false},
+		{input: "", isValid: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			actual, err := ParseMoney(tc.input)
+			if !tc.isValid {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestRoundMoney(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Money
+	}{
+		{input: "29000.004", expected: Money(2900000)},
+		{input: "29000.005", expected: Money(2900001)},
+		{input: "29000.0149", expected: Money(2900001)},
+		{input: "-0.005", expected: Money(-1)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			require.Equal(t, tc.expected, RoundMoney(decimal.RequireFromString(tc.input)))
+		})
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	testCases := []struct {
+		money    Money
+		expected string
+	}{
+		{money: Baht(29000), expected: "29000.0"},
+		{money: Money(532125), expected: "5321.25"},
+		{money: Money(532150), expected: "5321.5"},
+		{money: Money(0), expected: "0.0"},
+		{money: Baht(123456789012), expected: "123456789012.0"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expected, func(t *testing.T) {
+			data, err := json.Marshal(tc.money)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, string(data))
+
+			var actual Money
+			err = json.Unmarshal(data, &actual)
+			require.NoError(t, err)
+			require.Equal(t, tc.money, actual)
+		})
+	}
+}
+
+func TestMoneyScan(t *testing.T) {
+	var money Money
+
+	require.NoError(t, money.Scan([]byte("60000.00")))
+	require.Equal(t, Baht(60000), money)
+
+	require.NoError(t, money.Scan(int64(50000)))
+	require.Equal(t, Baht(50000), money)
+
+	require.Error(t, money.Scan(true))
+}
@@ -0,0 +1,26 @@
+package common
+
+// IsThaiNationalID reports whether id is a 13-digit Thai citizen ID whose last
+// digit matches the checksum of the first 12 digits.
+func IsThaiNationalID(id string) bool {
+	if len(id) != 13 {
+		return false
+	}
+
+	sum := 0
+	for i := 0; i < 12; i++ {
+		digit := id[i] - '0'
+		if digit > 9 {
+			return false
+		}
+
+		sum += int(digit) * (13 - i)
+	}
+
+	checkDigit := id[12] - '0'
+	if checkDigit > 9 {
+		return false
+	}
+
+	return (11-sum%11)%10 == int(checkDigit)
+}
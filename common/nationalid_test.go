@@ -0,0 +1,29 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsThaiNationalID(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected bool
+	}{
+		{input: "1101700203450", expected: true},
+		{input: "1234567890121", expected: true},
+		{input: "1101700203451", expected: false},
+		{input: "110170020345", expected: false},
+		{input: "11017002034500", expected: false},
+		{input: "110170020345a", expected: false},
+		{input: "1-10170020345", expected: false},
+		{input: "", expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			require.Equal(t, tc.expected, IsThaiNationalID(tc.input))
+		})
+	}
+}
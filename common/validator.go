@@ -1,11 +1,57 @@
 package common
 
-import "github.com/go-playground/validator/v10"
+import (
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
 
 type EchoValidator struct {
 	Validator *validator.Validate
 }
 
+func NewValidator() *validator.Validate {
+	v := validator.New()
+
+	// Report fields by their JSON name, as the client sent them.
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	// Money is validated in baht so that tags such as `gte=10000` read naturally.
+	v.RegisterCustomTypeFunc(func(field reflect.Value) interface{} {
+		if money, ok := field.Interface().(Money); ok {
+			return money.Float64()
+		}
+		return nil
+	}, Money(0))
+
+	v.RegisterValidation("thai_national_id", func(fl validator.FieldLevel) bool {
+		return IsThaiNationalID(fl.Field().String())
+	})
+
+	return v
+}
+
+// Validatable is implemented by requests with rules that tags cannot express, such
+// as rules across several fields. Validate is called once the tags pass.
+type Validatable interface {
+	Validate() error
+}
+
 func (v *EchoValidator) Validate(i interface{}) error {
-	return v.Validator.Struct(i)
+	if err := v.Validator.Struct(i); err != nil {
+		return err
+	}
+
+	if validatable, ok := i.(Validatable); ok {
+		return validatable.Validate()
+	}
+
+	return nil
 }
@@ -1,13 +1,18 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
+	"strconv"
 
 	"github.com/chuckboliver/assessment-tax/app"
 	"github.com/chuckboliver/assessment-tax/common"
+	"github.com/chuckboliver/assessment-tax/migrate"
+	"github.com/chuckboliver/assessment-tax/postgres"
 	"github.com/labstack/echo/v4"
 )
 
@@ -32,16 +37,72 @@ func main() {
 		adminPassword = "admin!"
 	}
 
+	maxUploadSize := os.Getenv("MAX_UPLOAD_SIZE")
+	if maxUploadSize == "" {
+		maxUploadSize = "100M"
+	}
+
+	maxUploadRows := 1000000
+	if v := os.Getenv("MAX_UPLOAD_ROWS"); v != "" {
+		var err error
+		maxUploadRows, err = strconv.Atoi(v)
+		if err != nil || maxUploadRows < 0 {
+			slog.Error("Invalid MAX_UPLOAD_ROWS", "value", v)
+			os.Exit(1)
+		}
+	}
+
+	jobWorkers := 4
+	if v := os.Getenv("JOB_WORKERS"); v != "" {
+		var err error
+		jobWorkers, err = strconv.Atoi(v)
+		if err != nil || jobWorkers < 1 {
+			slog.Error("Invalid JOB_WORKERS", "value", v)
+			os.Exit(1)
+		}
+	}
+
+	configFallback := os.Getenv("CONFIG_FALLBACK")
+	if configFallback == "" {
+		configFallback = "strict"
+	}
+	if configFallback != "strict" && configFallback != "lenient" {
+		slog.Error("Invalid CONFIG_FALLBACK", "value", configFallback)
+		os.Exit(1)
+	}
+
+	historyFailure := os.Getenv("HISTORY_FAILURE")
+	if historyFailure == "" {
+		historyFailure = "fail"
+	}
+	if historyFailure != "fail" && historyFailure != "log" {
+		slog.Error("Invalid HISTORY_FAILURE", "value", historyFailure)
+		os.Exit(1)
+	}
+
 	appConfig := common.AppConfig{
-		Port:          port,
-		DatabaseURL:   databaseURL,
-		AdminUsername: adminUsername,
-		AdminPassword: adminPassword,
+		Port:           port,
+		DatabaseURL:    databaseURL,
+		AdminUsername:  adminUsername,
+		AdminPassword:  adminPassword,
+		MaxUploadSize:  maxUploadSize,
+		MaxUploadRows:  maxUploadRows,
+		JobWorkers:     jobWorkers,
+		ConfigFallback: configFallback,
+		HistoryFailure: historyFailure,
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(appConfig, os.Args[2:]); err != nil {
+			slog.Error("Failed to migrate database", "err", err)
+			os.Exit(1)
+		}
+		return
 	}
 
 	e, err := app.New(appConfig)
 	if err != nil {
-		slog.Error("Failed to create new echo server", err)
+		slog.Error("Failed to create new echo server", "err", err)
 		os.Exit(1)
 	}
 
@@ -53,3 +114,47 @@ func main() {
 
 	e.Logger.Fatal(e.Start(address))
 }
+
+// runMigrate runs "migrate up", "migrate down [steps]" or "migrate version".
+// The server applies pending migrations on start, so "migrate up" is only
+// needed to migrate without starting it.
+func runMigrate(config common.AppConfig, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: migrate up|down [steps]|version")
+	}
+
+	db, err := postgres.New(config.DatabaseURL)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator, err := migrate.New(db)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		return migrator.Up(ctx)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("invalid steps %q", args[1])
+			}
+		}
+		return migrator.Down(ctx, steps)
+	case "version":
+		version, err := migrator.Version(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Println(version)
+		return nil
+	}
+
+	return fmt.Errorf("unknown migrate command %q", args[0])
+}
@@ -0,0 +1,246 @@
+// Package migrate keeps the database schema up to date with the versioned SQL
+// migrations embedded in the binary.
+package migrate
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/jmoiron/sqlx"
+)
+
+//go:embed migrations/*.sql
+var embeddedMigrations embed.FS
+
+// lockID is the key of the advisory lock held while migrating, so that servers
+// started together do not apply the same migration twice.
+const lockID = 7_263_514_001
+
+var ErrNoDownMigration = errors.New("no down migration")
+
+// Migration is a change of the schema, read from a pair of files named
+// "<version>_<name>.up.sql" and "<version>_<name>.down.sql". The down file is
+// optional, leaving the migration irreversible.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Migrator struct {
+	db         *sqlx.DB
+	migrations []Migration
+}
+
+// New returns a Migrator applying the migrations embedded in the binary.
+func New(db *sqlx.DB) (*Migrator, error) {
+	migrations, err := load(embeddedMigrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		db:         db,
+		migrations: migrations,
+	}, nil
+}
+
+var migrationFilename = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// load reads the migrations of dir, sorted by version.
+func load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	migrationByVersion := make(map[int]*Migration)
+	for _, v := range entries {
+		match := migrationFilename.FindStringSubmatch(v.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration filename %q", v.Name())
+		}
+
+		version, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration filename %q: %w", v.Name(), err)
+		}
+
+		content, err := fs.ReadFile(fsys, path.Join(dir, v.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := migrationByVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			migrationByVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migrations %q and %q have the same version", migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(migrationByVersion))
+	for _, v := range migrationByVersion {
+		if v.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up migration", v.Version, v.Name)
+		}
+		migrations = append(migrations, *v)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Up applies every migration that has not been applied yet, in the order of
+// their versions. Each migration is applied in its own transaction.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *sqlx.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, v := range m.migrations {
+			if _, ok := applied[v.Version]; ok {
+				continue
+			}
+
+			insertSQL := `INSERT INTO schema_version (version, name) VALUES ($1, $2)`
+			if err := applyInTx(ctx, conn, v.Up, insertSQL, v.Version, v.Name); err != nil {
+				return fmt.Errorf("apply migration %d_%s: %w", v.Version, v.Name, err)
+			}
+
+			slog.Info("Applied migration", "version", v.Version, "name", v.Name)
+		}
+
+		return nil
+	})
+}
+
+// Down reverts the last steps applied migrations, latest first.
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	return m.withLock(ctx, func(conn *sqlx.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+			v := m.migrations[i]
+			if _, ok := applied[v.Version]; !ok {
+				continue
+			}
+
+			if v.Down == "" {
+				return fmt.Errorf("%w: %d_%s", ErrNoDownMigration, v.Version, v.Name)
+			}
+
+			deleteSQL := `DELETE FROM schema_version WHERE version = $1`
+			if err := applyInTx(ctx, conn, v.Down, deleteSQL, v.Version); err != nil {
+				return fmt.Errorf("revert migration %d_%s: %w", v.Version, v.Name, err)
+			}
+
+			slog.Info("Reverted migration", "version", v.Version, "name", v.Name)
+			steps--
+		}
+
+		return nil
+	})
+}
+
+// Version returns the latest applied version, or 0 when none is.
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	var version int
+	err := m.withLock(ctx, func(conn *sqlx.Conn) error {
+		return conn.QueryRowxContext(ctx, `SELECT COALESCE(max(version), 0) FROM schema_version`).Scan(&version)
+	})
+
+	return version, err
+}
+
+// withLock runs fn on a single connection holding the advisory lock, after
+// creating the schema_version table when missing.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sqlx.Conn) error) error {
+	conn, err := m.db.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return err
+	}
+	defer func() {
+		// The lock is released with the connection should unlocking fail.
+		if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockID); err != nil {
+			slog.Error("Failed to release migration lock", "err", err)
+		}
+	}()
+
+	createSQL := `
+		CREATE TABLE IF NOT EXISTS schema_version (
+			version int4 NOT NULL PRIMARY KEY,
+			name varchar(255) NOT NULL,
+			applied_at timestamptz NOT NULL DEFAULT now()
+		)
+	`
+	if _, err := conn.ExecContext(ctx, createSQL); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+func appliedVersions(ctx context.Context, conn *sqlx.Conn) (map[int]struct{}, error) {
+	versions := make([]int, 0)
+	if err := sqlx.SelectContext(ctx, conn, &versions, `SELECT version FROM schema_version`); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]struct{}, len(versions))
+	for _, v := range versions {
+		applied[v] = struct{}{}
+	}
+
+	return applied, nil
+}
+
+// applyInTx runs the statements of a migration file, then records it with
+// versionSQL, in a single transaction.
+func applyInTx(ctx context.Context, conn *sqlx.Conn, migrationSQL string, versionSQL string, args ...any) error {
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, migrationSQL); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, versionSQL, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,163 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+
+	_ "github.com/lib/pq"
+)
+
+func TestLoad(t *testing.T) {
+	testCases := []struct {
+		name     string
+		files    fstest.MapFS
+		expected []Migration
+		isValid  bool
+	}{
+		{
+			name: "sorted by version",
+			files: fstest.MapFS{
+				"migrations/0010_add_audit_log.up.sql":    {Data: []byte("CREATE TABLE audit_log ();")},
+				"migrations/0002_add_index.up.sql":        {Data: []byte("CREATE INDEX i ON t (c);")},
+				"migrations/0002_add_index.down.sql":      {Data: []byte("DROP INDEX i;")},
+				"migrations/0001_initial_schema.up.sql":   {Data: []byte("CREATE TABLE t (c int4);")},
+				"migrations/0001_initial_schema.down.sql": {Data: []byte("DROP TABLE t;")},
+				"migrations/0010_add_audit_log.down.sql":  {Data: []byte("DROP TABLE audit_log;")},
+			},
+			expected: []Migration{
+				{Version: 1, Name: "initial_schema", Up: "CREATE TABLE t (c int4);", Down: "DROP TABLE t;"},
+				{Version: 2, Name: "add_index", Up: "CREATE INDEX i ON t (c);", Down: "DROP INDEX i;"},
+				{Version: 10, Name: "add_audit_log", Up: "CREATE TABLE audit_log ();", Down: "DROP TABLE audit_log;"},
+			},
+			isValid: true,
+		},
+		{
+			name: "without down migration",
+			files: fstest.MapFS{
+				"migrations/0001_initial_schema.up.sql": {Data: []byte("CREATE TABLE t (c int4);")},
+			},
+			expected: []Migration{
+				{Version: 1, Name: "initial_schema", Up: "CREATE TABLE t (c int4);"},
+			},
+			isValid: true,
+		},
+		{
+			name: "without up migration",
+			files: fstest.MapFS{
+				"migrations/0001_initial_schema.down.sql": {Data: []byte("DROP TABLE t;")},
+			},
+			isValid: false,
+		},
+		{
+			name: "same version",
+			files: fstest.MapFS{
+				"migrations/0001_initial_schema.up.sql": {Data: []byte("CREATE TABLE t (c int4);")},
+				"migrations/0001_add_index.up.sql":      {Data: []byte("CREATE INDEX i ON t (c);")},
+			},
+			isValid: false,
+		},
+		{
+			name: "invalid filename",
+			files: fstest.MapFS{
+				"migrations/initial_schema.sql": {Data: []byte("CREATE TABLE t (c int4);")},
+			},
+			isValid: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := load(tc.files, "migrations")
+			if !tc.isValid {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := load(embeddedMigrations, "migrations")
+
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+	require.Equal(t, 1, migrations[0].Version)
+}
+
+// initSQL is the schema created by the first init.sql, before migrations.
+const initSQL = `
+	CREATE TABLE IF NOT EXISTS tax_config (
+		id serial4 NOT NULL PRIMARY KEY,
+		name varchar(255) NOT NULL,
+		value REAL NOT NULL
+	);
+
+	INSERT INTO tax_config (name, value)
+	VALUES ('personal_deduction', 60000),
+	('kreceipt_deduction', 50000);
+`
+
+// TestUpFromInitSQL migrates a database created by the first init.sql. It needs
+// a postgres database, given by MIGRATE_TEST_DATABASE_URL, and is skipped
+// without one. The tables are created in a schema of their own, dropped after.
+func TestUpFromInitSQL(t *testing.T) {
+	databaseURL := os.Getenv("MIGRATE_TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("MIGRATE_TEST_DATABASE_URL is not set")
+	}
+
+	ctx := context.Background()
+
+	db, err := sqlx.Connect("postgres", databaseURL)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	// A single connection keeps the search path of the schema for every statement.
+	db.SetMaxOpenConns(1)
+
+	schema := fmt.Sprintf("migrate_test_%d", time.Now().UnixNano())
+	db.MustExecContext(ctx, `CREATE SCHEMA `+schema)
+	t.Cleanup(func() { db.MustExecContext(ctx, `DROP SCHEMA `+schema+` CASCADE`) })
+
+	db.MustExecContext(ctx, `SET search_path TO `+schema)
+	db.MustExecContext(ctx, initSQL)
+
+	migrator, err := New(db)
+	require.NoError(t, err)
+
+	err = migrator.Up(ctx)
+	require.NoError(t, err)
+
+	type taxConfigRow struct {
+		TaxYear int    `db:"tax_year"`
+		Name    string `db:"name"`
+		Value   string `db:"value"`
+	}
+
+	rows := make([]taxConfigRow, 0)
+	err = db.SelectContext(ctx, &rows, `SELECT tax_year, name, value FROM tax_config ORDER BY name`)
+	require.NoError(t, err)
+	require.Equal(t, []taxConfigRow{
+		{TaxYear: 2567, Name: "kreceipt_deduction", Value: "50000.00"},
+		{TaxYear: 2567, Name: "personal_deduction", Value: "60000.00"},
+	}, rows)
+
+	var config string
+	err = db.GetContext(ctx, &config, `SELECT config FROM tax_config_versions WHERE tax_year = 2567`)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"kreceipt_deduction": 50000.00, "personal_deduction": 60000.00}`, config)
+
+	version, err := migrator.Version(ctx)
+	require.NoError(t, err)
+	require.Equal(t, migrator.migrations[len(migrator.migrations)-1].Version, version)
+}
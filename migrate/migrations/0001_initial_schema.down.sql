@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS calculation_job_results;
+DROP TABLE IF EXISTS calculation_jobs;
+DROP TABLE IF EXISTS calculations;
+DROP TABLE IF EXISTS tax_config_versions;
+DROP TABLE IF EXISTS tax_bracket;
+DROP TABLE IF EXISTS tax_config;
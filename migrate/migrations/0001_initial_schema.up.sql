@@ -0,0 +1,104 @@
+-- The schema that init.sql used to create. Tables are created only when missing
+-- and seeded only when empty, so that databases created by init.sql are taken
+-- over, after bringing the tables of older versions of init.sql up to date.
+
+CREATE TABLE IF NOT EXISTS tax_config (
+	id serial4 NOT NULL PRIMARY KEY,
+	tax_year int4 NOT NULL,
+	name varchar(255) NOT NULL,
+	value NUMERIC(15, 2) NOT NULL
+);
+
+-- The first init.sql had neither tax years nor exact values. Its config is of
+-- the tax year that every calculation was of.
+ALTER TABLE tax_config ADD COLUMN IF NOT EXISTS tax_year int4 NOT NULL DEFAULT 2567;
+ALTER TABLE tax_config ALTER COLUMN tax_year DROP DEFAULT;
+ALTER TABLE tax_config ALTER COLUMN value TYPE NUMERIC(15, 2);
+
+INSERT INTO tax_config (tax_year, name, value)
+SELECT *
+FROM (
+	VALUES (2567, 'personal_deduction', 60000),
+	(2567, 'kreceipt_deduction', 50000),
+	(2567, 'donation_deduction', 100000)
+) AS seed (tax_year, name, value)
+WHERE NOT EXISTS (SELECT 1 FROM tax_config);
+
+CREATE TABLE IF NOT EXISTS tax_bracket (
+	id serial4 NOT NULL PRIMARY KEY,
+	tax_year int4 NOT NULL,
+	lower_bound NUMERIC(15, 2) NOT NULL,
+	upper_bound NUMERIC(15, 2) NULL,
+	rate NUMERIC(5, 4) NOT NULL
+);
+
+INSERT INTO tax_bracket (tax_year, lower_bound, upper_bound, rate)
+SELECT *
+FROM (
+	VALUES (2567, 0, 150000, 0),
+	(2567, 150000, 500000, 0.1),
+	(2567, 500000, 1000000, 0.15),
+	(2567, 1000000, 2000000, 0.2),
+	(2567, 2000000, NULL::numeric, 0.35)
+) AS seed (tax_year, lower_bound, upper_bound, rate)
+WHERE NOT EXISTS (SELECT 1 FROM tax_bracket);
+
+CREATE TABLE IF NOT EXISTS tax_config_versions (
+	version serial4 NOT NULL PRIMARY KEY,
+	tax_year int4 NOT NULL,
+	config jsonb NOT NULL,
+	brackets jsonb NOT NULL,
+	created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS tax_config_versions_tax_year_version_idx ON tax_config_versions (tax_year, version DESC);
+
+INSERT INTO tax_config_versions (tax_year, config, brackets)
+SELECT
+	tax_year,
+	COALESCE((SELECT jsonb_object_agg(name, value) FROM tax_config c WHERE c.tax_year = y.tax_year), '{}'),
+	(SELECT jsonb_agg(jsonb_build_object('lowerBound', lower_bound, 'upperBound', upper_bound, 'rate', rate) ORDER BY lower_bound) FROM tax_bracket b WHERE b.tax_year = y.tax_year)
+FROM (SELECT DISTINCT tax_year FROM tax_bracket) y
+WHERE NOT EXISTS (SELECT 1 FROM tax_config_versions)
+ORDER BY tax_year;
+
+CREATE TABLE IF NOT EXISTS calculations (
+	id uuid NOT NULL PRIMARY KEY DEFAULT gen_random_uuid(),
+	taxpayer_id varchar(255) NULL,
+	source varchar(16) NOT NULL,
+	request jsonb NOT NULL,
+	response jsonb NOT NULL,
+	created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS calculations_taxpayer_id_created_at_idx ON calculations (taxpayer_id, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS calculation_jobs (
+	id uuid NOT NULL PRIMARY KEY DEFAULT gen_random_uuid(),
+	status varchar(16) NOT NULL,
+	filename varchar(255) NOT NULL,
+	content_type varchar(255) NOT NULL,
+	partial boolean NOT NULL,
+	config_version int4 NOT NULL DEFAULT 0,
+	file bytea NULL,
+	total_rows int4 NOT NULL DEFAULT 0,
+	processed_rows int4 NOT NULL DEFAULT 0,
+	failed_rows int4 NOT NULL DEFAULT 0,
+	errors jsonb NULL,
+	error text NULL,
+	locked_until timestamptz NULL,
+	created_at timestamptz NOT NULL DEFAULT now(),
+	updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+-- Jobs created before config versions were added.
+ALTER TABLE calculation_jobs ADD COLUMN IF NOT EXISTS config_version int4 NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS calculation_jobs_status_created_at_idx ON calculation_jobs (status, created_at);
+
+CREATE TABLE IF NOT EXISTS calculation_job_results (
+	job_id uuid NOT NULL REFERENCES calculation_jobs (id) ON DELETE CASCADE,
+	row int4 NOT NULL,
+	result jsonb NOT NULL,
+	PRIMARY KEY (job_id, row)
+);
@@ -0,0 +1 @@
+ALTER TABLE tax_config DROP CONSTRAINT IF EXISTS tax_config_tax_year_name_key;
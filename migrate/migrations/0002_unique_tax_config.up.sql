@@ -0,0 +1,6 @@
+-- Keep the latest row of every config key duplicated before the key was unique.
+DELETE FROM tax_config a
+USING tax_config b
+WHERE a.tax_year = b.tax_year AND a.name = b.name AND a.id < b.id;
+
+ALTER TABLE tax_config ADD CONSTRAINT tax_config_tax_year_name_key UNIQUE (tax_year, name);
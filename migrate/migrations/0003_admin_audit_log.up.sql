@@ -0,0 +1,14 @@
+CREATE TABLE admin_audit_log (
+	id bigserial NOT NULL PRIMARY KEY,
+	config_key varchar(255) NOT NULL,
+	tax_year int4 NOT NULL,
+	old_value jsonb NULL,
+	new_value jsonb NOT NULL,
+	username varchar(255) NOT NULL,
+	client_ip varchar(64) NOT NULL,
+	request_id varchar(255) NOT NULL,
+	created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX admin_audit_log_config_key_created_at_idx ON admin_audit_log (config_key, created_at DESC);
+CREATE INDEX admin_audit_log_created_at_idx ON admin_audit_log (created_at DESC);
@@ -0,0 +1 @@
+ALTER TABLE calculation_jobs DROP COLUMN IF EXISTS locked_by;
@@ -0,0 +1,3 @@
+-- The worker holding the lease of a job, so that a worker whose lease expired
+-- cannot store results once another worker has resumed the job.
+ALTER TABLE calculation_jobs ADD COLUMN locked_by uuid NULL;
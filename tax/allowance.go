@@ -0,0 +1,366 @@
+package tax
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/chuckboliver/assessment-tax/common"
+	"github.com/shopspring/decimal"
+)
+
+type Allowance struct {
+	AllowanceType AllowanceType `json:"allowanceType" validate:"required"`
+	Amount        common.Money  `json:"amount" validate:"gte=0,lte=1000000000000"`
+}
+
+type AllowanceType string
+
+const (
+	AllowanceDonation AllowanceType = "donation"
+	// AllowanceDoubleDonation is a donation to education, sports, public hospitals
+	// and the like, deducted at twice its amount.
+	AllowanceDoubleDonation   AllowanceType = "double-donation"
+	AllowanceKReceipt         AllowanceType = "k-receipt"
+	AllowanceSpouse           AllowanceType = "spouse"
+	AllowanceChild            AllowanceType = "child"
+	AllowanceParentCare       AllowanceType = "parent-care"
+	AllowanceLifeInsurance    AllowanceType = "life-insurance"
+	AllowanceHealthInsurance  AllowanceType = "health-insurance"
+	AllowanceProvidentFund    AllowanceType = "provident-fund"
+	AllowanceRMF              AllowanceType = "rmf"
+	AllowanceSSF              AllowanceType = "ssf"
+	AllowanceThaiESG          AllowanceType = "thai-esg"
+	AllowanceSocialSecurity   AllowanceType = "social-security"
+	AllowanceHomeLoanInterest AllowanceType = "home-loan-interest"
+)
+
+type UnsupportedAllowanceTypeError struct {
+	AllowanceType  AllowanceType
+	SupportedTypes []AllowanceType
+}
+
+func (e *UnsupportedAllowanceTypeError) Error() string {
+	supportedTypes := make([]string, 0, len(e.SupportedTypes))
+	for _, v := range e.SupportedTypes {
+		supportedTypes = append(supportedTypes, string(v))
+	}
+
+	return fmt.Sprintf("unsupported allowance type %q, supported types are: %s", e.AllowanceType, strings.Join(supportedTypes, ", "))
+}
+
+// allowanceContext carries what an allowanceRule may base its cap on. netIncome is
+// the income after the expense and personal deductions, and deducted is the sum of
+// the allowances applied before the allowance being capped. Both are unrounded.
+type allowanceContext struct {
+	grossIncome common.Money
+	netIncome   decimal.Decimal
+	deducted    decimal.Decimal
+	rules       taxRules
+}
+
+// allowanceRule limits how much of a claimed allowance can be deducted. The limit
+// is unrounded, as a rate of an income may fall between two satang.
+type allowanceRule interface {
+	maxDeduction(ctx allowanceContext) decimal.Decimal
+}
+
+// rateRule is an allowanceRule capping at a rate of an amount, which the trace of
+// the allowance shows.
+type rateRule interface {
+	allowanceRule
+	rateOf(ctx allowanceContext) (base decimal.Decimal, rate decimal.Decimal)
+}
+
+// fixedCap caps an allowance at a fixed amount.
+type fixedCap common.Money
+
+func (f fixedCap) maxDeduction(_ allowanceContext) decimal.Decimal {
+	return common.Money(f).Decimal()
+}
+
+// configuredCap caps an allowance at a value configured per tax year.
+type configuredCap func(rules taxRules) common.Money
+
+func (f configuredCap) maxDeduction(ctx allowanceContext) decimal.Decimal {
+	return f(ctx.rules).Decimal()
+}
+
+// incomeRateCap caps an allowance at a percentage of gross income, but never above maxAmount.
+type incomeRateCap struct {
+	rate      decimal.Decimal
+	maxAmount common.Money
+}
+
+func (r incomeRateCap) maxDeduction(ctx allowanceContext) decimal.Decimal {
+	byIncome := ctx.grossIncome.Decimal().Mul(r.rate)
+	return decimal.Max(decimal.Min(byIncome, r.maxAmount.Decimal()), decimal.Zero)
+}
+
+func (r incomeRateCap) rateOf(ctx allowanceContext) (decimal.Decimal, decimal.Decimal) {
+	return ctx.grossIncome.Decimal(), r.rate
+}
+
+// netIncomeRateCap caps an allowance at a configured rate of the income left
+// after every deduction applied before it, but never above the configured
+// maxAmount.
+type netIncomeRateCap struct {
+	rate      func(rules taxRules) decimal.Decimal
+	maxAmount configuredCap
+}
+
+func (r netIncomeRateCap) maxDeduction(ctx allowanceContext) decimal.Decimal {
+	base, rate := r.rateOf(ctx)
+	byIncome := base.Mul(rate)
+	return decimal.Max(decimal.Min(byIncome, r.maxAmount.maxDeduction(ctx)), decimal.Zero)
+}
+
+func (r netIncomeRateCap) rateOf(ctx allowanceContext) (decimal.Decimal, decimal.Decimal) {
+	return decimal.Max(ctx.netIncome.Sub(ctx.deducted), decimal.Zero), r.rate(ctx.rules)
+}
+
+// allowanceGroup shares a combined cap between several allowance types.
+type allowanceGroup struct {
+	name string
+	rule allowanceRule
+}
+
+type registeredAllowance struct {
+	allowanceType AllowanceType
+	rule          allowanceRule
+	group         *allowanceGroup
+	// perClaim applies the rule to every claim on its own, e.g. one claim per child,
+	// instead of to the sum of all claims of the type.
+	perClaim bool
+	// multiplier counts every claim that many times before capping, e.g. 2 for
+	// donations deducted at twice their amount. Zero counts a claim once.
+	multiplier int64
+}
+
+type allowanceRegistry struct {
+	allowances map[AllowanceType]registeredAllowance
+	order      []AllowanceType
+}
+
+func newAllowanceRegistry() *allowanceRegistry {
+	return &allowanceRegistry{
+		allowances: make(map[AllowanceType]registeredAllowance),
+	}
+}
+
+// register adds an allowance type to the registry. Allowances are applied in
+// registration order.
+func (r *allowanceRegistry) register(allowance registeredAllowance) {
+	if _, ok := r.allowances[allowance.allowanceType]; !ok {
+		r.order = append(r.order, allowance.allowanceType)
+	}
+
+	r.allowances[allowance.allowanceType] = allowance
+}
+
+func (r *allowanceRegistry) supportedTypes() []AllowanceType {
+	supportedTypes := make([]AllowanceType, len(r.order))
+	copy(supportedTypes, r.order)
+	sort.Slice(supportedTypes, func(i, j int) bool {
+		return supportedTypes[i] < supportedTypes[j]
+	})
+
+	return supportedTypes
+}
+
+func (r *allowanceRegistry) isSupported(allowanceType AllowanceType) bool {
+	_, ok := r.allowances[allowanceType]
+	return ok
+}
+
+// isPerClaim reports whether several claims of allowanceType may be made, e.g. one
+// per child.
+func (r *allowanceRegistry) isPerClaim(allowanceType AllowanceType) bool {
+	return r.allowances[allowanceType].perClaim
+}
+
+func (r *allowanceRegistry) validate(allowances []Allowance) error {
+	for _, v := range allowances {
+		if _, ok := r.allowances[v.AllowanceType]; !ok {
+			return &UnsupportedAllowanceTypeError{
+				AllowanceType:  v.AllowanceType,
+				SupportedTypes: r.supportedTypes(),
+			}
+		}
+	}
+
+	return nil
+}
+
+// allowanceDeduction is how much of the claims of one allowance type was deducted.
+// Every amount but requested is unrounded.
+type allowanceDeduction struct {
+	allowanceType AllowanceType
+	requested     common.Money
+	// maxDeduction is the cap of the type, applied to every claim on its own for
+	// per-claim allowances. Group caps may reduce applied further.
+	maxDeduction decimal.Decimal
+	applied      decimal.Decimal
+	// base and rate are what maxDeduction was calculated from, when rateBased.
+	rateBased bool
+	base      decimal.Decimal
+	rate      decimal.Decimal
+}
+
+func totalAllowanceDeduction(deductions []allowanceDeduction) decimal.Decimal {
+	total := decimal.Zero
+	for _, v := range deductions {
+		total = total.Add(v.applied)
+	}
+
+	return total
+}
+
+// deduct applies the cap of every allowance type and group to the claimed allowances
+// and returns what can be deducted per allowance type, in registration order.
+func (r *allowanceRegistry) deduct(ctx allowanceContext, allowances []Allowance) ([]allowanceDeduction, error) {
+	if err := r.validate(allowances); err != nil {
+		return nil, err
+	}
+
+	claimsByType := make(map[AllowanceType][]common.Money)
+	for _, v := range allowances {
+		claimsByType[v.AllowanceType] = append(claimsByType[v.AllowanceType], v.Amount)
+	}
+
+	usedByGroup := make(map[string]decimal.Decimal)
+	deductions := make([]allowanceDeduction, 0, len(claimsByType))
+	for _, allowanceType := range r.order {
+		claims, ok := claimsByType[allowanceType]
+		if !ok {
+			continue
+		}
+
+		ctx.deducted = totalAllowanceDeduction(deductions)
+
+		allowance := r.allowances[allowanceType]
+		deduction := allowanceDeduction{
+			allowanceType: allowanceType,
+			maxDeduction:  allowance.rule.maxDeduction(ctx),
+		}
+		if rule, ok := allowance.rule.(rateRule); ok {
+			deduction.rateBased = true
+			deduction.base, deduction.rate = rule.rateOf(ctx)
+		}
+
+		multiplier := max(allowance.multiplier, 1)
+		counted := common.Money(0)
+		for _, claim := range claims {
+			deduction.requested += claim
+			if allowance.perClaim {
+				deduction.applied = deduction.applied.Add(decimal.Min((claim * common.Money(multiplier)).Decimal(), deduction.maxDeduction))
+			}
+			counted += claim * common.Money(multiplier)
+		}
+
+		if !allowance.perClaim {
+			deduction.applied = decimal.Min(counted.Decimal(), deduction.maxDeduction)
+		}
+
+		if allowance.group != nil {
+			remaining := allowance.group.rule.maxDeduction(ctx).Sub(usedByGroup[allowance.group.name])
+			deduction.applied = decimal.Min(deduction.applied, remaining)
+			usedByGroup[allowance.group.name] = usedByGroup[allowance.group.name].Add(deduction.applied)
+		}
+
+		deductions = append(deductions, deduction)
+	}
+
+	return deductions, nil
+}
+
+// defaultAllowanceRegistry returns the personal income tax allowances supported by
+// the calculator.
+func defaultAllowanceRegistry() *allowanceRegistry {
+	insurance := &allowanceGroup{name: "insurance", rule: fixedCap(common.Baht(100000))}
+	parentCare := &allowanceGroup{name: "parent-care", rule: fixedCap(common.Baht(120000))}
+	retirement := &allowanceGroup{name: "retirement", rule: fixedCap(common.Baht(500000))}
+	maxDonation := configuredCap(func(rules taxRules) common.Money {
+		return rules.maxDonationDeduction
+	})
+	donation := &allowanceGroup{name: "donation", rule: maxDonation}
+	donationIncomeRate := func(rules taxRules) decimal.Decimal {
+		return rules.donationIncomeRate
+	}
+
+	registry := newAllowanceRegistry()
+	registry.register(registeredAllowance{
+		allowanceType: AllowanceSpouse,
+		rule:          fixedCap(common.Baht(60000)),
+	})
+	registry.register(registeredAllowance{
+		allowanceType: AllowanceChild,
+		rule:          fixedCap(common.Baht(30000)),
+		perClaim:      true,
+	})
+	registry.register(registeredAllowance{
+		allowanceType: AllowanceParentCare,
+		rule:          fixedCap(common.Baht(30000)),
+		group:         parentCare,
+		perClaim:      true,
+	})
+	registry.register(registeredAllowance{
+		allowanceType: AllowanceSocialSecurity,
+		rule:          fixedCap(common.Baht(9000)),
+	})
+	registry.register(registeredAllowance{
+		allowanceType: AllowanceLifeInsurance,
+		rule:          fixedCap(common.Baht(100000)),
+		group:         insurance,
+	})
+	registry.register(registeredAllowance{
+		allowanceType: AllowanceHealthInsurance,
+		rule:          fixedCap(common.Baht(25000)),
+		group:         insurance,
+	})
+	registry.register(registeredAllowance{
+		allowanceType: AllowanceProvidentFund,
+		rule:          incomeRateCap{rate: decimal.RequireFromString("0.15"), maxAmount: common.Baht(500000)},
+		group:         retirement,
+	})
+	registry.register(registeredAllowance{
+		allowanceType: AllowanceRMF,
+		rule:          incomeRateCap{rate: decimal.RequireFromString("0.3"), maxAmount: common.Baht(500000)},
+		group:         retirement,
+	})
+	registry.register(registeredAllowance{
+		allowanceType: AllowanceSSF,
+		rule:          incomeRateCap{rate: decimal.RequireFromString("0.3"), maxAmount: common.Baht(200000)},
+		group:         retirement,
+	})
+	registry.register(registeredAllowance{
+		allowanceType: AllowanceThaiESG,
+		rule:          incomeRateCap{rate: decimal.RequireFromString("0.3"), maxAmount: common.Baht(300000)},
+	})
+	registry.register(registeredAllowance{
+		allowanceType: AllowanceHomeLoanInterest,
+		rule:          fixedCap(common.Baht(100000)),
+	})
+	registry.register(registeredAllowance{
+		allowanceType: AllowanceKReceipt,
+		rule: configuredCap(func(rules taxRules) common.Money {
+			return rules.maxKReceiptDeduction
+		}),
+	})
+	// Donations come last, as they are capped at a rate of the income left after
+	// every other deduction. Double donations are capped first, and the rest of
+	// the donations on the income left after them.
+	registry.register(registeredAllowance{
+		allowanceType: AllowanceDoubleDonation,
+		rule:          netIncomeRateCap{rate: donationIncomeRate, maxAmount: maxDonation},
+		group:         donation,
+		multiplier:    2,
+	})
+	registry.register(registeredAllowance{
+		allowanceType: AllowanceDonation,
+		rule:          netIncomeRateCap{rate: donationIncomeRate, maxAmount: maxDonation},
+		group:         donation,
+	})
+
+	return registry
+}
@@ -0,0 +1,224 @@
+package tax
+
+import (
+	"testing"
+
+	"github.com/chuckboliver/assessment-tax/common"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllowanceRegistryDeduct(t *testing.T) {
+	rules := taxRules{
+		maxKReceiptDeduction: common.Baht(50000),
+		maxDonationDeduction: common.Baht(100000),
+		donationIncomeRate:   decimal.RequireFromString("0.1"),
+	}
+
+	testCases := []struct {
+		name        string
+		grossIncome common.Money
+		allowances  []Allowance
+		expected    string
+	}{
+		{
+			name:        "Should cap allowance at fixed amount",
+			grossIncome: common.Baht(500000),
+			allowances: []Allowance{
+				{AllowanceType: AllowanceSpouse, Amount: common.Baht(80000)},
+			},
+			expected: "60000",
+		},
+		{
+			name:        "Should cap allowance at configured amount",
+			grossIncome: common.Baht(500000),
+			allowances: []Allowance{
+				{AllowanceType: AllowanceKReceipt, Amount: common.Baht(70000)},
+			},
+			expected: "50000",
+		},
+		{
+			name:        "Should cap sum of claims of the same type",
+			grossIncome: common.Baht(2000000),
+			allowances: []Allowance{
+				{AllowanceType: AllowanceDonation, Amount: common.Baht(80000)},
+				{AllowanceType: AllowanceDonation, Amount: common.Baht(80000)},
+			},
+			expected: "100000",
+		},
+		{
+			name:        "Should cap every claim on its own, given per-person allowance",
+			grossIncome: common.Baht(500000),
+			allowances: []Allowance{
+				{AllowanceType: AllowanceChild, Amount: common.Baht(40000)},
+				{AllowanceType: AllowanceChild, Amount: common.Baht(20000)},
+			},
+			expected: "50000",
+		},
+		{
+			name:        "Should cap allowance at percentage of gross income",
+			grossIncome: common.Baht(500000),
+			allowances: []Allowance{
+				{AllowanceType: AllowanceProvidentFund, Amount: common.Baht(100000)},
+			},
+			expected: "75000",
+		},
+		{
+			name:        "Should not round percentage of gross income",
+			grossIncome: common.Money(10000001),
+			allowances: []Allowance{
+				{AllowanceType: AllowanceProvidentFund, Amount: common.Baht(20000)},
+			},
+			expected: "15000.0015",
+		},
+		{
+			name:        "Should cap percentage of gross income at maximum amount",
+			grossIncome: common.Baht(5000000),
+			allowances: []Allowance{
+				{AllowanceType: AllowanceSSF, Amount: common.Baht(400000)},
+			},
+			expected: "200000",
+		},
+		{
+			name:        "Should cap life and health insurance at combined group cap",
+			grossIncome: common.Baht(500000),
+			allowances: []Allowance{
+				{AllowanceType: AllowanceLifeInsurance, Amount: common.Baht(90000)},
+				{AllowanceType: AllowanceHealthInsurance, Amount: common.Baht(25000)},
+			},
+			expected: "100000",
+		},
+		{
+			name:        "Should cap parent care at combined group cap",
+			grossIncome: common.Baht(500000),
+			allowances: []Allowance{
+				{AllowanceType: AllowanceParentCare, Amount: common.Baht(30000)},
+				{AllowanceType: AllowanceParentCare, Amount: common.Baht(30000)},
+				{AllowanceType: AllowanceParentCare, Amount: common.Baht(30000)},
+				{AllowanceType: AllowanceParentCare, Amount: common.Baht(30000)},
+				{AllowanceType: AllowanceParentCare, Amount: common.Baht(30000)},
+			},
+			expected: "120000",
+		},
+		{
+			name:        "Should cap retirement savings at combined group cap",
+			grossIncome: common.Baht(3000000),
+			allowances: []Allowance{
+				{AllowanceType: AllowanceProvidentFund, Amount: common.Baht(300000)},
+				{AllowanceType: AllowanceRMF, Amount: common.Baht(300000)},
+				{AllowanceType: AllowanceThaiESG, Amount: common.Baht(100000)},
+			},
+			expected: "600000",
+		},
+		{
+			name:        "Should sum allowances of different types",
+			grossIncome: common.Baht(500000),
+			allowances: []Allowance{
+				{AllowanceType: AllowanceSocialSecurity, Amount: common.Baht(9000)},
+				{AllowanceType: AllowanceHomeLoanInterest, Amount: common.Baht(120000)},
+				{AllowanceType: AllowanceDonation, Amount: common.Baht(1000)},
+			},
+			expected: "110000",
+		},
+		{
+			name:        "Should cap donation at rate of income after other deductions",
+			grossIncome: common.Baht(500000),
+			allowances: []Allowance{
+				{AllowanceType: AllowanceHomeLoanInterest, Amount: common.Baht(100000)},
+				{AllowanceType: AllowanceDonation, Amount: common.Baht(60000)},
+			},
+			expected: "140000",
+		},
+		{
+			name:        "Should count double donation twice",
+			grossIncome: common.Baht(1000000),
+			allowances: []Allowance{
+				{AllowanceType: AllowanceDoubleDonation, Amount: common.Baht(30000)},
+			},
+			expected: "60000",
+		},
+		{
+			name:        "Should cap donation and double donation at combined configured cap",
+			grossIncome: common.Baht(1000000),
+			allowances: []Allowance{
+				{AllowanceType: AllowanceDoubleDonation, Amount: common.Baht(40000)},
+				{AllowanceType: AllowanceDonation, Amount: common.Baht(50000)},
+			},
+			expected: "100000",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			registry := defaultAllowanceRegistry()
+
+			deductions, err := registry.deduct(allowanceContext{
+				grossIncome: tc.grossIncome,
+				netIncome:   tc.grossIncome.Decimal(),
+				rules:       rules,
+			}, tc.allowances)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, totalAllowanceDeduction(deductions).String())
+		})
+	}
+}
+
+func TestAllowanceRegistryDeductUnsupportedType(t *testing.T) {
+	registry := defaultAllowanceRegistry()
+
+	_, err := registry.deduct(allowanceContext{}, []Allowance{
+		{AllowanceType: "lottery", Amount: common.Baht(1000)},
+	})
+
+	var unsupportedAllowanceTypeError *UnsupportedAllowanceTypeError
+	require.ErrorAs(t, err, &unsupportedAllowanceTypeError)
+	require.Equal(t, AllowanceType("lottery"), unsupportedAllowanceTypeError.AllowanceType)
+	require.Contains(t, unsupportedAllowanceTypeError.SupportedTypes, AllowanceKReceipt)
+	require.Contains(t, err.Error(), "donation")
+}
+
+func TestAllowanceRegistryDeductDetails(t *testing.T) {
+	registry := defaultAllowanceRegistry()
+
+	deductions, err := registry.deduct(allowanceContext{
+		grossIncome: common.Baht(500000),
+		netIncome:   common.Baht(440000).Decimal(),
+		rules: taxRules{
+			maxDonationDeduction: common.Baht(100000),
+			donationIncomeRate:   decimal.RequireFromString("0.1"),
+		},
+	}, []Allowance{
+		{AllowanceType: AllowanceDonation, Amount: common.Baht(150000)},
+		{AllowanceType: AllowanceLifeInsurance, Amount: common.Baht(90000)},
+		{AllowanceType: AllowanceHealthInsurance, Amount: common.Baht(20000)},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, []allowanceDeduction{
+		{allowanceType: AllowanceLifeInsurance, requested: common.Baht(90000), maxDeduction: decimal.RequireFromString("100000"), applied: decimal.RequireFromString("90000")},
+		{allowanceType: AllowanceHealthInsurance, requested: common.Baht(20000), maxDeduction: decimal.RequireFromString("25000"), applied: decimal.RequireFromString("10000")},
+		{allowanceType: AllowanceDonation, requested: common.Baht(150000), maxDeduction: decimal.RequireFromString("34000"), applied: decimal.RequireFromString("34000"), rateBased: true, base: decimal.RequireFromString("340000"), rate: decimal.RequireFromString("0.1")},
+	}, normalizeDeductions(deductions))
+}
+
+// normalizeDeductions drops the trailing zeros of every amount of deductions, for
+// them to compare equal to amounts parsed from strings.
+func normalizeDeductions(deductions []allowanceDeduction) []allowanceDeduction {
+	normalize := func(d decimal.Decimal) decimal.Decimal {
+		if d.IsZero() {
+			return decimal.Decimal{}
+		}
+		return decimal.RequireFromString(d.String())
+	}
+
+	normalized := make([]allowanceDeduction, 0, len(deductions))
+	for _, v := range deductions {
+		v.maxDeduction = normalize(v.maxDeduction)
+		v.applied = normalize(v.applied)
+		v.base = normalize(v.base)
+		v.rate = normalize(v.rate)
+		normalized = append(normalized, v)
+	}
+
+	return normalized
+}
@@ -0,0 +1,134 @@
+package tax
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/chuckboliver/assessment-tax/common"
+	"github.com/shopspring/decimal"
+)
+
+var ErrInvalidTaxBrackets = errors.New("invalid tax brackets")
+
+type TaxBracket struct {
+	LowerBound common.Money    `json:"lowerBound" db:"lower_bound"`
+	UpperBound *common.Money   `json:"upperBound" db:"upper_bound"`
+	Rate       decimal.Decimal `json:"rate" db:"rate"`
+}
+
+func defaultTaxBrackets() []TaxBracket {
+	upperBound := func(baht int64) *common.Money {
+		v := common.Baht(baht)
+		return &v
+	}
+
+	return []TaxBracket{
+		{LowerBound: common.Baht(0), UpperBound: upperBound(150000), Rate: decimal.RequireFromString("0")},
+		{LowerBound: common.Baht(150000), UpperBound: upperBound(500000), Rate: decimal.RequireFromString("0.1")},
+		{LowerBound: common.Baht(500000), UpperBound: upperBound(1000000), Rate: decimal.RequireFromString("0.15")},
+		{LowerBound: common.Baht(1000000), UpperBound: upperBound(2000000), Rate: decimal.RequireFromString("0.2")},
+		{LowerBound: common.Baht(2000000), UpperBound: nil, Rate: decimal.RequireFromString("0.35")},
+	}
+}
+
+// Level returns the label of the bracket as shown in the tax level breakdown,
+// e.g. "150,001-500,000" or "2,000,001 ขึ้นไป" for the unbounded top bracket.
+func (b TaxBracket) Level() string {
+	lower := "0"
+	if b.LowerBound > 0 {
+		lower = formatThousands(b.LowerBound + common.Baht(1))
+	}
+
+	if b.UpperBound == nil {
+		return fmt.Sprintf("%s ขึ้นไป", lower)
+	}
+
+	return fmt.Sprintf("%s-%s", lower, formatThousands(*b.UpperBound))
+}
+
+// taxableIn returns the portion of income falling into this bracket.
+func (b TaxBracket) taxableIn(income decimal.Decimal) decimal.Decimal {
+	lowerBound := b.LowerBound.Decimal()
+	if income.LessThanOrEqual(lowerBound) {
+		return decimal.Zero
+	}
+
+	taxable := income
+	if b.UpperBound != nil {
+		taxable = decimal.Min(income, b.UpperBound.Decimal())
+	}
+
+	return taxable.Sub(lowerBound)
+}
+
+// taxOn returns the marginal tax of the portion of income falling into this bracket.
+// The result is not rounded.
+func (b TaxBracket) taxOn(income decimal.Decimal) decimal.Decimal {
+	return b.taxableIn(income).Mul(b.Rate)
+}
+
+// ValidateTaxBrackets checks that brackets start at zero, are contiguous and
+// non-overlapping, and end with a single unbounded bracket.
+func ValidateTaxBrackets(brackets []TaxBracket) error {
+	if len(brackets) == 0 {
+		return fmt.Errorf("%w: at least one bracket is required", ErrInvalidTaxBrackets)
+	}
+
+	sorted := make([]TaxBracket, len(brackets))
+	copy(sorted, brackets)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].LowerBound < sorted[j].LowerBound
+	})
+
+	if sorted[0].LowerBound != 0 {
+		return fmt.Errorf("%w: first bracket must start at 0", ErrInvalidTaxBrackets)
+	}
+
+	for i, bracket := range sorted {
+		if bracket.Rate.IsNegative() || bracket.Rate.GreaterThan(decimal.NewFromInt(1)) {
+			return fmt.Errorf("%w: rate of bracket %s must be between 0 and 1", ErrInvalidTaxBrackets, bracket.Level())
+		}
+
+		isLast := i == len(sorted)-1
+		if bracket.UpperBound == nil {
+			if !isLast {
+				return fmt.Errorf("%w: only the last bracket can be unbounded", ErrInvalidTaxBrackets)
+			}
+			continue
+		}
+
+		if *bracket.UpperBound <= bracket.LowerBound {
+			return fmt.Errorf("%w: upper bound of bracket %s must be greater than its lower bound", ErrInvalidTaxBrackets, bracket.Level())
+		}
+
+		if isLast {
+			return fmt.Errorf("%w: last bracket must be unbounded", ErrInvalidTaxBrackets)
+		}
+
+		next := sorted[i+1]
+		if next.LowerBound < *bracket.UpperBound {
+			return fmt.Errorf("%w: bracket %s overlaps bracket %s", ErrInvalidTaxBrackets, bracket.Level(), next.Level())
+		}
+		if next.LowerBound > *bracket.UpperBound {
+			return fmt.Errorf("%w: gap between bracket %s and bracket %s", ErrInvalidTaxBrackets, bracket.Level(), next.Level())
+		}
+	}
+
+	return nil
+}
+
+func formatThousands(v common.Money) string {
+	digits := v.Decimal().StringFixed(0)
+
+	var sb strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteRune(d)
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,123 @@
+package tax
+
+import (
+	"testing"
+
+	"github.com/chuckboliver/assessment-tax/common"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaxBracketLevel(t *testing.T) {
+	brackets := defaultTaxBrackets()
+
+	expected := []string{
+		"0-150,000",
+		"150,001-500,000",
+		"500,001-1,000,000",
+		"1,000,001-2,000,000",
+		"2,000,001 ขึ้นไป",
+	}
+
+	for i, bracket := range brackets {
+		require.Equal(t, expected[i], bracket.Level())
+	}
+}
+
+func TestValidateTaxBrackets(t *testing.T) {
+	upperBound := func(baht int64) *common.Money {
+		v := common.Baht(baht)
+		return &v
+	}
+
+	testCases := []struct {
+		name     string
+		brackets []TaxBracket
+		isValid  bool
+	}{
+		{
+			name:     "Should accept default brackets",
+			brackets: defaultTaxBrackets(),
+			isValid:  true,
+		},
+		{
+			name: "Should accept brackets given in any order",
+			brackets: []TaxBracket{
+				{LowerBound: common.Baht(100000), UpperBound: nil, Rate: decimal.RequireFromString("0.1")},
+				{LowerBound: 0, UpperBound: upperBound(100000), Rate: decimal.RequireFromString("0")},
+			},
+			isValid: true,
+		},
+		{
+			name:     "Should reject empty brackets",
+			brackets: []TaxBracket{},
+			isValid:  false,
+		},
+		{
+			name: "Should reject brackets not starting at 0",
+			brackets: []TaxBracket{
+				{LowerBound: common.Baht(1000), UpperBound: nil, Rate: decimal.RequireFromString("0.1")},
+			},
+			isValid: false,
+		},
+		{
+			name: "Should reject overlapping brackets",
+			brackets: []TaxBracket{
+				{LowerBound: 0, UpperBound: upperBound(150000), Rate: decimal.RequireFromString("0")},
+				{LowerBound: common.Baht(100000), UpperBound: nil, Rate: decimal.RequireFromString("0.1")},
+			},
+			isValid: false,
+		},
+		{
+			name: "Should reject brackets with gap",
+			brackets: []TaxBracket{
+				{LowerBound: 0, UpperBound: upperBound(150000), Rate: decimal.RequireFromString("0")},
+				{LowerBound: common.Baht(200000), UpperBound: nil, Rate: decimal.RequireFromString("0.1")},
+			},
+			isValid: false,
+		},
+		{
+			name: "Should reject brackets without unbounded top bracket",
+			brackets: []TaxBracket{
+				{LowerBound: 0, UpperBound: upperBound(150000), Rate: decimal.RequireFromString("0")},
+				{LowerBound: common.Baht(150000), UpperBound: upperBound(500000), Rate: decimal.RequireFromString("0.1")},
+			},
+			isValid: false,
+		},
+		{
+			name: "Should reject more than one unbounded bracket",
+			brackets: []TaxBracket{
+				{LowerBound: 0, UpperBound: nil, Rate: decimal.RequireFromString("0")},
+				{LowerBound: common.Baht(150000), UpperBound: nil, Rate: decimal.RequireFromString("0.1")},
+			},
+			isValid: false,
+		},
+		{
+			name: "Should reject upper bound not greater than lower bound",
+			brackets: []TaxBracket{
+				{LowerBound: 0, UpperBound: upperBound(0), Rate: decimal.RequireFromString("0")},
+				{LowerBound: 0, UpperBound: nil, Rate: decimal.RequireFromString("0.1")},
+			},
+			isValid: false,
+		},
+		{
+			name: "Should reject rate greater than 1",
+			brackets: []TaxBracket{
+				{LowerBound: 0, UpperBound: nil, Rate: decimal.RequireFromString("1.5")},
+			},
+			isValid: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateTaxBrackets(tc.brackets)
+			if tc.isValid {
+				require.NoError(t, err)
+				return
+			}
+
+			require.ErrorIs(t, err, ErrInvalidTaxBrackets)
+		})
+	}
+}
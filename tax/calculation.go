@@ -2,135 +2,377 @@ package tax
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 
 	"github.com/chuckboliver/assessment-tax/common"
+	"github.com/shopspring/decimal"
 )
 
-type Allowance struct {
-	AllowanceType AllowanceType `json:"allowanceType"`
-	Amount        float64       `json:"amount"`
-}
+const DefaultTaxYear = 2567
+
+var (
+	ErrUnknownTaxYear       = errors.New("unknown tax year")
+	ErrUnknownConfigVersion = errors.New("unknown config version")
+	// ErrConfigUnavailable is returned by a strict Calculator when the tax config
+	// cannot be read.
+	ErrConfigUnavailable = errors.New("tax config unavailable")
+)
 
-type AllowanceType string
+// ConfigFallback tells what a Calculator does when the tax config cannot be read,
+// e.g. while the database is down.
+type ConfigFallback string
 
 const (
-	AllowanceDonation AllowanceType = "donation"
-	AllowanceKReceipt AllowanceType = "k-receipt"
+	// ConfigFallbackStrict fails the calculation with ErrConfigUnavailable.
+	ConfigFallbackStrict ConfigFallback = "strict"
+	// ConfigFallbackLenient calculates with the built-in defaults, flagging the
+	// result with ConfigSourceDefault.
+	ConfigFallbackLenient ConfigFallback = "lenient"
 )
 
+type TaxMethod string
+
 const (
-	defaultPersonalDeduction    = 60000.0
-	defaultMaxKReceiptDeduction = 50000.0
+	TaxMethodProgressive TaxMethod = "progressive"
+	TaxMethodMinimum     TaxMethod = "minimum"
+)
+
+// The minimum tax is 0.5% of gross income other than salary, payable instead of the
+// progressive tax when higher, once that income exceeds 120,000.
+var (
+	minimumTaxRate            = decimal.RequireFromString("0.005")
+	minimumTaxIncomeThreshold = common.Baht(120000)
 )
 
+// CalculationResultWithTaxLevel is the result of a single calculation.
+// ConfigVersion is the version of the tax config the tax was calculated with,
+// which can be requested to calculate again with the same config. ConfigSource is
+// ConfigSourceDefault when the config could not be read and the built-in defaults
+// were used instead.
 type CalculationResultWithTaxLevel struct {
-	Tax       common.Float64 `json:"tax"`
-	TaxRefund common.Float64 `json:"taxRefund"`
-	TaxLevels []TaxLevel     `json:"taxLevel"`
+	TaxYear        int               `json:"taxYear"`
+	ConfigVersion  int               `json:"configVersion"`
+	ConfigSource   ConfigSource      `json:"configSource"`
+	Tax            common.Money      `json:"tax"`
+	TaxRefund      common.Money      `json:"taxRefund"`
+	ProgressiveTax common.Money      `json:"progressiveTax"`
+	MinimumTax     common.Money      `json:"minimumTax"`
+	TaxMethod      TaxMethod         `json:"taxMethod"`
+	TaxLevels      []TaxLevel        `json:"taxLevel"`
+	Incomes        []IncomeBreakdown `json:"incomes,omitempty"`
+	Trace          []TraceStep       `json:"trace,omitempty"`
 }
 
 type BatchCalculationResult struct {
 	Taxes []CalculationResult `json:"taxes"`
+	// Summary aggregates the results of every calculated row of an upload.
+	Summary *BatchSummary `json:"summary,omitempty"`
+	// SkippedRows and Errors are set for a partial upload, telling which rows of the
+	// file were not calculated and why.
+	SkippedRows []int      `json:"skippedRows,omitempty"`
+	Errors      []RowError `json:"errors,omitempty"`
 }
 
 type CalculationResult struct {
-	TotalIncome common.Float64 `json:"totalIncome"`
-	Tax         common.Float64 `json:"tax"`
-	TaxRefund   common.Float64 `json:"taxRefund"`
+	// Row is the line of the uploaded file the result was calculated from.
+	Row           int          `json:"row,omitempty"`
+	TaxYear       int          `json:"taxYear"`
+	ConfigVersion int          `json:"configVersion"`
+	ConfigSource  ConfigSource `json:"configSource"`
+	TotalIncome   common.Money `json:"totalIncome"`
+	Tax           common.Money `json:"tax"`
+	TaxRefund     common.Money `json:"taxRefund"`
+	// TaxLevels is the tax per bracket. Upload endpoints return it only when asked
+	// with ?includeTaxLevel=true, but always render it in CSV and PDF.
+	TaxLevels []TaxLevel `json:"taxLevel,omitempty"`
+}
+
+// BatchSummary aggregates the results of a batch. HighestBrackets counts the rows
+// by the highest bracket of their tax, in the order of the brackets.
+type BatchSummary struct {
+	TotalTax        common.Money   `json:"totalTax"`
+	TotalTaxRefund  common.Money   `json:"totalTaxRefund"`
+	HighestBrackets []BracketCount `json:"highestBrackets"`
+}
+
+type BracketCount struct {
+	Level string `json:"level"`
+	Count int    `json:"count"`
+}
+
+// newBatchSummary aggregates taxes. The highest bracket of a result is the last
+// bracket with tax, or the first bracket when there is no tax at all.
+func newBatchSummary(taxes []CalculationResult) *BatchSummary {
+	summary := &BatchSummary{
+		HighestBrackets: make([]BracketCount, 0),
+	}
+
+	indexByLevel := make(map[string]int)
+	for _, v := range taxes {
+		summary.TotalTax += v.Tax
+		summary.TotalTaxRefund += v.TaxRefund
+
+		highest := -1
+		for i, level := range v.TaxLevels {
+			if _, ok := indexByLevel[level.Level]; !ok {
+				indexByLevel[level.Level] = len(summary.HighestBrackets)
+				summary.HighestBrackets = append(summary.HighestBrackets, BracketCount{Level: level.Level})
+			}
+
+			if level.Tax > 0 || highest < 0 {
+				highest = i
+			}
+		}
+
+		if highest >= 0 {
+			summary.HighestBrackets[indexByLevel[v.TaxLevels[highest].Level]].Count++
+		}
+	}
+
+	return summary
+}
+
+// withoutTaxLevels drops the tax per bracket of results, which is left out of
+// batch results unless asked for.
+func withoutTaxLevels(results []CalculationResult) {
+	for i := range results {
+		results[i].TaxLevels = nil
+	}
 }
 
 type TaxLevel struct {
-	Level string         `json:"level"`
-	Tax   common.Float64 `json:"tax"`
+	Level string       `json:"level"`
+	Tax   common.Money `json:"tax"`
 }
 
 type TaxConfigRepository interface {
-	FindByName(ctx context.Context, name string) (*Config, error)
+	// FindSnapshot returns the config of taxYear at version, or at the latest
+	// version when version is 0. It returns ErrUnknownConfigVersion when there is
+	// no such version.
+	FindSnapshot(ctx context.Context, taxYear int, version int) (*ConfigSnapshot, error)
+	LatestVersion(ctx context.Context) (int, error)
 }
 
+// Calculator calculates tax with the tax config of TaxConfigRepository. Every
+// method returns ErrConfigUnavailable, wrapping the error of the repository, when
+// the config cannot be read and the fallback is strict.
 type Calculator interface {
-	Calculate(ctx context.Context, param calculationRequest) CalculationResultWithTaxLevel
-	BatchCalculate(ctx context.Context, params []calculationRequest) BatchCalculationResult
+	Calculate(ctx context.Context, param calculationRequest) (CalculationResultWithTaxLevel, error)
+	// BatchCalculate calculates every param with the same config version, the
+	// latest one unless a param asks for another.
+	BatchCalculate(ctx context.Context, params []calculationRequest) (BatchCalculationResult, error)
+	// LatestConfigVersion returns the current config version, for calculations of
+	// several batches to ask for the same one.
+	LatestConfigVersion(ctx context.Context) (int, error)
+	// CheckRules returns the error Calculate would return for the tax rules of
+	// taxYear at configVersion, without calculating anything.
+	CheckRules(ctx context.Context, taxYear int, configVersion int) error
 }
 
 var _ Calculator = (*CalculatorImpl)(nil)
 
 type CalculatorImpl struct {
 	taxConfigRepository TaxConfigRepository
+	configFallback      ConfigFallback
+	allowanceRegistry   *allowanceRegistry
 }
 
-func NewCalculator(taxConfigRepository TaxConfigRepository) Calculator {
+// NewCalculator returns a Calculator that is strict unless configFallback is
+// ConfigFallbackLenient.
+func NewCalculator(taxConfigRepository TaxConfigRepository, configFallback ConfigFallback) Calculator {
 	return &CalculatorImpl{
 		taxConfigRepository: taxConfigRepository,
+		configFallback:      configFallback,
+		allowanceRegistry:   defaultAllowanceRegistry(),
 	}
 }
 
-func (c *CalculatorImpl) calculate(personalDeduction float64, maxKReceiptDeduction float64, param calculationRequest) CalculationResultWithTaxLevel {
-	income := param.TotalIncome - personalDeduction
+// taxRules holds every year-dependent value needed to calculate tax.
+type taxRules struct {
+	taxYear              int
+	configVersion        int
+	configSource         ConfigSource
+	personalDeduction    common.Money
+	maxKReceiptDeduction common.Money
+	maxDonationDeduction common.Money
+	// donationIncomeRate caps donations at a rate of the income left after every
+	// other deduction.
+	donationIncomeRate decimal.Decimal
+	brackets           []TaxBracket
+	// configSources tells, per config name and for "brackets", whether the value
+	// was read from the database or is the built-in default.
+	configSources map[string]ConfigSource
+}
 
-	income = c.applyAllowances(income, param.Allowances, maxKReceiptDeduction)
+func (r taxRules) trace(t *tracer) {
+	t.record(TraceStep{Step: TraceStepConfig, Name: SettingPersonalDeduction.Key, Source: r.configSources[SettingPersonalDeduction.Key], Amount: traceMoney(r.personalDeduction)})
+	t.record(TraceStep{Step: TraceStepConfig, Name: SettingKReceiptDeduction.Key, Source: r.configSources[SettingKReceiptDeduction.Key], Amount: traceMoney(r.maxKReceiptDeduction)})
+	t.record(TraceStep{Step: TraceStepConfig, Name: SettingDonationDeduction.Key, Source: r.configSources[SettingDonationDeduction.Key], Amount: traceMoney(r.maxDonationDeduction)})
+	t.record(TraceStep{Step: TraceStepConfig, Name: SettingDonationIncomeRate.Key, Source: r.configSources[SettingDonationIncomeRate.Key], Rate: traceRate(r.donationIncomeRate)})
+	t.record(TraceStep{Step: TraceStepConfig, Name: "brackets", Source: r.configSources["brackets"]})
+}
 
-	taxLevels := createEmptyTaxLevels()
+// calculate keeps every intermediate amount, from the expense deductions and
+// allowance caps to the tax of each bracket, unrounded and rounds the payable tax
+// half-up to satang once at the end, so that the result does not depend on the
+// number of brackets. Each tax level, the income breakdown and the trace are
+// rounded the same way for display only.
+func (c *CalculatorImpl) calculate(rules taxRules, param calculationRequest) (CalculationResultWithTaxLevel, error) {
+	trace := newTracer(param.Explain)
+	rules.trace(trace)
 
-	tax := 0.0
+	assessment, err := assessIncome(param)
+	if err != nil {
+		return CalculationResultWithTaxLevel{}, err
+	}
 
-	if income > 2000000 {
-		currentLevelTax := (income - 2000000) * 0.35
-		taxLevels[4].Tax = common.Float64(currentLevelTax)
-		tax += currentLevelTax
+	trace.record(TraceStep{Step: TraceStepGrossIncome, Amount: traceMoney(assessment.grossIncome)})
+	for _, v := range assessment.breakdown {
+		trace.record(TraceStep{Step: TraceStepExpenseDeduction, Name: string(v.IncomeType), Base: traceMoney(v.Amount), Amount: traceMoney(v.ExpenseDeduction)})
 	}
 
-	if income > 1000000 {
-		currentLevelTax := (income - 1000000) * 0.2
-		taxLevels[3].Tax = common.Float64(currentLevelTax)
-		tax += currentLevelTax
+	trace.record(TraceStep{Step: TraceStepPersonalDeduction, Amount: traceMoney(rules.personalDeduction)})
+
+	allowanceDeductions, err := c.allowanceRegistry.deduct(allowanceContext{
+		grossIncome: assessment.grossIncome,
+		netIncome:   assessment.netIncome().Sub(rules.personalDeduction.Decimal()),
+		rules:       rules,
+	}, param.Allowances)
+	if err != nil {
+		return CalculationResultWithTaxLevel{}, err
 	}
 
-	if income > 500000 {
-		currentLevelTax := (income - 500000) * 0.15
-		taxLevels[2].Tax = common.Float64(currentLevelTax)
-		tax += currentLevelTax
+	for _, v := range allowanceDeductions {
+		step := TraceStep{Step: TraceStepAllowance, Name: string(v.allowanceType), Requested: traceMoney(v.requested), Cap: traceAmount(v.maxDeduction), Amount: traceAmount(v.applied)}
+		if v.rateBased {
+			step.Base = traceAmount(v.base)
+			step.Rate = traceRate(v.rate)
+		}
+		trace.record(step)
 	}
 
-	if income > 150000 {
-		currentLevelTax := (income - 150000) * 0.1
-		taxLevels[1].Tax = common.Float64(currentLevelTax)
-		tax += currentLevelTax
+	income := assessment.netIncome().Sub(rules.personalDeduction.Decimal()).Sub(totalAllowanceDeduction(allowanceDeductions))
+	trace.record(TraceStep{Step: TraceStepTaxableIncome, Amount: traceAmount(income)})
+
+	taxLevels := createEmptyTaxLevels(rules.brackets)
+
+	tax := decimal.Zero
+
+	for i, bracket := range rules.brackets {
+		currentLevelTax := bracket.taxOn(income)
+		taxLevels[i].Tax = common.RoundMoney(currentLevelTax)
+		tax = tax.Add(currentLevelTax)
+
+		trace.record(TraceStep{Step: TraceStepBracket, Name: bracket.Level(), Base: traceAmount(bracket.taxableIn(income)), Rate: traceRate(bracket.Rate), Amount: traceMoney(taxLevels[i].Tax)})
 	}
+	trace.record(TraceStep{Step: TraceStepProgressiveTax, Amount: traceMoney(common.RoundMoney(tax))})
+
+	taxMethod := TaxMethodProgressive
+	minimumTax := minimumTaxOf(assessment)
+	if !minimumTax.IsZero() {
+		trace.record(TraceStep{Step: TraceStepMinimumTax, Base: traceMoney(assessment.nonSalaryIncome), Rate: traceRate(minimumTaxRate), Amount: traceMoney(common.RoundMoney(minimumTax))})
+	}
+
+	payableTax := tax
+	if minimumTax.GreaterThan(tax) {
+		taxMethod = TaxMethodMinimum
+		payableTax = minimumTax
+	}
+
+	netTax := common.RoundMoney(payableTax.Sub(param.Wht.Decimal()))
+	trace.record(TraceStep{Step: TraceStepWht, Amount: traceMoney(param.Wht)})
 
-	tax -= param.Wht
-	taxRefund := 0.0
-	if tax < 0 {
-		taxRefund = -tax
-		tax = 0
+	taxRefund := common.Money(0)
+	if netTax < 0 {
+		taxRefund = -netTax
+		netTax = 0
 	}
 
+	trace.record(TraceStep{Step: TraceStepResult, Name: "tax", Amount: traceMoney(netTax)})
+	trace.record(TraceStep{Step: TraceStepResult, Name: "taxRefund", Amount: traceMoney(taxRefund)})
+
 	return CalculationResultWithTaxLevel{
-		Tax:       common.Float64(tax),
-		TaxRefund: common.Float64(taxRefund),
-		TaxLevels: taxLevels,
+		TaxYear:        rules.taxYear,
+		ConfigVersion:  rules.configVersion,
+		ConfigSource:   rules.configSource,
+		Tax:            netTax,
+		TaxRefund:      taxRefund,
+		ProgressiveTax: common.RoundMoney(tax),
+		MinimumTax:     common.RoundMoney(minimumTax),
+		TaxMethod:      taxMethod,
+		TaxLevels:      taxLevels,
+		Incomes:        assessment.breakdown,
+		Trace:          trace.result(),
+	}, nil
+}
+
+// minimumTaxOf returns the unrounded minimum tax, or zero when income other than
+// salary does not exceed the threshold.
+func minimumTaxOf(assessment incomeAssessment) decimal.Decimal {
+	if assessment.nonSalaryIncome <= minimumTaxIncomeThreshold {
+		return decimal.Zero
 	}
+
+	return assessment.nonSalaryIncome.Decimal().Mul(minimumTaxRate)
 }
 
-func (c *CalculatorImpl) Calculate(ctx context.Context, param calculationRequest) CalculationResultWithTaxLevel {
-	personalDeduction := c.getPersonalDeduction(ctx)
-	maxKReceiptDeduction := c.getMaxKReceiptDeduction(ctx)
-	return c.calculate(personalDeduction, maxKReceiptDeduction, param)
+func (c *CalculatorImpl) Calculate(ctx context.Context, param calculationRequest) (CalculationResultWithTaxLevel, error) {
+	rules, err := c.getTaxRules(ctx, param.taxYear(), param.ConfigVersion)
+	if err != nil {
+		return CalculationResultWithTaxLevel{}, err
+	}
+
+	return c.calculate(rules, param)
 }
 
-func (c *CalculatorImpl) BatchCalculate(ctx context.Context, params []calculationRequest) BatchCalculationResult {
-	personalDeduction := c.getPersonalDeduction(ctx)
-	maxKReceiptDeduction := c.getMaxKReceiptDeduction(ctx)
+// BatchCalculate loads the rules of every tax year and config version once. The
+// params without a config version use the version the first rules were read at,
+// so that a config change in the middle of the batch is not seen by its later
+// tax years.
+func (c *CalculatorImpl) BatchCalculate(ctx context.Context, params []calculationRequest) (BatchCalculationResult, error) {
+	type rulesKey struct {
+		taxYear       int
+		configVersion int
+	}
+	rulesByKey := make(map[rulesKey]taxRules)
+	latestVersion := 0
 
 	calculationResults := make([]CalculationResult, 0, len(params))
 	for _, v := range params {
-		calculationResultWithTaxLevel := c.calculate(personalDeduction, maxKReceiptDeduction, v)
+		key := rulesKey{taxYear: v.taxYear(), configVersion: v.ConfigVersion}
+		if key.configVersion == 0 {
+			key.configVersion = latestVersion
+		}
+
+		rules, ok := rulesByKey[key]
+		if !ok {
+			var err error
+			rules, err = c.getTaxRules(ctx, key.taxYear, key.configVersion)
+			if err != nil {
+				return BatchCalculationResult{}, err
+			}
+
+			if latestVersion == 0 && v.ConfigVersion == 0 {
+				latestVersion = rules.configVersion
+			}
+			rulesByKey[rulesKey{taxYear: key.taxYear, configVersion: rules.configVersion}] = rules
+		}
+
+		calculationResultWithTaxLevel, err := c.calculate(rules, v)
+		if err != nil {
+			return BatchCalculationResult{}, err
+		}
 
 		calculationResult := CalculationResult{
-			TotalIncome: common.Float64(v.TotalIncome),
-			Tax:         calculationResultWithTaxLevel.Tax,
-			TaxRefund:   calculationResultWithTaxLevel.TaxRefund,
+			TaxYear:       calculationResultWithTaxLevel.TaxYear,
+			ConfigVersion: calculationResultWithTaxLevel.ConfigVersion,
+			ConfigSource:  calculationResultWithTaxLevel.ConfigSource,
+			TotalIncome:   v.grossIncome(),
+			Tax:           calculationResultWithTaxLevel.Tax,
+			TaxRefund:     calculationResultWithTaxLevel.TaxRefund,
+			TaxLevels:     calculationResultWithTaxLevel.TaxLevels,
 		}
 
 		calculationResults = append(calculationResults, calculationResult)
@@ -138,64 +380,106 @@ func (c *CalculatorImpl) BatchCalculate(ctx context.Context, params []calculatio
 
 	return BatchCalculationResult{
 		Taxes: calculationResults,
-	}
+	}, nil
 }
 
-func (c *CalculatorImpl) getPersonalDeduction(ctx context.Context) float64 {
-	config, err := c.taxConfigRepository.FindByName(ctx, "personal_deduction")
+// LatestConfigVersion returns 0, asking for the latest version whichever it is,
+// when the version cannot be read and the fallback is lenient.
+func (c *CalculatorImpl) LatestConfigVersion(ctx context.Context) (int, error) {
+	version, err := c.taxConfigRepository.LatestVersion(ctx)
 	if err != nil {
-		slog.Error("failed to get personal deduction", err)
-		return defaultPersonalDeduction
+		if c.configFallback != ConfigFallbackLenient {
+			return 0, fmt.Errorf("%w: %w", ErrConfigUnavailable, err)
+		}
+
+		slog.Warn("failed to get tax config version", "err", err)
+		return 0, nil
 	}
 
-	return config.Value
+	return version, nil
 }
 
-func (c *CalculatorImpl) getMaxKReceiptDeduction(ctx context.Context) float64 {
-	config, err := c.taxConfigRepository.FindByName(ctx, "kreceipt_deduction")
-	if err != nil {
-		slog.Error("failed to get max kreceipt deduction", err)
-		return defaultMaxKReceiptDeduction
+func (c *CalculatorImpl) CheckRules(ctx context.Context, taxYear int, configVersion int) error {
+	_, err := c.getTaxRules(ctx, taxYear, configVersion)
+	return err
+}
+
+// getTaxRules reads the config of taxYear at configVersion, or at the latest
+// version when configVersion is 0, from a single snapshot.
+func (c *CalculatorImpl) getTaxRules(ctx context.Context, taxYear int, configVersion int) (taxRules, error) {
+	snapshot, err := c.taxConfigRepository.FindSnapshot(ctx, taxYear, configVersion)
+	switch {
+	case errors.Is(err, ErrUnknownConfigVersion):
+		return taxRules{}, err
+	case err != nil:
+		if c.configFallback != ConfigFallbackLenient {
+			return taxRules{}, fmt.Errorf("%w: %w", ErrConfigUnavailable, err)
+		}
+
+		slog.Warn("failed to get tax config, calculating with defaults", "taxYear", taxYear, "configVersion", configVersion, "err", err)
+		return defaultTaxRules(taxYear), nil
+	case len(snapshot.Brackets) == 0:
+		return taxRules{}, fmt.Errorf("%w: %d", ErrUnknownTaxYear, taxYear)
 	}
 
-	return config.Value
+	rules := taxRules{
+		taxYear:       taxYear,
+		configVersion: snapshot.Version,
+		configSource:  ConfigSourceDatabase,
+		brackets:      snapshot.Brackets,
+		configSources: map[string]ConfigSource{"brackets": ConfigSourceDatabase},
+	}
+
+	rules.personalDeduction = getConfigValue(snapshot, rules, SettingPersonalDeduction).Money()
+	rules.maxKReceiptDeduction = getConfigValue(snapshot, rules, SettingKReceiptDeduction).Money()
+	rules.maxDonationDeduction = getConfigValue(snapshot, rules, SettingDonationDeduction).Money()
+	rules.donationIncomeRate = getConfigValue(snapshot, rules, SettingDonationIncomeRate).Rate()
+
+	return rules, nil
 }
 
-func (c *CalculatorImpl) applyAllowances(income float64, allowances []Allowance, maxKReceiptDeduction float64) float64 {
+// defaultTaxRules are the built-in rules, used when the config cannot be read and
+// the fallback is lenient. They have no config version.
+func defaultTaxRules(taxYear int) taxRules {
+	return taxRules{
+		taxYear:              taxYear,
+		configSource:         ConfigSourceDefault,
+		personalDeduction:    SettingPersonalDeduction.Default.Money(),
+		maxKReceiptDeduction: SettingKReceiptDeduction.Default.Money(),
+		maxDonationDeduction: SettingDonationDeduction.Default.Money(),
+		donationIncomeRate:   SettingDonationIncomeRate.Default.Rate(),
+		brackets:             defaultTaxBrackets(),
+		configSources: map[string]ConfigSource{
+			SettingPersonalDeduction.Key:  ConfigSourceDefault,
+			SettingKReceiptDeduction.Key:  ConfigSourceDefault,
+			SettingDonationDeduction.Key:  ConfigSourceDefault,
+			SettingDonationIncomeRate.Key: ConfigSourceDefault,
+			"brackets":                    ConfigSourceDefault,
+		},
+	}
+}
 
-	for _, v := range allowances {
-		switch v.AllowanceType {
-		case AllowanceDonation:
-			income -= min(v.Amount, 100000)
-		case AllowanceKReceipt:
-			income -= min(v.Amount, maxKReceiptDeduction)
-		}
+// getConfigValue returns the value of setting in snapshot, or its default when it
+// is not configured, and records its source in rules.
+func getConfigValue(snapshot *ConfigSnapshot, rules taxRules, setting Setting) ConfigValue {
+	value, ok := snapshot.Values[setting.Key]
+	if !ok {
+		rules.configSources[setting.Key] = ConfigSourceDefault
+		return setting.Default
 	}
 
-	return income
+	rules.configSources[setting.Key] = ConfigSourceDatabase
+	return value
 }
 
-func createEmptyTaxLevels() []TaxLevel {
-	return []TaxLevel{
-		{
-			Level: "0-150,000",
+func createEmptyTaxLevels(brackets []TaxBracket) []TaxLevel {
+	taxLevels := make([]TaxLevel, 0, len(brackets))
+	for _, bracket := range brackets {
+		taxLevels = append(taxLevels, TaxLevel{
+			Level: bracket.Level(),
 			Tax:   0,
-		},
-		{
-			Level: "150,001-500,000",
-			Tax:   0,
-		},
-		{
-			Level: "500,001-1,000,000",
-			Tax:   0,
-		},
-		{
-			Level: "1,000,001-2,000,000",
-			Tax:   0,
-		},
-		{
-			Level: "2,000,001 ขึ้นไป",
-			Tax:   0,
-		},
+		})
 	}
+
+	return taxLevels
 }
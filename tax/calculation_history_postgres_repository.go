@@ -0,0 +1,133 @@
+package tax
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type database interface {
+	sqlx.ExtContext
+	BeginTxx(ctx context.Context, opts *sql.TxOptions) (*sqlx.Tx, error)
+}
+
+var _ CalculationHistoryRepository = (*calculationHistoryPostgresRepository)(nil)
+
+type calculationHistoryPostgresRepository struct {
+	db database
+}
+
+func NewCalculationHistoryPostgresRepository(db database) CalculationHistoryRepository {
+	return &calculationHistoryPostgresRepository{
+		db: db,
+	}
+}
+
+type calculationRow struct {
+	ID         string         `db:"id"`
+	TaxpayerID sql.NullString `db:"taxpayer_id"`
+	Source     string         `db:"source"`
+	Request    []byte         `db:"request"`
+	Response   []byte         `db:"response"`
+	CreatedAt  time.Time      `db:"created_at"`
+}
+
+func (r calculationRow) toRecord() CalculationRecord {
+	return CalculationRecord{
+		ID:         r.ID,
+		TaxpayerID: r.TaxpayerID.String,
+		Source:     CalculationSource(r.Source),
+		Request:    r.Request,
+		Response:   r.Response,
+		CreatedAt:  r.CreatedAt,
+	}
+}
+
+// Save stores records in a single transaction and returns them with their
+// generated ID and creation time.
+func (r *calculationHistoryPostgresRepository) Save(ctx context.Context, records []CalculationRecord) ([]CalculationRecord, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	insertSQL := `
+		INSERT INTO calculations (taxpayer_id, source, request, response)
+		VALUES (NULLIF($1, ''), $2, $3, $4)
+		RETURNING id, created_at
+	`
+
+	savedRecords := make([]CalculationRecord, 0, len(records))
+	for _, record := range records {
+		row := tx.QueryRowxContext(ctx, insertSQL, record.TaxpayerID, record.Source, []byte(record.Request), []byte(record.Response))
+		if err := row.Scan(&record.ID, &record.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		savedRecords = append(savedRecords, record)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return savedRecords, nil
+}
+
+func (r *calculationHistoryPostgresRepository) FindByID(ctx context.Context, id string) (*CalculationRecord, error) {
+	selectSQL := `
+		SELECT id, taxpayer_id, source, request, response, created_at
+		FROM calculations
+		WHERE id = $1
+	`
+
+	var row calculationRow
+	if err := sqlx.GetContext(ctx, r.db, &row, selectSQL, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrCalculationNotFound
+		}
+		return nil, err
+	}
+
+	record := row.toRecord()
+	return &record, nil
+}
+
+// FindAll returns a page of the calculations of a taxpayer, newest first, and the
+// number of calculations of the taxpayer.
+func (r *calculationHistoryPostgresRepository) FindAll(ctx context.Context, filter CalculationFilter) ([]CalculationRecord, int, error) {
+	countSQL := `
+		SELECT count(*)
+		FROM calculations
+		WHERE taxpayer_id = $1
+	`
+
+	var total int
+	if err := sqlx.GetContext(ctx, r.db, &total, countSQL, filter.TaxpayerID); err != nil {
+		return nil, 0, err
+	}
+
+	selectSQL := `
+		SELECT id, taxpayer_id, source, request, response, created_at
+		FROM calculations
+		WHERE taxpayer_id = $1
+		ORDER BY created_at DESC, id
+		LIMIT $2 OFFSET $3
+	`
+
+	rows := make([]calculationRow, 0)
+	if err := sqlx.SelectContext(ctx, r.db, &rows, selectSQL, filter.TaxpayerID, filter.Limit, filter.Offset); err != nil {
+		return nil, 0, err
+	}
+
+	records := make([]CalculationRecord, 0, len(rows))
+	for _, v := range rows {
+		records = append(records, v.toRecord())
+	}
+
+	return records, total, nil
+}
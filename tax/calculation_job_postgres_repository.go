@@ -0,0 +1,336 @@
+package tax
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var _ CalculationJobRepository = (*calculationJobPostgresRepository)(nil)
+
+type calculationJobPostgresRepository struct {
+	db database
+}
+
+func NewCalculationJobPostgresRepository(db database) CalculationJobRepository {
+	return &calculationJobPostgresRepository{
+		db: db,
+	}
+}
+
+type calculationJobRow struct {
+	ID            string         `db:"id"`
+	Status        string         `db:"status"`
+	Filename      string         `db:"filename"`
+	ContentType   string         `db:"content_type"`
+	Partial       bool           `db:"partial"`
+	ConfigVersion int            `db:"config_version"`
+	File          []byte         `db:"file"`
+	TotalRows     int            `db:"total_rows"`
+	ProcessedRows int            `db:"processed_rows"`
+	FailedRows    int            `db:"failed_rows"`
+	Errors        []byte         `db:"errors"`
+	Error         sql.NullString `db:"error"`
+	LockedBy      sql.NullString `db:"locked_by"`
+	CreatedAt     time.Time      `db:"created_at"`
+	UpdatedAt     time.Time      `db:"updated_at"`
+}
+
+func (r calculationJobRow) toJob() (CalculationJob, error) {
+	job := CalculationJob{
+		ID:            r.ID,
+		Status:        JobStatus(r.Status),
+		Filename:      r.Filename,
+		Partial:       r.Partial,
+		ConfigVersion: r.ConfigVersion,
+		TotalRows:     r.TotalRows,
+		ProcessedRows: r.ProcessedRows,
+		FailedRows:    r.FailedRows,
+		Error:         r.Error.String,
+		CreatedAt:     r.CreatedAt,
+		UpdatedAt:     r.UpdatedAt,
+		ContentType:   r.ContentType,
+		File:          r.File,
+		LeaseOwner:    r.LockedBy.String,
+	}
+
+	if r.Errors != nil {
+		if err := json.Unmarshal(r.Errors, &job.Errors); err != nil {
+			return CalculationJob{}, err
+		}
+
+		// The errors of rows that could not be calculated are stored after those
+		// of the invalid rows.
+		job.Errors = sortRowErrors(job.Errors)
+	}
+
+	return job, nil
+}
+
+func (r *calculationJobPostgresRepository) Create(ctx context.Context, job CalculationJob) (CalculationJob, error) {
+	insertSQL := `
+		INSERT INTO calculation_jobs (status, filename, content_type, partial, config_version, file)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at
+	`
+
+	row := r.db.QueryRowxContext(ctx, insertSQL, job.Status, job.Filename, job.ContentType, job.Partial, job.ConfigVersion, job.File)
+	if err := row.Scan(&job.ID, &job.CreatedAt, &job.UpdatedAt); err != nil {
+		return CalculationJob{}, err
+	}
+
+	return job, nil
+}
+
+// FindByID returns a job without its file.
+func (r *calculationJobPostgresRepository) FindByID(ctx context.Context, id string) (*CalculationJob, error) {
+	selectSQL := `
+		SELECT id, status, filename, content_type, partial, config_version, NULL AS file, total_rows, processed_rows, failed_rows, errors, error, NULL AS locked_by, created_at, updated_at
+		FROM calculation_jobs
+		WHERE id = $1
+	`
+
+	var row calculationJobRow
+	if err := sqlx.GetContext(ctx, r.db, &row, selectSQL, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrJobNotFound
+		}
+		return nil, err
+	}
+
+	job, err := row.toJob()
+	if err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+func (r *calculationJobPostgresRepository) FindFile(ctx context.Context, id string) ([]byte, error) {
+	selectSQL := `
+		SELECT file
+		FROM calculation_jobs
+		WHERE id = $1
+	`
+
+	var file []byte
+	if err := r.db.QueryRowxContext(ctx, selectSQL, id).Scan(&file); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrJobNotFound
+		}
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// Claim locks the job with SKIP LOCKED so that workers of several servers never
+// take the same job, and gives the lease a new owner so that the worker whose
+// lease expired can no longer change the job.
+func (r *calculationJobPostgresRepository) Claim(ctx context.Context, lease time.Duration) (*CalculationJob, error) {
+	updateSQL := `
+		UPDATE calculation_jobs
+		SET status = 'running', locked_by = gen_random_uuid(), locked_until = now() + make_interval(secs => $1), updated_at = now()
+		WHERE id = (
+			SELECT id
+			FROM calculation_jobs
+			WHERE status = 'pending' OR (status = 'running' AND locked_until < now())
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, status, filename, content_type, partial, config_version, file, total_rows, processed_rows, failed_rows, errors, error, locked_by, created_at, updated_at
+	`
+
+	var row calculationJobRow
+	if err := sqlx.GetContext(ctx, r.db, &row, updateSQL, lease.Seconds()); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoPendingJob
+		}
+		return nil, err
+	}
+
+	job, err := row.toJob()
+	if err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+func (r *calculationJobPostgresRepository) Start(ctx context.Context, id string, owner string, totalRows int, configVersion int, rowErrors []RowError) error {
+	updateSQL := `
+		UPDATE calculation_jobs
+		SET total_rows = $3, config_version = $4, failed_rows = $5, errors = $6, updated_at = now()
+		WHERE id = $1 AND locked_by = $2
+	`
+
+	rowErrorsJSON, err := marshalRowErrors(rowErrors)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.db.ExecContext(ctx, updateSQL, id, owner, totalRows, configVersion, countRows(rowErrors), rowErrorsJSON)
+	if err != nil {
+		return err
+	}
+
+	return leaseHeld(result, id)
+}
+
+// SaveResults stores results and the progress of the job in a single transaction,
+// so that a resumed job starts right after the last stored result. Only the
+// results that were not stored yet count as progress.
+func (r *calculationJobPostgresRepository) SaveResults(ctx context.Context, id string, owner string, results []CalculationResult, rowErrors []RowError, lease time.Duration) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	insertSQL := `
+		INSERT INTO calculation_job_results (job_id, row, result)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (job_id, row) DO NOTHING
+	`
+
+	inserted := int64(0)
+	for _, v := range results {
+		result, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+
+		insertResult, err := tx.ExecContext(ctx, insertSQL, id, v.Row, result)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err := insertResult.RowsAffected()
+		if err != nil {
+			return err
+		}
+		inserted += rowsAffected
+	}
+
+	rowErrorsJSON, err := marshalRowErrors(rowErrors)
+	if err != nil {
+		return err
+	}
+
+	// The rows that could not be calculated are processed as well, so that a
+	// resumed job does not calculate them again.
+	updateSQL := `
+		UPDATE calculation_jobs
+		SET
+			processed_rows = processed_rows + $3,
+			failed_rows = failed_rows + $4,
+			errors = CASE WHEN $5::jsonb IS NULL THEN errors ELSE COALESCE(errors, '[]'::jsonb) || $5::jsonb END,
+			locked_until = now() + make_interval(secs => $6),
+			updated_at = now()
+		WHERE id = $1 AND locked_by = $2
+	`
+
+	result, err := tx.ExecContext(ctx, updateSQL, id, owner, inserted+int64(len(rowErrors)), countRows(rowErrors), rowErrorsJSON, lease.Seconds())
+	if err != nil {
+		return err
+	}
+
+	if err := leaseHeld(result, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *calculationJobPostgresRepository) FindResults(ctx context.Context, id string) ([]CalculationResult, error) {
+	selectSQL := `
+		SELECT result
+		FROM calculation_job_results
+		WHERE job_id = $1
+		ORDER BY row
+	`
+
+	rows := make([][]byte, 0)
+	if err := sqlx.SelectContext(ctx, r.db, &rows, selectSQL, id); err != nil {
+		return nil, err
+	}
+
+	results := make([]CalculationResult, 0, len(rows))
+	for _, v := range rows {
+		var result CalculationResult
+		if err := json.Unmarshal(v, &result); err != nil {
+			return nil, err
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// Complete marks a job completed. Its file is kept for the result to be returned
+// with the columns of the file.
+func (r *calculationJobPostgresRepository) Complete(ctx context.Context, id string, owner string) error {
+	updateSQL := `
+		UPDATE calculation_jobs
+		SET status = 'completed', locked_by = NULL, locked_until = NULL, updated_at = now()
+		WHERE id = $1 AND locked_by = $2
+	`
+
+	result, err := r.db.ExecContext(ctx, updateSQL, id, owner)
+	if err != nil {
+		return err
+	}
+
+	return leaseHeld(result, id)
+}
+
+func (r *calculationJobPostgresRepository) Fail(ctx context.Context, id string, owner string, message string, rowErrors []RowError) error {
+	updateSQL := `
+		UPDATE calculation_jobs
+		SET status = 'failed', error = $3, errors = $4, failed_rows = $5, file = NULL, locked_by = NULL, locked_until = NULL, updated_at = now()
+		WHERE id = $1 AND locked_by = $2
+	`
+
+	rowErrorsJSON, err := marshalRowErrors(rowErrors)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.db.ExecContext(ctx, updateSQL, id, owner, message, rowErrorsJSON, countRows(rowErrors))
+	if err != nil {
+		return err
+	}
+
+	return leaseHeld(result, id)
+}
+
+// leaseHeld returns ErrJobLeaseLost when an update of the job, conditioned on
+// the owner of its lease, changed nothing.
+func leaseHeld(result sql.Result, id string) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%w: %s", ErrJobLeaseLost, id)
+	}
+
+	return nil
+}
+
+// marshalRowErrors returns nil, stored as NULL, when there is no row error.
+func marshalRowErrors(rowErrors []RowError) ([]byte, error) {
+	if len(rowErrors) == 0 {
+		return nil, nil
+	}
+
+	return json.Marshal(rowErrors)
+}
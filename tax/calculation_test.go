@@ -2,36 +2,39 @@ package tax
 
 import (
 	"context"
+	"errors"
 	"testing"
 
+	"github.com/chuckboliver/assessment-tax/common"
 	"github.com/golang/mock/gomock"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/require"
 )
 
 func TestCalculateTax(t *testing.T) {
-	taxLevels1 := createEmptyTaxLevels()
-	taxLevels1[1].Tax = 29000
+	taxLevels1 := createEmptyTaxLevels(defaultTaxBrackets())
+	taxLevels1[1].Tax = common.Baht(29000)
 
-	taxLevels2 := createEmptyTaxLevels()
-	taxLevels2[1].Tax = 29000
+	taxLevels2 := createEmptyTaxLevels(defaultTaxBrackets())
+	taxLevels2[1].Tax = common.Baht(29000)
 
-	taxLevels3 := createEmptyTaxLevels()
-	taxLevels3[1].Tax = 19000
+	taxLevels3 := createEmptyTaxLevels(defaultTaxBrackets())
+	taxLevels3[1].Tax = common.Baht(24600)
 
-	taxLevels4 := createEmptyTaxLevels()
-	taxLevels4[1].Tax = 20000
+	taxLevels4 := createEmptyTaxLevels(defaultTaxBrackets())
+	taxLevels4[1].Tax = common.Baht(25000)
 
-	taxLevels5 := createEmptyTaxLevels()
-	taxLevels5[1].Tax = 22500
+	taxLevels5 := createEmptyTaxLevels(defaultTaxBrackets())
+	taxLevels5[1].Tax = common.Baht(26850)
 
-	taxLevels6 := createEmptyTaxLevels()
-	taxLevels6[1].Tax = 20000
+	taxLevels6 := createEmptyTaxLevels(defaultTaxBrackets())
+	taxLevels6[1].Tax = common.Baht(24600)
 
-	taxLevels7 := createEmptyTaxLevels()
-	taxLevels7[1].Tax = 17000
+	taxLevels7 := createEmptyTaxLevels(defaultTaxBrackets())
+	taxLevels7[1].Tax = common.Baht(21900)
 
-	taxLevels8 := createEmptyTaxLevels()
-	taxLevels8[1].Tax = 15000
+	taxLevels8 := createEmptyTaxLevels(defaultTaxBrackets())
+	taxLevels8[1].Tax = common.Baht(20100)
 
 	testCases := []struct {
 		name              string
@@ -42,7 +45,7 @@ func TestCalculateTax(t *testing.T) {
 		{
 			name: "Should calculate tax correctly, given only total income",
 			arg: calculationRequest{
-				TotalIncome: 500000,
+				TotalIncome: common.Baht(500000),
 				Wht:         0,
 				Allowances: []Allowance{
 					{
@@ -53,23 +56,21 @@ func TestCalculateTax(t *testing.T) {
 			},
 			taxConfigRepoStub: func(taxConfigRepo *MockTaxConfigRepository) {
 				taxConfigRepo.EXPECT().
-					FindByName(gomock.Any(), "personal_deduction").
+					FindSnapshot(gomock.Any(), 2567, 0).
 					Times(1).
-					Return(&Config{
-						Name:  "personal_deduction",
-						Value: 60000.0,
-					}, nil)
-
-				taxConfigRepo.EXPECT().
-					FindByName(gomock.Any(), "kreceipt_deduction").
-					Times(1).
-					Return(&Config{
-						Name:  "kreceipt_deduction",
-						Value: 50000.0,
+					Return(&ConfigSnapshot{
+						Version: 1,
+						TaxYear: 2567,
+						Values: map[string]ConfigValue{
+							"personal_deduction": NewConfigValue(60000),
+							"kreceipt_deduction": NewConfigValue(50000),
+							"donation_deduction": NewConfigValue(100000),
+						},
+						Brackets: defaultTaxBrackets(),
 					}, nil)
 			},
 			expected: CalculationResultWithTaxLevel{
-				Tax:       29000,
+				Tax:       common.Baht(29000),
 				TaxRefund: 0,
 				TaxLevels: taxLevels1,
 			},
@@ -77,8 +78,8 @@ func TestCalculateTax(t *testing.T) {
 		{
 			name: "Should calculate tax correctly, given total income and withholding tax",
 			arg: calculationRequest{
-				TotalIncome: 500000,
-				Wht:         25000,
+				TotalIncome: common.Baht(500000),
+				Wht:         common.Baht(25000),
 				Allowances: []Allowance{
 					{
 						AllowanceType: AllowanceDonation,
@@ -88,93 +89,87 @@ func TestCalculateTax(t *testing.T) {
 			},
 			taxConfigRepoStub: func(taxConfigRepo *MockTaxConfigRepository) {
 				taxConfigRepo.EXPECT().
-					FindByName(gomock.Any(), "personal_deduction").
-					Times(1).
-					Return(&Config{
-						Name:  "personal_deduction",
-						Value: 60000.0,
-					}, nil)
-
-				taxConfigRepo.EXPECT().
-					FindByName(gomock.Any(), "kreceipt_deduction").
+					FindSnapshot(gomock.Any(), 2567, 0).
 					Times(1).
-					Return(&Config{
-						Name:  "kreceipt_deduction",
-						Value: 50000.0,
+					Return(&ConfigSnapshot{
+						Version: 1,
+						TaxYear: 2567,
+						Values: map[string]ConfigValue{
+							"personal_deduction": NewConfigValue(60000),
+							"kreceipt_deduction": NewConfigValue(50000),
+							"donation_deduction": NewConfigValue(100000),
+						},
+						Brackets: defaultTaxBrackets(),
 					}, nil)
 			},
 			expected: CalculationResultWithTaxLevel{
-				Tax:       4000,
+				Tax:       common.Baht(4000),
 				TaxRefund: 0,
 				TaxLevels: taxLevels2,
 			},
 		},
 		{
-			name: "Should calculate tax correctly, given total income and donation (over allowance limit of 100000)",
+			name: "Should calculate tax correctly, given total income and donation (over 10% of income after deductions)",
 			arg: calculationRequest{
-				TotalIncome: 500000,
+				TotalIncome: common.Baht(500000),
 				Wht:         0,
 				Allowances: []Allowance{
 					{
 						AllowanceType: AllowanceDonation,
-						Amount:        200000,
+						Amount:        common.Baht(200000),
 					},
 				},
 			},
 			taxConfigRepoStub: func(taxConfigRepo *MockTaxConfigRepository) {
 				taxConfigRepo.EXPECT().
-					FindByName(gomock.Any(), "personal_deduction").
-					Times(1).
-					Return(&Config{
-						Name:  "personal_deduction",
-						Value: 60000.0,
-					}, nil)
-
-				taxConfigRepo.EXPECT().
-					FindByName(gomock.Any(), "kreceipt_deduction").
+					FindSnapshot(gomock.Any(), 2567, 0).
 					Times(1).
-					Return(&Config{
-						Name:  "kreceipt_deduction",
-						Value: 50000.0,
+					Return(&ConfigSnapshot{
+						Version: 1,
+						TaxYear: 2567,
+						Values: map[string]ConfigValue{
+							"personal_deduction": NewConfigValue(60000),
+							"kreceipt_deduction": NewConfigValue(50000),
+							"donation_deduction": NewConfigValue(100000),
+						},
+						Brackets: defaultTaxBrackets(),
 					}, nil)
 			},
 			expected: CalculationResultWithTaxLevel{
-				Tax:       19000,
+				Tax:       common.Baht(24600),
 				TaxRefund: 0,
 				TaxLevels: taxLevels3,
 			},
 		},
 		{
-			name: "Should calculate tax correctly, given total income and donation (under allowance limit of 100000)",
+			name: "Should calculate tax correctly, given total income and donation (under 10% of income after deductions)",
 			arg: calculationRequest{
-				TotalIncome: 500000,
+				TotalIncome: common.Baht(500000),
 				Wht:         0,
 				Allowances: []Allowance{
 					{
 						AllowanceType: AllowanceDonation,
-						Amount:        90000,
+						Amount:        common.Baht(40000),
 					},
 				},
 			},
 			taxConfigRepoStub: func(taxConfigRepo *MockTaxConfigRepository) {
 				taxConfigRepo.EXPECT().
-					FindByName(gomock.Any(), "personal_deduction").
-					Times(1).
-					Return(&Config{
-						Name:  "personal_deduction",
-						Value: 60000.0,
-					}, nil)
-
-				taxConfigRepo.EXPECT().
-					FindByName(gomock.Any(), "kreceipt_deduction").
+					FindSnapshot(gomock.Any(), 2567, 0).
 					Times(1).
-					Return(&Config{
-						Name:  "kreceipt_deduction",
-						Value: 50000.0,
+					Return(&ConfigSnapshot{
+						Version: 1,
+						TaxYear: 2567,
+						Values: map[string]ConfigValue{
+							"personal_deduction": NewConfigValue(60000),
+							"kreceipt_deduction": NewConfigValue(50000),
+							"donation_deduction": NewConfigValue(100000),
+						},
+						Brackets: defaultTaxBrackets(),
 					}, nil)
 			},
 			expected: CalculationResultWithTaxLevel{
-				Tax:       20000,
+				Tax:       common.Baht(25000),
 				TaxRefund: 0,
 				TaxLevels: taxLevels4,
 			},
@@ -182,34 +177,32 @@ func TestCalculateTax(t *testing.T) {
 		{
 			name: "Should calculate tax correctly, when personal deduction is configured",
 			arg: calculationRequest{
-				TotalIncome: 500000,
+				TotalIncome: common.Baht(500000),
 				Wht:         0,
 				Allowances: []Allowance{
 					{
 						AllowanceType: AllowanceDonation,
-						Amount:        90000,
+						Amount:        common.Baht(90000),
 					},
 				},
 			},
 			taxConfigRepoStub: func(taxConfigRepo *MockTaxConfigRepository) {
 				taxConfigRepo.EXPECT().
-					FindByName(gomock.Any(), "personal_deduction").
-					Times(1).
-					Return(&Config{
-						Name:  "personal_deduction",
-						Value: 35000.0,
-					}, nil)
-
-				taxConfigRepo.EXPECT().
-					FindByName(gomock.Any(), "kreceipt_deduction").
+					FindSnapshot(gomock.Any(), 2567, 0).
 					Times(1).
-					Return(&Config{
-						Name:  "kreceipt_deduction",
-						Value: 50000.0,
+					Return(&ConfigSnapshot{
+						Version: 1,
+						TaxYear: 2567,
+						Values: map[string]ConfigValue{
+							"personal_deduction": NewConfigValue(35000),
+							"kreceipt_deduction": NewConfigValue(50000),
+							"donation_deduction": NewConfigValue(100000),
+						},
+						Brackets: defaultTaxBrackets(),
 					}, nil)
 			},
 			expected: CalculationResultWithTaxLevel{
-				Tax:       22500,
+				Tax:       common.Baht(26850),
 				TaxRefund: 0,
 				TaxLevels: taxLevels5,
 			},
@@ -217,112 +210,106 @@ func TestCalculateTax(t *testing.T) {
 		{
 			name: "Should calculate tax refund correctly, when withholding tax is more than calculated tax",
 			arg: calculationRequest{
-				TotalIncome: 500000,
-				Wht:         30000,
+				TotalIncome: common.Baht(500000),
+				Wht:         common.Baht(30000),
 				Allowances: []Allowance{
 					{
 						AllowanceType: AllowanceDonation,
-						Amount:        90000,
+						Amount:        common.Baht(90000),
 					},
 				},
 			},
 			taxConfigRepoStub: func(taxConfigRepo *MockTaxConfigRepository) {
 				taxConfigRepo.EXPECT().
-					FindByName(gomock.Any(), "personal_deduction").
-					Times(1).
-					Return(&Config{
-						Name:  "personal_deduction",
-						Value: 60000.0,
-					}, nil)
-
-				taxConfigRepo.EXPECT().
-					FindByName(gomock.Any(), "kreceipt_deduction").
+					FindSnapshot(gomock.Any(), 2567, 0).
 					Times(1).
-					Return(&Config{
-						Name:  "kreceipt_deduction",
-						Value: 50000.0,
+					Return(&ConfigSnapshot{
+						Version: 1,
+						TaxYear: 2567,
+						Values: map[string]ConfigValue{
+							"personal_deduction": NewConfigValue(60000),
+							"kreceipt_deduction": NewConfigValue(50000),
+							"donation_deduction": NewConfigValue(100000),
+						},
+						Brackets: defaultTaxBrackets(),
 					}, nil)
 			},
 			expected: CalculationResultWithTaxLevel{
 				Tax:       0,
-				TaxRefund: 10000,
+				TaxRefund: common.Baht(5400),
 				TaxLevels: taxLevels6,
 			},
 		},
 		{
 			name: "Should calculate tax correctly, given allowance type of k-receipt",
 			arg: calculationRequest{
-				TotalIncome: 500000,
-				Wht:         21000,
+				TotalIncome: common.Baht(500000),
+				Wht:         common.Baht(21000),
 				Allowances: []Allowance{
 					{
 						AllowanceType: AllowanceDonation,
-						Amount:        90000,
+						Amount:        common.Baht(90000),
 					},
 					{
 						AllowanceType: AllowanceKReceipt,
-						Amount:        30000,
+						Amount:        common.Baht(30000),
 					},
 				},
 			},
 			taxConfigRepoStub: func(taxConfigRepo *MockTaxConfigRepository) {
 				taxConfigRepo.EXPECT().
-					FindByName(gomock.Any(), "personal_deduction").
+					FindSnapshot(gomock.Any(), 2567, 0).
 					Times(1).
-					Return(&Config{
-						Name:  "personal_deduction",
-						Value: 60000.0,
-					}, nil)
-
-				taxConfigRepo.EXPECT().
-					FindByName(gomock.Any(), "kreceipt_deduction").
-					Times(1).
-					Return(&Config{
-						Name:  "kreceipt_deduction",
-						Value: 50000.0,
+					Return(&ConfigSnapshot{
+						Version: 1,
+						TaxYear: 2567,
+						Values: map[string]ConfigValue{
+							"personal_deduction": NewConfigValue(60000),
+							"kreceipt_deduction": NewConfigValue(50000),
+							"donation_deduction": NewConfigValue(100000),
+						},
+						Brackets: defaultTaxBrackets(),
 					}, nil)
 			},
 			expected: CalculationResultWithTaxLevel{
-				Tax:       0,
-				TaxRefund: 4000,
+				Tax:       common.Baht(900),
+				TaxRefund: 0,
 				TaxLevels: taxLevels7,
 			},
 		},
 		{
 			name: "Should calculate tax correctly, given allowance type of k-receipt (over allowance limit of 50000)",
 			arg: calculationRequest{
-				TotalIncome: 500000,
-				Wht:         15000,
+				TotalIncome: common.Baht(500000),
+				Wht:         common.Baht(15000),
 				Allowances: []Allowance{
 					{
 						AllowanceType: AllowanceDonation,
-						Amount:        90000,
+						Amount:        common.Baht(90000),
 					},
 					{
 						AllowanceType: AllowanceKReceipt,
-						Amount:        60000,
+						Amount:        common.Baht(60000),
 					},
 				},
 			},
 			taxConfigRepoStub: func(taxConfigRepo *MockTaxConfigRepository) {
 				taxConfigRepo.EXPECT().
-					FindByName(gomock.Any(), "personal_deduction").
-					Times(1).
-					Return(&Config{
-						Name:  "personal_deduction",
-						Value: 60000.0,
-					}, nil)
-
-				taxConfigRepo.EXPECT().
-					FindByName(gomock.Any(), "kreceipt_deduction").
+					FindSnapshot(gomock.Any(), 2567, 0).
 					Times(1).
-					Return(&Config{
-						Name:  "kreceipt_deduction",
-						Value: 50000.0,
+					Return(&ConfigSnapshot{
+						Version: 1,
+						TaxYear: 2567,
+						Values: map[string]ConfigValue{
+							"personal_deduction": NewConfigValue(60000),
+							"kreceipt_deduction": NewConfigValue(50000),
+							"donation_deduction": NewConfigValue(100000),
+						},
+						Brackets: defaultTaxBrackets(),
 					}, nil)
 			},
 			expected: CalculationResultWithTaxLevel{
-				Tax:       0,
+				Tax:       common.Baht(5100),
 				TaxRefund: 0,
 				TaxLevels: taxLevels8,
 			},
@@ -330,38 +317,36 @@ func TestCalculateTax(t *testing.T) {
 		{
 			name: "Should calculate tax correctly, given allowance type of k-receipt (equal to allowance limit of 50000)",
 			arg: calculationRequest{
-				TotalIncome: 500000,
-				Wht:         15000,
+				TotalIncome: common.Baht(500000),
+				Wht:         common.Baht(15000),
 				Allowances: []Allowance{
 					{
 						AllowanceType: AllowanceDonation,
-						Amount:        90000,
+						Amount:        common.Baht(90000),
 					},
 					{
 						AllowanceType: AllowanceKReceipt,
-						Amount:        50000,
+						Amount:        common.Baht(50000),
 					},
 				},
 			},
 			taxConfigRepoStub: func(taxConfigRepo *MockTaxConfigRepository) {
 				taxConfigRepo.EXPECT().
-					FindByName(gomock.Any(), "personal_deduction").
-					Times(1).
-					Return(&Config{
-						Name:  "personal_deduction",
-						Value: 60000.0,
-					}, nil)
-
-				taxConfigRepo.EXPECT().
-					FindByName(gomock.Any(), "kreceipt_deduction").
+					FindSnapshot(gomock.Any(), 2567, 0).
 					Times(1).
-					Return(&Config{
-						Name:  "kreceipt_deduction",
-						Value: 50000.0,
+					Return(&ConfigSnapshot{
+						Version: 1,
+						TaxYear: 2567,
+						Values: map[string]ConfigValue{
+							"personal_deduction": NewConfigValue(60000),
+							"kreceipt_deduction": NewConfigValue(50000),
+							"donation_deduction": NewConfigValue(100000),
+						},
+						Brackets: defaultTaxBrackets(),
 					}, nil)
 			},
 			expected: CalculationResultWithTaxLevel{
-				Tax:       0,
+				Tax:       common.Baht(5100),
 				TaxRefund: 0,
 				TaxLevels: taxLevels8,
 			},
@@ -373,12 +358,13 @@ func TestCalculateTax(t *testing.T) {
 			ctrl := gomock.NewController(t)
 
 			taxConfigRepo := NewMockTaxConfigRepository(ctrl)
-			calculator := NewCalculator(taxConfigRepo)
+			calculator := NewCalculator(taxConfigRepo, ConfigFallbackStrict)
 
 			tc.taxConfigRepoStub(taxConfigRepo)
 
 			ctx := context.Background()
-			result := calculator.Calculate(ctx, tc.arg)
+			result, err := calculator.Calculate(ctx, tc.arg)
+			require.NoError(t, err)
 
 			require.Equal(t, tc.expected.Tax, result.Tax)
 			require.Equal(t, tc.expected.TaxLevels, result.TaxLevels)
@@ -398,7 +384,7 @@ func TestBatchCalculate(t *testing.T) {
 			name: "Should calculate tax for batch input correctly",
 			arg: []calculationRequest{
 				{
-					TotalIncome: 500000,
+					TotalIncome: common.Baht(500000),
 					Wht:         0,
 					Allowances: []Allowance{
 						{
@@ -408,58 +394,56 @@ func TestBatchCalculate(t *testing.T) {
 					},
 				},
 				{
-					TotalIncome: 600000,
-					Wht:         55000,
+					TotalIncome: common.Baht(600000),
+					Wht:         common.Baht(55000),
 					Allowances: []Allowance{
 						{
 							AllowanceType: AllowanceDonation,
-							Amount:        20000,
+							Amount:        common.Baht(20000),
 						},
 					},
 				},
 				{
-					TotalIncome: 750000,
-					Wht:         50000,
+					TotalIncome: common.Baht(750000),
+					Wht:         common.Baht(50000),
 					Allowances: []Allowance{
 						{
 							AllowanceType: AllowanceDonation,
-							Amount:        15000,
+							Amount:        common.Baht(15000),
 						},
 					},
 				},
 			},
 			taxConfigRepoStub: func(taxConfigRepo *MockTaxConfigRepository) {
 				taxConfigRepo.EXPECT().
-					FindByName(gomock.Any(), "personal_deduction").
-					Times(1).
-					Return(&Config{
-						Name:  "personal_deduction",
-						Value: 60000.0,
-					}, nil)
-
-				taxConfigRepo.EXPECT().
-					FindByName(gomock.Any(), "kreceipt_deduction").
+					FindSnapshot(gomock.Any(), 2567, 0).
 					Times(1).
-					Return(&Config{
-						Name:  "kreceipt_deduction",
-						Value: 50000.0,
+					Return(&ConfigSnapshot{
+						Version: 1,
+						TaxYear: 2567,
+						Values: map[string]ConfigValue{
+							"personal_deduction": NewConfigValue(60000),
+							"kreceipt_deduction": NewConfigValue(50000),
+							"donation_deduction": NewConfigValue(100000),
+						},
+						Brackets: defaultTaxBrackets(),
 					}, nil)
 			},
 			expected: BatchCalculationResult{
 				Taxes: []CalculationResult{
 					{
-						TotalIncome: 500000,
-						Tax:         29000,
+						TotalIncome: common.Baht(500000),
+						Tax:         common.Baht(29000),
 						TaxRefund:   0,
 					},
 					{
-						TotalIncome: 600000,
+						TotalIncome: common.Baht(600000),
 						Tax:         0,
-						TaxRefund:   15000,
+						TaxRefund:   common.Baht(17000),
 					},
 					{
-						TotalIncome: 750000,
-						Tax:         28750,
+						TotalIncome: common.Baht(750000),
+						Tax:         common.Baht(11250),
 						TaxRefund:   0,
 					},
 				},
@@ -472,12 +456,13 @@ func TestBatchCalculate(t *testing.T) {
 			ctrl := gomock.NewController(t)
 
 			taxConfigRepo := NewMockTaxConfigRepository(ctrl)
-			calculator := NewCalculator(taxConfigRepo)
+			calculator := NewCalculator(taxConfigRepo, ConfigFallbackStrict)
 
 			tc.taxConfigRepoStub(taxConfigRepo)
 
 			ctx := context.Background()
-			result := calculator.BatchCalculate(ctx, tc.arg)
+			result, err := calculator.BatchCalculate(ctx, tc.arg)
+			require.NoError(t, err)
 
 			require.Equal(t, len(tc.expected.Taxes), len(result.Taxes))
 
@@ -485,7 +470,559 @@ func TestBatchCalculate(t *testing.T) {
 				require.Equal(t, tc.expected.Taxes[i].TotalIncome, result.Taxes[i].TotalIncome)
 				require.Equal(t, tc.expected.Taxes[i].Tax, result.Taxes[i].Tax)
 				require.Equal(t, tc.expected.Taxes[i].TaxRefund, result.Taxes[i].TaxRefund)
+				require.Len(t, result.Taxes[i].TaxLevels, len(defaultTaxBrackets()))
 			}
 		})
 	}
 }
+
+func TestCalculateTaxWithTaxBrackets(t *testing.T) {
+	upperBound := func(baht int64) *common.Money {
+		v := common.Baht(baht)
+		return &v
+	}
+
+	taxLevels1 := createEmptyTaxLevels(defaultTaxBrackets())
+	taxLevels1[1].Tax = common.Baht(35000)
+	taxLevels1[2].Tax = common.Baht(75000)
+	taxLevels1[3].Tax = common.Baht(200000)
+	taxLevels1[4].Tax = common.Baht(175000)
+
+	flatBrackets := []TaxBracket{
+		{LowerBound: 0, UpperBound: upperBound(100000), Rate: decimal.RequireFromString("0")},
+		{LowerBound: common.Baht(100000), UpperBound: nil, Rate: decimal.RequireFromString("0.1")},
+	}
+	taxLevels2 := createEmptyTaxLevels(flatBrackets)
+	taxLevels2[1].Tax = common.Baht(34000)
+
+	testCases := []struct {
+		name              string
+		arg               calculationRequest
+		taxConfigRepoStub func(taxConfigRepo *MockTaxConfigRepository)
+		expected          CalculationResultWithTaxLevel
+	}{
+		{
+			name: "Should calculate marginal tax of every bracket, given income in the top bracket",
+			arg: calculationRequest{
+				TotalIncome: common.Baht(2560000),
+			},
+			taxConfigRepoStub: func(taxConfigRepo *MockTaxConfigRepository) {
+				taxConfigRepo.EXPECT().
+					FindSnapshot(gomock.Any(), 2567, 0).
+					Times(1).
+					Return(&ConfigSnapshot{
+						Version: 1,
+						TaxYear: 2567,
+						Values: map[string]ConfigValue{
+							"personal_deduction": NewConfigValue(60000),
+							"kreceipt_deduction": NewConfigValue(50000),
+							"donation_deduction": NewConfigValue(100000),
+						},
+						Brackets: defaultTaxBrackets(),
+					}, nil)
+			},
+			expected: CalculationResultWithTaxLevel{
+				Tax:       common.Baht(485000),
+				TaxRefund: 0,
+				TaxLevels: taxLevels1,
+			},
+		},
+		{
+			name: "Should calculate tax and tax levels from configured brackets",
+			arg: calculationRequest{
+				TotalIncome: common.Baht(500000),
+			},
+			taxConfigRepoStub: func(taxConfigRepo *MockTaxConfigRepository) {
+				taxConfigRepo.EXPECT().
+					FindSnapshot(gomock.Any(), 2567, 0).
+					Times(1).
+					Return(&ConfigSnapshot{
+						Version: 1,
+						TaxYear: 2567,
+						Values: map[string]ConfigValue{
+							"personal_deduction": NewConfigValue(60000),
+							"kreceipt_deduction": NewConfigValue(50000),
+							"donation_deduction": NewConfigValue(100000),
+						},
+						Brackets: flatBrackets,
+					}, nil)
+			},
+			expected: CalculationResultWithTaxLevel{
+				Tax:       common.Baht(34000),
+				TaxRefund: 0,
+				TaxLevels: taxLevels2,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			taxConfigRepo := NewMockTaxConfigRepository(ctrl)
+			calculator := NewCalculator(taxConfigRepo, ConfigFallbackStrict)
+
+			tc.taxConfigRepoStub(taxConfigRepo)
+
+			ctx := context.Background()
+			result, err := calculator.Calculate(ctx, tc.arg)
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expected.Tax, result.Tax)
+			require.Equal(t, tc.expected.TaxLevels, result.TaxLevels)
+			require.Equal(t, tc.expected.TaxRefund, result.TaxRefund)
+		})
+	}
+}
+
+func TestCalculateTaxWithConfigFallback(t *testing.T) {
+	t.Run("Should return config unavailable, given strict fallback and config that cannot be loaded", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		taxConfigRepo := NewMockTaxConfigRepository(ctrl)
+		calculator := NewCalculator(taxConfigRepo, ConfigFallbackStrict)
+
+		repositoryErr := errors.New("connection refused")
+		taxConfigRepo.EXPECT().FindSnapshot(gomock.Any(), 2567, 0).Times(1).Return(nil, repositoryErr)
+
+		_, err := calculator.Calculate(context.Background(), calculationRequest{TotalIncome: common.Baht(500000)})
+		require.ErrorIs(t, err, ErrConfigUnavailable)
+		require.ErrorIs(t, err, repositoryErr)
+	})
+
+	t.Run("Should calculate with default rules flagged as default, given lenient fallback and config that cannot be loaded", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		taxConfigRepo := NewMockTaxConfigRepository(ctrl)
+		calculator := NewCalculator(taxConfigRepo, ConfigFallbackLenient)
+
+		taxConfigRepo.EXPECT().FindSnapshot(gomock.Any(), 2567, 0).Times(1).Return(nil, errors.New("connection refused"))
+
+		result, err := calculator.Calculate(context.Background(), calculationRequest{TotalIncome: common.Baht(500000)})
+		require.NoError(t, err)
+
+		taxLevels := createEmptyTaxLevels(defaultTaxBrackets())
+		taxLevels[1].Tax = common.Baht(29000)
+
+		require.Equal(t, common.Baht(29000), result.Tax)
+		require.Equal(t, taxLevels, result.TaxLevels)
+		require.Equal(t, ConfigSourceDefault, result.ConfigSource)
+		require.Zero(t, result.ConfigVersion)
+	})
+
+	t.Run("Should flag result as read from database, given config that can be loaded", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		taxConfigRepo := NewMockTaxConfigRepository(ctrl)
+		calculator := NewCalculator(taxConfigRepo, ConfigFallbackLenient)
+
+		taxConfigRepo.EXPECT().FindSnapshot(gomock.Any(), 2567, 0).Times(1).
+			Return(&ConfigSnapshot{Version: 2, TaxYear: 2567, Brackets: defaultTaxBrackets()}, nil)
+
+		result, err := calculator.BatchCalculate(context.Background(), []calculationRequest{{TotalIncome: common.Baht(500000)}})
+		require.NoError(t, err)
+
+		require.Equal(t, ConfigSourceDatabase, result.Taxes[0].ConfigSource)
+		require.Equal(t, 2, result.Taxes[0].ConfigVersion)
+	})
+
+	t.Run("Should return config unavailable, given strict fallback and config version that cannot be loaded", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		taxConfigRepo := NewMockTaxConfigRepository(ctrl)
+		calculator := NewCalculator(taxConfigRepo, ConfigFallbackStrict)
+
+		taxConfigRepo.EXPECT().LatestVersion(gomock.Any()).Times(1).Return(0, errors.New("connection refused"))
+
+		_, err := calculator.LatestConfigVersion(context.Background())
+		require.ErrorIs(t, err, ErrConfigUnavailable)
+	})
+}
+
+func TestCalculateTaxWithTaxYear(t *testing.T) {
+	t.Run("Should calculate tax with rules of requested tax year", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		taxConfigRepo := NewMockTaxConfigRepository(ctrl)
+		calculator := NewCalculator(taxConfigRepo, ConfigFallbackStrict)
+
+		taxConfigRepo.EXPECT().FindSnapshot(gomock.Any(), 2566, 0).Times(1).
+			Return(&ConfigSnapshot{
+				Version: 1,
+				TaxYear: 2566,
+				Values: map[string]ConfigValue{
+					"personal_deduction": NewConfigValue(50000),
+					"donation_deduction": NewConfigValue(10000),
+				},
+				Brackets: defaultTaxBrackets(),
+			}, nil)
+
+		result, err := calculator.Calculate(context.Background(), calculationRequest{
+			TaxYear:     2566,
+			TotalIncome: common.Baht(500000),
+			Allowances: []Allowance{
+				{
+					AllowanceType: AllowanceDonation,
+					Amount:        common.Baht(20000),
+				},
+			},
+		})
+		require.NoError(t, err)
+
+		taxLevels := createEmptyTaxLevels(defaultTaxBrackets())
+		taxLevels[1].Tax = common.Baht(29000)
+
+		require.Equal(t, 2566, result.TaxYear)
+		require.Equal(t, common.Baht(29000), result.Tax)
+		require.Equal(t, taxLevels, result.TaxLevels)
+	})
+
+	t.Run("Should return error, given unknown tax year", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		taxConfigRepo := NewMockTaxConfigRepository(ctrl)
+		calculator := NewCalculator(taxConfigRepo, ConfigFallbackStrict)
+
+		taxConfigRepo.EXPECT().FindSnapshot(gomock.Any(), 2500, 0).Times(1).
+			Return(&ConfigSnapshot{Version: 1, TaxYear: 2500}, nil)
+
+		_, err := calculator.Calculate(context.Background(), calculationRequest{
+			TaxYear:     2500,
+			TotalIncome: common.Baht(500000),
+		})
+		require.ErrorIs(t, err, ErrUnknownTaxYear)
+	})
+
+	t.Run("Should return error, given unknown config version", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		taxConfigRepo := NewMockTaxConfigRepository(ctrl)
+		calculator := NewCalculator(taxConfigRepo, ConfigFallbackStrict)
+
+		taxConfigRepo.EXPECT().FindSnapshot(gomock.Any(), 2567, 99).Times(1).Return(nil, ErrUnknownConfigVersion)
+
+		_, err := calculator.Calculate(context.Background(), calculationRequest{
+			TotalIncome:   common.Baht(500000),
+			ConfigVersion: 99,
+		})
+		require.ErrorIs(t, err, ErrUnknownConfigVersion)
+	})
+
+	t.Run("Should load rules once per tax year at the version of the first, given batch with mixed tax years", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		taxConfigRepo := NewMockTaxConfigRepository(ctrl)
+		calculator := NewCalculator(taxConfigRepo, ConfigFallbackStrict)
+
+		gomock.InOrder(
+			taxConfigRepo.EXPECT().FindSnapshot(gomock.Any(), 2566, 0).Times(1).
+				Return(&ConfigSnapshot{Version: 3, TaxYear: 2566, Brackets: defaultTaxBrackets()}, nil),
+			taxConfigRepo.EXPECT().FindSnapshot(gomock.Any(), 2567, 3).Times(1).
+				Return(&ConfigSnapshot{Version: 3, TaxYear: 2567, Brackets: defaultTaxBrackets()}, nil),
+		)
+
+		result, err := calculator.BatchCalculate(context.Background(), []calculationRequest{
+			{TaxYear: 2566, TotalIncome: common.Baht(500000)},
+			{TotalIncome: common.Baht(500000)},
+			{TaxYear: 2566, TotalIncome: common.Baht(600000)},
+		})
+		require.NoError(t, err)
+
+		require.Len(t, result.Taxes, 3)
+		require.Equal(t, 2566, result.Taxes[0].TaxYear)
+		require.Equal(t, 2567, result.Taxes[1].TaxYear)
+		require.Equal(t, 2566, result.Taxes[2].TaxYear)
+		for _, v := range result.Taxes {
+			require.Equal(t, 3, v.ConfigVersion)
+		}
+	})
+}
+
+func TestCalculateTaxRounding(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	taxConfigRepo := NewMockTaxConfigRepository(ctrl)
+	calculator := NewCalculator(taxConfigRepo, ConfigFallbackStrict)
+
+	taxConfigRepo.EXPECT().FindSnapshot(gomock.Any(), 2567, 0).Times(1).
+		Return(&ConfigSnapshot{Version: 1, TaxYear: 2567, Brackets: defaultTaxBrackets()}, nil)
+
+	totalIncome, err := common.ParseMoney("1500000.05")
+	require.NoError(t, err)
+
+	result, err := calculator.Calculate(context.Background(), calculationRequest{
+		TotalIncome: totalIncome,
+	})
+	require.NoError(t, err)
+
+	// 35,000 + 75,000 + 88,000.01 (440,000.05 at 20%), rounded half-up to satang
+	taxLevels := createEmptyTaxLevels(defaultTaxBrackets())
+	taxLevels[1].Tax = common.Baht(35000)
+	taxLevels[2].Tax = common.Baht(75000)
+	taxLevels[3].Tax = common.Money(8800001)
+
+	require.Equal(t, common.Money(19800001), result.Tax)
+	require.Equal(t, taxLevels, result.TaxLevels)
+}
+
+func TestCalculateTaxRoundingDeductions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	taxConfigRepo := NewMockTaxConfigRepository(ctrl)
+	calculator := NewCalculator(taxConfigRepo, ConfigFallbackStrict)
+
+	taxConfigRepo.EXPECT().FindSnapshot(gomock.Any(), 2567, 0).Times(1).
+		Return(&ConfigSnapshot{Version: 1, TaxYear: 2567, Brackets: defaultTaxBrackets()}, nil)
+
+	rental, err := common.ParseMoney("800000.05")
+	require.NoError(t, err)
+
+	result, err := calculator.Calculate(context.Background(), calculationRequest{
+		Incomes: []Income{
+			{IncomeType: IncomeRental, Amount: rental},
+		},
+	})
+	require.NoError(t, err)
+
+	// 800,000.05 - 240,000.015 expenses (30%) - 60,000 personal = 500,000.035,
+	// taxed 35,000 + 0.00525 and rounded to 35,000.01. Rounding the expenses to
+	// 240,000.02 first would give 35,000.00.
+	require.Equal(t, common.Money(3500001), result.Tax)
+	require.Equal(t, []IncomeBreakdown{
+		{IncomeType: IncomeRental, Section: "40(5)", Amount: rental, ExpenseDeduction: common.Money(24000002), NetIncome: common.Money(56000004)},
+	}, result.Incomes)
+}
+
+func TestCalculateTaxWithIncomes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	taxConfigRepo := NewMockTaxConfigRepository(ctrl)
+	calculator := NewCalculator(taxConfigRepo, ConfigFallbackStrict)
+
+	taxConfigRepo.EXPECT().FindSnapshot(gomock.Any(), 2567, 0).Times(1).
+		Return(&ConfigSnapshot{Version: 1, TaxYear: 2567, Brackets: defaultTaxBrackets()}, nil)
+
+	result, err := calculator.Calculate(context.Background(), calculationRequest{
+		Incomes: []Income{
+			{IncomeType: IncomeSalary, Amount: common.Baht(500000)},
+			{IncomeType: IncomeDividend, Amount: common.Baht(100000)},
+		},
+		Allowances: []Allowance{
+			{AllowanceType: AllowanceProvidentFund, Amount: common.Baht(100000)},
+		},
+	})
+	require.NoError(t, err)
+
+	// 600,000 - 100,000 expenses - 60,000 personal - 90,000 provident fund (15% of gross)
+	taxLevels := createEmptyTaxLevels(defaultTaxBrackets())
+	taxLevels[1].Tax = common.Baht(20000)
+
+	require.Equal(t, common.Baht(20000), result.Tax)
+	require.Equal(t, taxLevels, result.TaxLevels)
+	require.Equal(t, []IncomeBreakdown{
+		{IncomeType: IncomeSalary, Section: "40(1)", Amount: common.Baht(500000), ExpenseDeduction: common.Baht(100000), NetIncome: common.Baht(400000)},
+		{IncomeType: IncomeDividend, Section: "40(4)", Amount: common.Baht(100000), ExpenseDeduction: 0, NetIncome: common.Baht(100000)},
+	}, result.Incomes)
+}
+
+func TestCalculateMinimumTax(t *testing.T) {
+	mustParseMoney := func(s string) common.Money {
+		m, err := common.ParseMoney(s)
+		require.NoError(t, err)
+		return m
+	}
+
+	testCases := []struct {
+		name                   string
+		arg                    calculationRequest
+		expectedTax            common.Money
+		expectedProgressiveTax common.Money
+		expectedMinimumTax     common.Money
+		expectedTaxMethod      TaxMethod
+	}{
+		{
+			name: "Should not apply minimum tax, given non-salary income of exactly 120000",
+			arg: calculationRequest{
+				Incomes: []Income{
+					{IncomeType: IncomeInterest, Amount: common.Baht(120000)},
+				},
+			},
+			expectedTax:            0,
+			expectedProgressiveTax: 0,
+			expectedMinimumTax:     0,
+			expectedTaxMethod:      TaxMethodProgressive,
+		},
+		{
+			name: "Should apply minimum tax, given non-salary income just over 120000",
+			arg: calculationRequest{
+				Incomes: []Income{
+					{IncomeType: IncomeInterest, Amount: mustParseMoney("120000.01")},
+				},
+			},
+			expectedTax:            common.Baht(600),
+			expectedProgressiveTax: 0,
+			expectedMinimumTax:     common.Baht(600),
+			expectedTaxMethod:      TaxMethodMinimum,
+		},
+		{
+			name: "Should not apply minimum tax, given only salary income",
+			arg: calculationRequest{
+				Incomes: []Income{
+					{IncomeType: IncomeSalary, Amount: common.Baht(300000)},
+				},
+			},
+			expectedTax:            0,
+			expectedProgressiveTax: 0,
+			expectedMinimumTax:     0,
+			expectedTaxMethod:      TaxMethodProgressive,
+		},
+		{
+			name: "Should apply progressive tax, given progressive tax higher than minimum tax",
+			arg: calculationRequest{
+				Incomes: []Income{
+					{IncomeType: IncomeBusiness, Amount: common.Baht(1000000)},
+				},
+			},
+			expectedTax:            common.Baht(19000),
+			expectedProgressiveTax: common.Baht(19000),
+			expectedMinimumTax:     common.Baht(5000),
+			expectedTaxMethod:      TaxMethodProgressive,
+		},
+		{
+			name: "Should offset withholding tax against minimum tax",
+			arg: calculationRequest{
+				Incomes: []Income{
+					{IncomeType: IncomeRental, Amount: common.Baht(200000)},
+					{IncomeType: IncomeSalary, Amount: common.Baht(100000)},
+				},
+				Wht: common.Baht(1500),
+			},
+			expectedTax:            0,
+			expectedProgressiveTax: 0,
+			expectedMinimumTax:     common.Baht(1000),
+			expectedTaxMethod:      TaxMethodMinimum,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			taxConfigRepo := NewMockTaxConfigRepository(ctrl)
+			calculator := NewCalculator(taxConfigRepo, ConfigFallbackStrict)
+
+			taxConfigRepo.EXPECT().FindSnapshot(gomock.Any(), 2567, 0).Times(1).
+				Return(&ConfigSnapshot{Version: 1, TaxYear: 2567, Brackets: defaultTaxBrackets()}, nil)
+
+			result, err := calculator.Calculate(context.Background(), tc.arg)
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expectedTax, result.Tax)
+			require.Equal(t, tc.expectedProgressiveTax, result.ProgressiveTax)
+			require.Equal(t, tc.expectedMinimumTax, result.MinimumTax)
+			require.Equal(t, tc.expectedTaxMethod, result.TaxMethod)
+		})
+	}
+}
+
+func TestCalculateTaxWithExplain(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	taxConfigRepo := NewMockTaxConfigRepository(ctrl)
+	calculator := NewCalculator(taxConfigRepo, ConfigFallbackStrict)
+
+	taxConfigRepo.EXPECT().FindSnapshot(gomock.Any(), 2567, 0).Times(1).
+		Return(&ConfigSnapshot{
+			Version:  1,
+			TaxYear:  2567,
+			Values:   map[string]ConfigValue{"personal_deduction": NewConfigValue(60000)},
+			Brackets: defaultTaxBrackets(),
+		}, nil)
+
+	money := func(baht int64) *common.Money {
+		return traceMoney(common.Baht(baht))
+	}
+	rate := func(s string) *float64 {
+		return traceRate(decimal.RequireFromString(s))
+	}
+
+	result, err := calculator.Calculate(context.Background(), calculationRequest{
+		TotalIncome: common.Baht(500000),
+		Wht:         common.Baht(25000),
+		Allowances: []Allowance{
+			{AllowanceType: AllowanceDonation, Amount: common.Baht(200000)},
+			{AllowanceType: AllowanceDoubleDonation, Amount: common.Baht(10000)},
+			{AllowanceType: AllowanceKReceipt, Amount: common.Baht(60000)},
+		},
+		Explain: true,
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, []TraceStep{
+		{Step: TraceStepConfig, Name: "personal_deduction", Source: ConfigSourceDatabase, Amount: money(60000)},
+		{Step: TraceStepConfig, Name: "kreceipt_deduction", Source: ConfigSourceDefault, Amount: money(50000)},
+		{Step: TraceStepConfig, Name: "donation_deduction", Source: ConfigSourceDefault, Amount: money(100000)},
+		{Step: TraceStepConfig, Name: "donation_income_rate", Source: ConfigSourceDefault, Rate: rate("0.1")},
+		{Step: TraceStepConfig, Name: "brackets", Source: ConfigSourceDatabase},
+		{Step: TraceStepGrossIncome, Amount: money(500000)},
+		{Step: TraceStepPersonalDeduction, Amount: money(60000)},
+		{Step: TraceStepAllowance, Name: "k-receipt", Requested: money(60000), Cap: money(50000), Amount: money(50000)},
+		{Step: TraceStepAllowance, Name: "double-donation", Requested: money(10000), Cap: money(39000), Base: money(390000), Rate: rate("0.1"), Amount: money(20000)},
+		{Step: TraceStepAllowance, Name: "donation", Requested: money(200000), Cap: money(37000), Base: money(370000), Rate: rate("0.1"), Amount: money(37000)},
+		{Step: TraceStepTaxableIncome, Amount: money(333000)},
+		{Step: TraceStepBracket, Name: "0-150,000", Base: money(150000), Rate: rate("0"), Amount: money(0)},
+		{Step: TraceStepBracket, Name: "150,001-500,000", Base: money(183000), Rate: rate("0.1"), Amount: money(18300)},
+		{Step: TraceStepBracket, Name: "500,001-1,000,000", Base: money(0), Rate: rate("0.15"), Amount: money(0)},
+		{Step: TraceStepBracket, Name: "1,000,001-2,000,000", Base: money(0), Rate: rate("0.2"), Amount: money(0)},
+		{Step: TraceStepBracket, Name: "2,000,001 ขึ้นไป", Base: money(0), Rate: rate("0.35"), Amount: money(0)},
+		{Step: TraceStepProgressiveTax, Amount: money(18300)},
+		{Step: TraceStepWht, Amount: money(25000)},
+		{Step: TraceStepResult, Name: "tax", Amount: money(0)},
+		{Step: TraceStepResult, Name: "taxRefund", Amount: money(6700)},
+	}, result.Trace)
+}
+
+func TestCalculateTaxWithoutExplain(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	taxConfigRepo := NewMockTaxConfigRepository(ctrl)
+	calculator := NewCalculator(taxConfigRepo, ConfigFallbackStrict)
+
+	taxConfigRepo.EXPECT().FindSnapshot(gomock.Any(), 2567, 0).Times(1).
+		Return(&ConfigSnapshot{Version: 1, TaxYear: 2567, Brackets: defaultTaxBrackets()}, nil)
+
+	result, err := calculator.Calculate(context.Background(), calculationRequest{
+		TotalIncome: common.Baht(500000),
+	})
+	require.NoError(t, err)
+	require.Nil(t, result.Trace)
+}
+
+func TestNewBatchSummary(t *testing.T) {
+	levels := func(taxes ...int64) []TaxLevel {
+		taxLevels := createEmptyTaxLevels(defaultTaxBrackets())
+		for i, v := range taxes {
+			taxLevels[i].Tax = common.Baht(v)
+		}
+		return taxLevels
+	}
+
+	summary := newBatchSummary([]CalculationResult{
+		{Tax: common.Baht(29000), TaxLevels: levels(0, 29000)},
+		{Tax: 0, TaxRefund: common.Baht(1000), TaxLevels: levels()},
+		{Tax: common.Baht(63750), TaxLevels: levels(0, 35000, 28750)},
+		{Tax: common.Baht(20000), TaxLevels: levels(0, 20000)},
+	})
+
+	require.Equal(t, &BatchSummary{
+		TotalTax:       common.Baht(112750),
+		TotalTaxRefund: common.Baht(1000),
+		HighestBrackets: []BracketCount{
+			{Level: "0-150,000", Count: 1},
+			{Level: "150,001-500,000", Count: 2},
+			{Level: "500,001-1,000,000", Count: 1},
+			{Level: "1,000,001-2,000,000", Count: 0},
+			{Level: "2,000,001 ขึ้นไป", Count: 0},
+		},
+	}, summary)
+}
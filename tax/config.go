@@ -1,6 +1,151 @@
 package tax
 
-type Config struct {
-	Name  string  `db:"name"`
-	Value float64 `db:"value"`
+import (
+	"database/sql/driver"
+	"slices"
+
+	"github.com/chuckboliver/assessment-tax/common"
+	"github.com/shopspring/decimal"
+)
+
+// ConfigSnapshot is the tax config of a tax year as it was at a config version.
+// Every change of tax config, of any tax year, makes a new version, so that a
+// version tells the config of every tax year at once.
+type ConfigSnapshot struct {
+	// Version is the config version the snapshot was read at.
+	Version int
+	TaxYear int
+	// Values are the config values by name. A value not configured for the tax
+	// year is missing.
+	Values map[string]ConfigValue
+	// Brackets are empty when the tax year has no brackets.
+	Brackets []TaxBracket
+}
+
+// SettingUnit is the unit of the value of a Setting.
+type SettingUnit string
+
+const (
+	SettingUnitBaht    SettingUnit = "THB"
+	SettingUnitPercent SettingUnit = "percent"
+)
+
+// ConfigValue is the value of a Setting in its unit, baht or percent, with at
+// most two decimals, e.g. NewConfigValue(10) is 10 baht or 10%. It is formatted
+// as a plain number, without the trailing decimal of an amount of money.
+type ConfigValue int64
+
+// NewConfigValue returns the ConfigValue of a whole number of units.
+func NewConfigValue(units int64) ConfigValue {
+	return ConfigValue(common.Baht(units))
+}
+
+// ConfigValueOf returns the ConfigValue of an amount of a baht Setting.
+func ConfigValueOf(amount common.Money) ConfigValue {
+	return ConfigValue(amount)
+}
+
+func (v ConfigValue) Decimal() decimal.Decimal {
+	return common.Money(v).Decimal()
+}
+
+// Money returns the amount of a baht Setting.
+func (v ConfigValue) Money() common.Money {
+	return common.Money(v)
+}
+
+// Rate returns the rate of a percent Setting, e.g. 0.1 for 10%.
+func (v ConfigValue) Rate() decimal.Decimal {
+	return v.Decimal().Div(decimal.NewFromInt(100))
+}
+
+// String formats the value without trailing zeros, e.g. "10" or "12.5".
+func (v ConfigValue) String() string {
+	return v.Decimal().String()
+}
+
+func (v ConfigValue) MarshalJSON() ([]byte, error) {
+	return []byte(v.String()), nil
+}
+
+func (v *ConfigValue) UnmarshalJSON(data []byte) error {
+	return (*common.Money)(v).UnmarshalJSON(data)
+}
+
+func (v *ConfigValue) Scan(src interface{}) error {
+	return (*common.Money)(v).Scan(src)
+}
+
+func (v ConfigValue) Value() (driver.Value, error) {
+	return common.Money(v).Value()
+}
+
+// Setting is a tax config value that admins can change per tax year, stored in
+// tax_config under Key. A tax year without the value is calculated with Default.
+type Setting struct {
+	Key         string
+	Unit        SettingUnit
+	Description string
+	Min         ConfigValue
+	Max         ConfigValue
+	Default     ConfigValue
+}
+
+var (
+	SettingPersonalDeduction = Setting{
+		Key:         "personal_deduction",
+		Unit:        SettingUnitBaht,
+		Description: "Personal deduction of every taxpayer",
+		Min:         NewConfigValue(10000),
+		Max:         NewConfigValue(100000),
+		Default:     NewConfigValue(60000),
+	}
+	SettingKReceiptDeduction = Setting{
+		Key:         "kreceipt_deduction",
+		Unit:        SettingUnitBaht,
+		Description: "Maximum deduction of k-receipt allowances",
+		Min:         0,
+		Max:         NewConfigValue(100000),
+		Default:     NewConfigValue(50000),
+	}
+	SettingDonationDeduction = Setting{
+		Key:         "donation_deduction",
+		Unit:        SettingUnitBaht,
+		Description: "Maximum deduction of donation allowances",
+		Min:         0,
+		Max:         NewConfigValue(1000000),
+		Default:     NewConfigValue(100000),
+	}
+	SettingDonationIncomeRate = Setting{
+		Key:         "donation_income_rate",
+		Unit:        SettingUnitPercent,
+		Description: "Maximum deduction of donation allowances as a percentage of income after every other deduction",
+		Min:         0,
+		Max:         NewConfigValue(100),
+		Default:     NewConfigValue(10),
+	}
+)
+
+// settings are every Setting, in the order they are listed to admins.
+var settings = []Setting{
+	SettingPersonalDeduction,
+	SettingKReceiptDeduction,
+	SettingDonationDeduction,
+	SettingDonationIncomeRate,
+}
+
+// Settings returns every Setting that admins can change.
+func Settings() []Setting {
+	return slices.Clone(settings)
+}
+
+// FindSetting returns the Setting of key, and false when there is none.
+func FindSetting(key string) (Setting, bool) {
+	for _, v := range settings {
+		if v.Key == key {
+			return v, true
+		}
+	}
+
+	return Setting{}, false
 }
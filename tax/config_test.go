@@ -0,0 +1,46 @@
+package tax
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/chuckboliver/assessment-tax/common"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigValueJSON(t *testing.T) {
+	testCases := []struct {
+		value    ConfigValue
+		expected string
+	}{
+		{value: NewConfigValue(10), expected: "10"},
+		{value: ConfigValueOf(common.Money(1250)), expected: "12.5"},
+		{value: ConfigValueOf(common.Money(532125)), expected: "5321.25"},
+		{value: NewConfigValue(0), expected: "0"},
+		{value: NewConfigValue(60000), expected: "60000"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expected, func(t *testing.T) {
+			data, err := json.Marshal(tc.value)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, string(data))
+
+			var actual ConfigValue
+			err = json.Unmarshal(data, &actual)
+			require.NoError(t, err)
+			require.Equal(t, tc.value, actual)
+		})
+	}
+}
+
+func TestConfigValueUnits(t *testing.T) {
+	t.Run("Should return rate of percentage", func(t *testing.T) {
+		require.True(t, decimal.RequireFromString("0.125").Equal(ConfigValueOf(common.Money(1250)).Rate()))
+	})
+
+	t.Run("Should return amount of baht", func(t *testing.T) {
+		require.Equal(t, common.Baht(60000), NewConfigValue(60000).Money())
+	})
+}
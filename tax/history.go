@@ -0,0 +1,161 @@
+package tax
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+var ErrCalculationNotFound = errors.New("calculation not found")
+
+const (
+	defaultCalculationPageLimit = 20
+	maxCalculationPageLimit     = 100
+)
+
+// CalculationSource is the endpoint a calculation was requested through.
+type CalculationSource string
+
+const (
+	CalculationSourceAPI CalculationSource = "api"
+	CalculationSourceCSV CalculationSource = "csv"
+)
+
+// CalculationRecord is a stored calculation, kept as the request and response
+// exchanged with the taxpayer.
+type CalculationRecord struct {
+	ID         string            `json:"id"`
+	TaxpayerID string            `json:"taxpayerId,omitempty"`
+	Source     CalculationSource `json:"source"`
+	Request    json.RawMessage   `json:"request"`
+	Response   json.RawMessage   `json:"response"`
+	CreatedAt  time.Time         `json:"createdAt"`
+}
+
+// CalculationFilter selects a page of the stored calculations of a taxpayer,
+// newest first.
+type CalculationFilter struct {
+	TaxpayerID string
+	Limit      int
+	Offset     int
+}
+
+type CalculationPage struct {
+	Calculations []CalculationRecord `json:"calculations"`
+	Total        int                 `json:"total"`
+	Limit        int                 `json:"limit"`
+	Offset       int                 `json:"offset"`
+}
+
+type CalculationHistoryRepository interface {
+	Save(ctx context.Context, records []CalculationRecord) ([]CalculationRecord, error)
+	FindByID(ctx context.Context, id string) (*CalculationRecord, error)
+	FindAll(ctx context.Context, filter CalculationFilter) ([]CalculationRecord, int, error)
+}
+
+// calculationEntry is one calculation to be recorded. response is the result as
+// returned to the taxpayer.
+type calculationEntry struct {
+	request  calculationRequest
+	response any
+}
+
+// HistoryFailure tells what a CalculationHistoryService does when calculations
+// cannot be stored, e.g. while the database is down.
+type HistoryFailure string
+
+const (
+	// HistoryFailureFail fails the calculation with the error of the store.
+	HistoryFailureFail HistoryFailure = "fail"
+	// HistoryFailureLog only logs the error, so that calculations are still
+	// served without being recorded.
+	HistoryFailureLog HistoryFailure = "log"
+)
+
+type CalculationHistoryService interface {
+	// Record stores the calculations of entries. Under HistoryFailureLog a
+	// failure to store them is only logged and no record is returned.
+	Record(ctx context.Context, source CalculationSource, entries []calculationEntry) ([]CalculationRecord, error)
+	FindByID(ctx context.Context, id string) (CalculationRecord, error)
+	FindAll(ctx context.Context, filter CalculationFilter) (CalculationPage, error)
+}
+
+var _ CalculationHistoryService = (*calculationHistoryService)(nil)
+
+type calculationHistoryService struct {
+	calculationHistoryRepository CalculationHistoryRepository
+	historyFailure               HistoryFailure
+}
+
+// NewCalculationHistoryService returns a CalculationHistoryService that fails to
+// record calculations when they cannot be stored, unless historyFailure is
+// HistoryFailureLog.
+func NewCalculationHistoryService(calculationHistoryRepository CalculationHistoryRepository, historyFailure HistoryFailure) CalculationHistoryService {
+	return &calculationHistoryService{
+		calculationHistoryRepository: calculationHistoryRepository,
+		historyFailure:               historyFailure,
+	}
+}
+
+func (s *calculationHistoryService) Record(ctx context.Context, source CalculationSource, entries []calculationEntry) ([]CalculationRecord, error) {
+	records := make([]CalculationRecord, 0, len(entries))
+	for _, v := range entries {
+		request, err := json.Marshal(v.request)
+		if err != nil {
+			return nil, err
+		}
+
+		response, err := json.Marshal(v.response)
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, CalculationRecord{
+			TaxpayerID: v.request.TaxpayerID,
+			Source:     source,
+			Request:    request,
+			Response:   response,
+		})
+	}
+
+	savedRecords, err := s.calculationHistoryRepository.Save(ctx, records)
+	if err != nil && s.historyFailure == HistoryFailureLog {
+		slog.Error("Failed to record calculations", "source", source, "count", len(records), "err", err)
+		return nil, nil
+	}
+
+	return savedRecords, err
+}
+
+func (s *calculationHistoryService) FindByID(ctx context.Context, id string) (CalculationRecord, error) {
+	record, err := s.calculationHistoryRepository.FindByID(ctx, id)
+	if err != nil {
+		return CalculationRecord{}, err
+	}
+
+	return *record, nil
+}
+
+// FindAll returns a page of stored calculations. A limit out of range is replaced
+// by the default or the maximum page size.
+func (s *calculationHistoryService) FindAll(ctx context.Context, filter CalculationFilter) (CalculationPage, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultCalculationPageLimit
+	}
+	filter.Limit = min(filter.Limit, maxCalculationPageLimit)
+	filter.Offset = max(filter.Offset, 0)
+
+	records, total, err := s.calculationHistoryRepository.FindAll(ctx, filter)
+	if err != nil {
+		return CalculationPage{}, err
+	}
+
+	return CalculationPage{
+		Calculations: records,
+		Total:        total,
+		Limit:        filter.Limit,
+		Offset:       filter.Offset,
+	}, nil
+}
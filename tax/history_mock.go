@@ -0,0 +1,149 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: tax/history.go
+
+// Package tax is a generated GoMock package.
+package tax
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockCalculationHistoryRepository is a mock of CalculationHistoryRepository interface.
+type MockCalculationHistoryRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockCalculationHistoryRepositoryMockRecorder
+}
+
+// MockCalculationHistoryRepositoryMockRecorder is the mock recorder for MockCalculationHistoryRepository.
+type MockCalculationHistoryRepositoryMockRecorder struct {
+	mock *MockCalculationHistoryRepository
+}
+
+// NewMockCalculationHistoryRepository creates a new mock instance.
+func NewMockCalculationHistoryRepository(ctrl *gomock.Controller) *MockCalculationHistoryRepository {
+	mock := &MockCalculationHistoryRepository{ctrl: ctrl}
+	mock.recorder = &MockCalculationHistoryRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCalculationHistoryRepository) EXPECT() *MockCalculationHistoryRepositoryMockRecorder {
+	return m.recorder
+}
+
+// FindAll mocks base method.
+func (m *MockCalculationHistoryRepository) FindAll(ctx context.Context, filter CalculationFilter) ([]CalculationRecord, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindAll", ctx, filter)
+	ret0, _ := ret[0].([]CalculationRecord)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// FindAll indicates an expected call of FindAll.
+func (mr *MockCalculationHistoryRepositoryMockRecorder) FindAll(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAll", reflect.TypeOf((*MockCalculationHistoryRepository)(nil).FindAll), ctx, filter)
+}
+
+// FindByID mocks base method.
+func (m *MockCalculationHistoryRepository) FindByID(ctx context.Context, id string) (*CalculationRecord, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByID", ctx, id)
+	ret0, _ := ret[0].(*CalculationRecord)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByID indicates an expected call of FindByID.
+func (mr *MockCalculationHistoryRepositoryMockRecorder) FindByID(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByID", reflect.TypeOf((*MockCalculationHistoryRepository)(nil).FindByID), ctx, id)
+}
+
+// Save mocks base method.
+func (m *MockCalculationHistoryRepository) Save(ctx context.Context, records []CalculationRecord) ([]CalculationRecord, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Save", ctx, records)
+	ret0, _ := ret[0].([]CalculationRecord)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Save indicates an expected call of Save.
+func (mr *MockCalculationHistoryRepositoryMockRecorder) Save(ctx, records interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockCalculationHistoryRepository)(nil).Save), ctx, records)
+}
+
+// MockCalculationHistoryService is a mock of CalculationHistoryService interface.
+type MockCalculationHistoryService struct {
+	ctrl     *gomock.Controller
+	recorder *MockCalculationHistoryServiceMockRecorder
+}
+
+// MockCalculationHistoryServiceMockRecorder is the mock recorder for MockCalculationHistoryService.
+type MockCalculationHistoryServiceMockRecorder struct {
+	mock *MockCalculationHistoryService
+}
+
+// NewMockCalculationHistoryService creates a new mock instance.
+func NewMockCalculationHistoryService(ctrl *gomock.Controller) *MockCalculationHistoryService {
+	mock := &MockCalculationHistoryService{ctrl: ctrl}
+	mock.recorder = &MockCalculationHistoryServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCalculationHistoryService) EXPECT() *MockCalculationHistoryServiceMockRecorder {
+	return m.recorder
+}
+
+// FindAll mocks base method.
+func (m *MockCalculationHistoryService) FindAll(ctx context.Context, filter CalculationFilter) (CalculationPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindAll", ctx, filter)
+	ret0, _ := ret[0].(CalculationPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindAll indicates an expected call of FindAll.
+func (mr *MockCalculationHistoryServiceMockRecorder) FindAll(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAll", reflect.TypeOf((*MockCalculationHistoryService)(nil).FindAll), ctx, filter)
+}
+
+// FindByID mocks base method.
+func (m *MockCalculationHistoryService) FindByID(ctx context.Context, id string) (CalculationRecord, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByID", ctx, id)
+	ret0, _ := ret[0].(CalculationRecord)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByID indicates an expected call of FindByID.
+func (mr *MockCalculationHistoryServiceMockRecorder) FindByID(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByID", reflect.TypeOf((*MockCalculationHistoryService)(nil).FindByID), ctx, id)
+}
+
+// Record mocks base method.
+func (m *MockCalculationHistoryService) Record(ctx context.Context, source CalculationSource, entries []calculationEntry) ([]CalculationRecord, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Record", ctx, source, entries)
+	ret0, _ := ret[0].([]CalculationRecord)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Record indicates an expected call of Record.
+func (mr *MockCalculationHistoryServiceMockRecorder) Record(ctx, source, entries interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Record", reflect.TypeOf((*MockCalculationHistoryService)(nil).Record), ctx, source, entries)
+}
@@ -0,0 +1,157 @@
+package tax
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/chuckboliver/assessment-tax/common"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordCalculation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	calculationHistoryRepo := NewMockCalculationHistoryRepository(ctrl)
+	service := NewCalculationHistoryService(calculationHistoryRepo, HistoryFailureFail)
+
+	request := calculationRequest{TaxpayerID: "1101700203450", TotalIncome: common.Baht(500000)}
+	response := CalculationResult{TotalIncome: common.Baht(500000), Tax: common.Baht(29000)}
+
+	expectedRequest, err := json.Marshal(request)
+	require.NoError(t, err)
+	expectedResponse, err := json.Marshal(response)
+	require.NoError(t, err)
+
+	expectedRecords := []CalculationRecord{
+		{
+			TaxpayerID: "1101700203450",
+			Source:     CalculationSourceCSV,
+			Request:    expectedRequest,
+			Response:   expectedResponse,
+		},
+	}
+	calculationHistoryRepo.EXPECT().Save(gomock.Any(), expectedRecords).Times(1).Return(expectedRecords, nil)
+
+	records, err := service.Record(context.Background(), CalculationSourceCSV, []calculationEntry{
+		{request: request, response: response},
+	})
+	require.NoError(t, err)
+	require.Equal(t, expectedRecords, records)
+}
+
+func TestRecordCalculationWithSaveError(t *testing.T) {
+	testCases := []struct {
+		name           string
+		historyFailure HistoryFailure
+		isValid        bool
+	}{
+		{
+			name:           "Should return error, given history failure fail",
+			historyFailure: HistoryFailureFail,
+			isValid:        false,
+		},
+		{
+			name:           "Should return no record, given history failure log",
+			historyFailure: HistoryFailureLog,
+			isValid:        true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			calculationHistoryRepo := NewMockCalculationHistoryRepository(ctrl)
+			service := NewCalculationHistoryService(calculationHistoryRepo, tc.historyFailure)
+
+			calculationHistoryRepo.EXPECT().Save(gomock.Any(), gomock.Any()).Times(1).Return(nil, errors.New("connection refused"))
+
+			records, err := service.Record(context.Background(), CalculationSourceAPI, []calculationEntry{
+				{request: calculationRequest{TotalIncome: common.Baht(500000)}, response: CalculationResult{Tax: common.Baht(29000)}},
+			})
+			if !tc.isValid {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Empty(t, records)
+		})
+	}
+}
+
+func TestFindCalculationByID(t *testing.T) {
+	t.Run("Should return calculation, given existing id", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		calculationHistoryRepo := NewMockCalculationHistoryRepository(ctrl)
+		service := NewCalculationHistoryService(calculationHistoryRepo, HistoryFailureFail)
+
+		record := CalculationRecord{ID: "8f0e4c5e-2f6a-4a3b-9d43-6d1c2a7f9b10", Source: CalculationSourceAPI}
+		calculationHistoryRepo.EXPECT().FindByID(gomock.Any(), record.ID).Times(1).Return(&record, nil)
+
+		got, err := service.FindByID(context.Background(), record.ID)
+		require.NoError(t, err)
+		require.Equal(t, record, got)
+	})
+
+	t.Run("Should return ErrCalculationNotFound, given unknown id", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		calculationHistoryRepo := NewMockCalculationHistoryRepository(ctrl)
+		service := NewCalculationHistoryService(calculationHistoryRepo, HistoryFailureFail)
+
+		calculationHistoryRepo.EXPECT().FindByID(gomock.Any(), gomock.Any()).Times(1).Return(nil, ErrCalculationNotFound)
+
+		_, err := service.FindByID(context.Background(), "8f0e4c5e-2f6a-4a3b-9d43-6d1c2a7f9b10")
+		require.True(t, errors.Is(err, ErrCalculationNotFound))
+	})
+}
+
+func TestFindAllCalculations(t *testing.T) {
+	testCases := []struct {
+		name           string
+		filter         CalculationFilter
+		expectedFilter CalculationFilter
+	}{
+		{
+			name:           "Should use default limit, given no limit",
+			filter:         CalculationFilter{TaxpayerID: "1101700203450"},
+			expectedFilter: CalculationFilter{TaxpayerID: "1101700203450", Limit: 20, Offset: 0},
+		},
+		{
+			name:           "Should cap limit, given limit over maximum",
+			filter:         CalculationFilter{Limit: 1000, Offset: 40},
+			expectedFilter: CalculationFilter{Limit: 100, Offset: 40},
+		},
+		{
+			name:           "Should reset offset, given negative offset",
+			filter:         CalculationFilter{Limit: 10, Offset: -1},
+			expectedFilter: CalculationFilter{Limit: 10, Offset: 0},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			calculationHistoryRepo := NewMockCalculationHistoryRepository(ctrl)
+			service := NewCalculationHistoryService(calculationHistoryRepo, HistoryFailureFail)
+
+			records := []CalculationRecord{{ID: "8f0e4c5e-2f6a-4a3b-9d43-6d1c2a7f9b10"}}
+			calculationHistoryRepo.EXPECT().FindAll(gomock.Any(), tc.expectedFilter).Times(1).Return(records, 41, nil)
+
+			page, err := service.FindAll(context.Background(), tc.filter)
+			require.NoError(t, err)
+			require.Equal(t, CalculationPage{
+				Calculations: records,
+				Total:        41,
+				Limit:        tc.expectedFilter.Limit,
+				Offset:       tc.expectedFilter.Offset,
+			}, page)
+		})
+	}
+}
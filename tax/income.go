@@ -0,0 +1,185 @@
+package tax
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/chuckboliver/assessment-tax/common"
+	"github.com/shopspring/decimal"
+)
+
+var ErrTotalIncomeMismatch = errors.New("totalIncome does not match the sum of incomes")
+
+type Income struct {
+	IncomeType IncomeType   `json:"incomeType" validate:"required"`
+	Amount     common.Money `json:"amount" validate:"gte=0,lte=1000000000000"`
+}
+
+// IncomeType is a type of assessable income under section 40 of the Revenue Code.
+type IncomeType string
+
+const (
+	IncomeSalary       IncomeType = "salary"
+	IncomeFreelance    IncomeType = "freelance"
+	IncomeRoyalty      IncomeType = "royalty"
+	IncomeInterest     IncomeType = "interest"
+	IncomeDividend     IncomeType = "dividend"
+	IncomeRental       IncomeType = "rental"
+	IncomeProfessional IncomeType = "professional"
+	IncomeContracting  IncomeType = "contracting"
+	IncomeBusiness     IncomeType = "business"
+)
+
+type IncomeBreakdown struct {
+	IncomeType       IncomeType   `json:"incomeType"`
+	Section          string       `json:"section"`
+	Amount           common.Money `json:"amount"`
+	ExpenseDeduction common.Money `json:"expenseDeduction"`
+	NetIncome        common.Money `json:"netIncome"`
+}
+
+type UnsupportedIncomeTypeError struct {
+	IncomeType     IncomeType
+	SupportedTypes []IncomeType
+}
+
+func (e *UnsupportedIncomeTypeError) Error() string {
+	supportedTypes := make([]string, 0, len(e.SupportedTypes))
+	for _, v := range e.SupportedTypes {
+		supportedTypes = append(supportedTypes, string(v))
+	}
+
+	return fmt.Sprintf("unsupported income type %q, supported types are: %s", e.IncomeType, strings.Join(supportedTypes, ", "))
+}
+
+// expenseGroup shares a combined expense deduction cap between several income types.
+type expenseGroup struct {
+	name      string
+	maxAmount common.Money
+}
+
+// expenseRule is the standard expense deduction of an income type: a percentage of
+// the income, optionally capped together with other income types of the same group.
+type expenseRule struct {
+	incomeType IncomeType
+	section    string
+	rate       decimal.Decimal
+	group      *expenseGroup
+}
+
+// incomeAssessment is the gross income of a request and its standard expense
+// deduction. expenseDeduction is kept unrounded, and the breakdown is rounded to
+// satang for display only.
+type incomeAssessment struct {
+	grossIncome      common.Money
+	nonSalaryIncome  common.Money
+	expenseDeduction decimal.Decimal
+	breakdown        []IncomeBreakdown
+}
+
+func (a incomeAssessment) netIncome() decimal.Decimal {
+	return a.grossIncome.Decimal().Sub(a.expenseDeduction)
+}
+
+func expenseRules() []expenseRule {
+	employment := &expenseGroup{name: "40(1)-40(2)", maxAmount: common.Baht(100000)}
+	royalty := &expenseGroup{name: "40(3)", maxAmount: common.Baht(100000)}
+
+	return []expenseRule{
+		{incomeType: IncomeSalary, section: "40(1)", rate: decimal.RequireFromString("0.5"), group: employment},
+		{incomeType: IncomeFreelance, section: "40(2)", rate: decimal.RequireFromString("0.5"), group: employment},
+		{incomeType: IncomeRoyalty, section: "40(3)", rate: decimal.RequireFromString("0.5"), group: royalty},
+		{incomeType: IncomeInterest, section: "40(4)", rate: decimal.Zero},
+		{incomeType: IncomeDividend, section: "40(4)", rate: decimal.Zero},
+		{incomeType: IncomeRental, section: "40(5)", rate: decimal.RequireFromString("0.3")},
+		{incomeType: IncomeProfessional, section: "40(6)", rate: decimal.RequireFromString("0.3")},
+		{incomeType: IncomeContracting, section: "40(7)", rate: decimal.RequireFromString("0.6")},
+		{incomeType: IncomeBusiness, section: "40(8)", rate: decimal.RequireFromString("0.6")},
+	}
+}
+
+func supportedIncomeTypes() []IncomeType {
+	rules := expenseRules()
+
+	supportedTypes := make([]IncomeType, 0, len(rules))
+	for _, v := range rules {
+		supportedTypes = append(supportedTypes, v.incomeType)
+	}
+	sort.Slice(supportedTypes, func(i, j int) bool {
+		return supportedTypes[i] < supportedTypes[j]
+	})
+
+	return supportedTypes
+}
+
+// assessIncome applies the standard expense deduction of every income type. A request
+// without typed incomes is assessed on totalIncome with no expense deduction.
+func assessIncome(param calculationRequest) (incomeAssessment, error) {
+	if len(param.Incomes) == 0 {
+		return incomeAssessment{
+			grossIncome: param.TotalIncome,
+		}, nil
+	}
+
+	rules := expenseRules()
+
+	amountByType := make(map[IncomeType]common.Money)
+	for _, v := range param.Incomes {
+		amountByType[v.IncomeType] += v.Amount
+	}
+
+	for _, v := range param.Incomes {
+		supported := false
+		for _, rule := range rules {
+			if rule.incomeType == v.IncomeType {
+				supported = true
+				break
+			}
+		}
+
+		if !supported {
+			return incomeAssessment{}, &UnsupportedIncomeTypeError{
+				IncomeType:     v.IncomeType,
+				SupportedTypes: supportedIncomeTypes(),
+			}
+		}
+	}
+
+	assessment := incomeAssessment{
+		breakdown: make([]IncomeBreakdown, 0, len(amountByType)),
+	}
+	usedByGroup := make(map[string]decimal.Decimal)
+	for _, rule := range rules {
+		amount, ok := amountByType[rule.incomeType]
+		if !ok {
+			continue
+		}
+
+		expenseDeduction := amount.Decimal().Mul(rule.rate)
+		if rule.group != nil {
+			expenseDeduction = decimal.Min(expenseDeduction, rule.group.maxAmount.Decimal().Sub(usedByGroup[rule.group.name]))
+			usedByGroup[rule.group.name] = usedByGroup[rule.group.name].Add(expenseDeduction)
+		}
+
+		assessment.grossIncome += amount
+		if rule.incomeType != IncomeSalary {
+			assessment.nonSalaryIncome += amount
+		}
+		assessment.expenseDeduction = assessment.expenseDeduction.Add(expenseDeduction)
+		assessment.breakdown = append(assessment.breakdown, IncomeBreakdown{
+			IncomeType:       rule.incomeType,
+			Section:          rule.section,
+			Amount:           amount,
+			ExpenseDeduction: common.RoundMoney(expenseDeduction),
+			NetIncome:        common.RoundMoney(amount.Decimal().Sub(expenseDeduction)),
+		})
+	}
+
+	if param.TotalIncome != 0 && param.TotalIncome != param.grossIncome() {
+		return incomeAssessment{}, fmt.Errorf("%w: expected %s, got %s", ErrTotalIncomeMismatch, param.grossIncome(), param.TotalIncome)
+	}
+
+	return assessment, nil
+}
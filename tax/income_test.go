@@ -0,0 +1,124 @@
+package tax
+
+import (
+	"testing"
+
+	"github.com/chuckboliver/assessment-tax/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssessIncome(t *testing.T) {
+	testCases := []struct {
+		name                     string
+		arg                      calculationRequest
+		expectedGrossIncome      common.Money
+		expectedExpenseDeduction string
+		expectedBreakdown        []IncomeBreakdown
+	}{
+		{
+			name: "Should not deduct expenses, given only total income",
+			arg: calculationRequest{
+				TotalIncome: common.Baht(500000),
+			},
+			expectedGrossIncome:      common.Baht(500000),
+			expectedExpenseDeduction: "0",
+			expectedBreakdown:        nil,
+		},
+		{
+			name: "Should deduct 50% of salary",
+			arg: calculationRequest{
+				Incomes: []Income{
+					{IncomeType: IncomeSalary, Amount: common.Baht(120000)},
+				},
+			},
+			expectedGrossIncome:      common.Baht(120000),
+			expectedExpenseDeduction: "60000",
+			expectedBreakdown: []IncomeBreakdown{
+				{IncomeType: IncomeSalary, Section: "40(1)", Amount: common.Baht(120000), ExpenseDeduction: common.Baht(60000), NetIncome: common.Baht(60000)},
+			},
+		},
+		{
+			name: "Should cap salary and freelance expenses at combined 100000",
+			arg: calculationRequest{
+				Incomes: []Income{
+					{IncomeType: IncomeFreelance, Amount: common.Baht(100000)},
+					{IncomeType: IncomeSalary, Amount: common.Baht(160000)},
+				},
+			},
+			expectedGrossIncome:      common.Baht(260000),
+			expectedExpenseDeduction: "100000",
+			expectedBreakdown: []IncomeBreakdown{
+				{IncomeType: IncomeSalary, Section: "40(1)", Amount: common.Baht(160000), ExpenseDeduction: common.Baht(80000), NetIncome: common.Baht(80000)},
+				{IncomeType: IncomeFreelance, Section: "40(2)", Amount: common.Baht(100000), ExpenseDeduction: common.Baht(20000), NetIncome: common.Baht(80000)},
+			},
+		},
+		{
+			name: "Should sum incomes of the same type and apply each type's rate",
+			arg: calculationRequest{
+				TotalIncome: common.Baht(400000),
+				Incomes: []Income{
+					{IncomeType: IncomeRental, Amount: common.Baht(100000)},
+					{IncomeType: IncomeRental, Amount: common.Baht(100000)},
+					{IncomeType: IncomeInterest, Amount: common.Baht(50000)},
+					{IncomeType: IncomeBusiness, Amount: common.Baht(150000)},
+				},
+			},
+			expectedGrossIncome:      common.Baht(400000),
+			expectedExpenseDeduction: "150000",
+			expectedBreakdown: []IncomeBreakdown{
+				{IncomeType: IncomeInterest, Section: "40(4)", Amount: common.Baht(50000), ExpenseDeduction: 0, NetIncome: common.Baht(50000)},
+				{IncomeType: IncomeRental, Section: "40(5)", Amount: common.Baht(200000), ExpenseDeduction: common.Baht(60000), NetIncome: common.Baht(140000)},
+				{IncomeType: IncomeBusiness, Section: "40(8)", Amount: common.Baht(150000), ExpenseDeduction: common.Baht(90000), NetIncome: common.Baht(60000)},
+			},
+		},
+		{
+			name: "Should not round expense deduction, but its breakdown",
+			arg: calculationRequest{
+				Incomes: []Income{
+					{IncomeType: IncomeRental, Amount: common.Money(10000001)},
+				},
+			},
+			expectedGrossIncome:      common.Money(10000001),
+			expectedExpenseDeduction: "30000.003",
+			expectedBreakdown: []IncomeBreakdown{
+				{IncomeType: IncomeRental, Section: "40(5)", Amount: common.Money(10000001), ExpenseDeduction: common.Baht(30000), NetIncome: common.Money(7000001)},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assessment, err := assessIncome(tc.arg)
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expectedGrossIncome, assessment.grossIncome)
+			require.Equal(t, tc.expectedExpenseDeduction, assessment.expenseDeduction.String())
+			require.Equal(t, tc.expectedBreakdown, assessment.breakdown)
+		})
+	}
+}
+
+func TestAssessIncomeInvalid(t *testing.T) {
+	t.Run("Should return error, given unsupported income type", func(t *testing.T) {
+		_, err := assessIncome(calculationRequest{
+			Incomes: []Income{
+				{IncomeType: "lottery", Amount: common.Baht(1000)},
+			},
+		})
+
+		var unsupportedIncomeTypeError *UnsupportedIncomeTypeError
+		require.ErrorAs(t, err, &unsupportedIncomeTypeError)
+		require.Contains(t, unsupportedIncomeTypeError.SupportedTypes, IncomeSalary)
+	})
+
+	t.Run("Should return error, given total income not matching incomes", func(t *testing.T) {
+		_, err := assessIncome(calculationRequest{
+			TotalIncome: common.Baht(500000),
+			Incomes: []Income{
+				{IncomeType: IncomeSalary, Amount: common.Baht(400000)},
+			},
+		})
+
+		require.ErrorIs(t, err, ErrTotalIncomeMismatch)
+	})
+}
@@ -0,0 +1,305 @@
+package tax
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/chuckboliver/assessment-tax/common"
+	"github.com/labstack/echo/v4"
+)
+
+var (
+	ErrJobNotFound  = errors.New("job not found")
+	ErrNoPendingJob = errors.New("no pending job")
+	// ErrJobFileNotKept is returned for the rows of a job whose file was dropped,
+	// i.e. a failed job or one completed before files were kept.
+	ErrJobFileNotKept = errors.New("file of job is not kept")
+	// ErrJobLeaseLost is returned when a worker changes a job whose lease expired
+	// and was given to another worker.
+	ErrJobLeaseLost = errors.New("lease of job is lost")
+)
+
+// jobLease is how long a worker holds a job without reporting progress before
+// the job is considered abandoned, e.g. by a stopped server, and is resumed by
+// another worker.
+const jobLease = 5 * time.Minute
+
+type JobStatus string
+
+const (
+	JobStatusPending   JobStatus = "pending"
+	JobStatusRunning   JobStatus = "running"
+	JobStatusCompleted JobStatus = "completed"
+	JobStatusFailed    JobStatus = "failed"
+)
+
+// CalculationJob is an uploaded file calculated in the background. TotalRows is
+// the number of valid rows to calculate, known once the file has been read, and
+// FailedRows the number of invalid rows. ConfigVersion is the version of the tax
+// config the job is calculated with, the latest one when the job starts unless
+// it was submitted with another.
+type CalculationJob struct {
+	ID            string    `json:"id"`
+	Status        JobStatus `json:"status"`
+	Filename      string    `json:"filename"`
+	Partial       bool      `json:"partial"`
+	ConfigVersion int       `json:"configVersion,omitempty"`
+	TotalRows     int       `json:"totalRows"`
+	ProcessedRows int       `json:"processedRows"`
+	FailedRows    int       `json:"failedRows"`
+	Error         string    `json:"error,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+	ContentType   string    `json:"-"`
+	// File is the uploaded file, kept so that a job can be resumed and its result
+	// returned with the columns of the file. It is dropped when the job fails.
+	File []byte `json:"-"`
+	// Errors are the errors of the invalid rows of the file.
+	Errors []RowError `json:"-"`
+	// LeaseOwner identifies the lease of a claimed job. Every change of the job
+	// by the worker holding it is conditioned on it.
+	LeaseOwner string `json:"-"`
+}
+
+type CalculationJobRepository interface {
+	Create(ctx context.Context, job CalculationJob) (CalculationJob, error)
+	FindByID(ctx context.Context, id string) (*CalculationJob, error)
+	// FindFile returns the file of a job, nil once it is dropped.
+	FindFile(ctx context.Context, id string) ([]byte, error)
+	// Claim takes the oldest pending or abandoned job for lease, returning
+	// ErrNoPendingJob when there is none.
+	Claim(ctx context.Context, lease time.Duration) (*CalculationJob, error)
+	// Start stores the rows to calculate and the config version to calculate them
+	// with. Start, SaveResults, Complete and Fail return ErrJobLeaseLost when
+	// owner no longer holds the lease of the job.
+	Start(ctx context.Context, id string, owner string, totalRows int, configVersion int, rowErrors []RowError) error
+	// SaveResults stores the results of a batch of rows, and the errors of its
+	// rows that could not be calculated, and extends the lease.
+	SaveResults(ctx context.Context, id string, owner string, results []CalculationResult, rowErrors []RowError, lease time.Duration) error
+	FindResults(ctx context.Context, id string) ([]CalculationResult, error)
+	Complete(ctx context.Context, id string, owner string) error
+	Fail(ctx context.Context, id string, owner string, message string, rowErrors []RowError) error
+}
+
+type CalculationJobService interface {
+	Submit(ctx context.Context, filename string, contentType string, partial bool, configVersion int, file []byte) (CalculationJob, error)
+	FindByID(ctx context.Context, id string) (CalculationJob, error)
+	FindResults(ctx context.Context, id string) ([]CalculationResult, error)
+	// FindRows reads the rows of the file of job by their line in the file, for
+	// its result to be returned in a format of the file such as CSV.
+	FindRows(ctx context.Context, job CalculationJob) (map[int]parsedRow, error)
+	// Process runs a pending job, returning false when there is none.
+	Process(ctx context.Context) (bool, error)
+}
+
+var _ CalculationJobService = (*calculationJobService)(nil)
+
+type calculationJobService struct {
+	calculationJobRepository  CalculationJobRepository
+	taxCalculator             Calculator
+	calculationHistoryService CalculationHistoryService
+	validator                 echo.Validator
+	maxRows                   int
+	maxFileSize               int64
+}
+
+func NewCalculationJobService(calculationJobRepository CalculationJobRepository, taxCalculator Calculator, calculationHistoryService CalculationHistoryService, validator echo.Validator, maxRows int, maxFileSize int64) CalculationJobService {
+	return &calculationJobService{
+		calculationJobRepository:  calculationJobRepository,
+		taxCalculator:             taxCalculator,
+		calculationHistoryService: calculationHistoryService,
+		validator:                 validator,
+		maxRows:                   maxRows,
+		maxFileSize:               maxFileSize,
+	}
+}
+
+func (s *calculationJobService) Submit(ctx context.Context, filename string, contentType string, partial bool, configVersion int, file []byte) (CalculationJob, error) {
+	return s.calculationJobRepository.Create(ctx, CalculationJob{
+		Status:        JobStatusPending,
+		Filename:      filename,
+		ContentType:   contentType,
+		Partial:       partial,
+		ConfigVersion: configVersion,
+		File:          file,
+	})
+}
+
+func (s *calculationJobService) FindByID(ctx context.Context, id string) (CalculationJob, error) {
+	job, err := s.calculationJobRepository.FindByID(ctx, id)
+	if err != nil {
+		return CalculationJob{}, err
+	}
+
+	return *job, nil
+}
+
+func (s *calculationJobService) FindResults(ctx context.Context, id string) ([]CalculationResult, error) {
+	return s.calculationJobRepository.FindResults(ctx, id)
+}
+
+func (s *calculationJobService) FindRows(ctx context.Context, job CalculationJob) (map[int]parsedRow, error) {
+	file, err := s.calculationJobRepository.FindFile(ctx, job.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if file == nil {
+		return nil, fmt.Errorf("%w: %s", ErrJobFileNotKept, job.ID)
+	}
+
+	parser, ok := parserFor(job.Filename, job.ContentType)
+	if !ok {
+		return nil, unsupportedFileError(job.Filename)
+	}
+
+	rows := make(map[int]parsedRow)
+	err = limitUnzipSize(parser, s.maxFileSize).readRows(bytes.NewReader(file), func(row parsedRow, _ []RowError) error {
+		rows[row.row] = row
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
+
+func (s *calculationJobService) Process(ctx context.Context) (bool, error) {
+	job, err := s.calculationJobRepository.Claim(ctx, jobLease)
+	if errors.Is(err, ErrNoPendingJob) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	err = s.run(ctx, *job)
+	if err == nil {
+		return true, s.calculationJobRepository.Complete(ctx, job.ID, job.LeaseOwner)
+	}
+
+	// The job is left running to be resumed once its lease is over, by when the
+	// config can hopefully be read again. A job whose lease was lost is left to
+	// the worker that resumed it.
+	if errors.Is(err, ErrConfigUnavailable) || errors.Is(err, ErrJobLeaseLost) {
+		return true, err
+	}
+
+	// A job fails with the error its upload would get from the synchronous
+	// endpoints, or an internal error that is only logged.
+	message := "internal server error"
+	var rowErrors []RowError
+	var e *common.Error
+	if errors.As(err, &e) && e.Status < 500 {
+		message = e.Message
+		rowErrors, _ = e.Details.([]RowError)
+	} else {
+		slog.Error("Failed to process calculation job", "id", job.ID, "err", err)
+	}
+
+	return true, s.calculationJobRepository.Fail(ctx, job.ID, job.LeaseOwner, message, rowErrors)
+}
+
+// run calculates the rows of job a batch at a time, starting after the rows
+// already processed when the job is resumed.
+func (s *calculationJobService) run(ctx context.Context, job CalculationJob) error {
+	parser, ok := parserFor(job.Filename, job.ContentType)
+	if !ok {
+		return unsupportedFileError(job.Filename)
+	}
+
+	parsedRows, rowErrors, err := parseCalculationRequest(limitRows(limitUnzipSize(parser, s.maxFileSize), s.maxRows), bytes.NewReader(job.File))
+	if err != nil {
+		return uploadError(err)
+	}
+
+	validRows, rowErrors, err := validateRows(s.validator, parsedRows, rowErrors)
+	if err != nil {
+		return err
+	}
+
+	if len(rowErrors) > 0 && !job.Partial {
+		return invalidRowsError(rowErrors)
+	}
+
+	// The version is kept with the job, so that a resumed job is calculated with
+	// the same config as its first rows.
+	if job.ConfigVersion == 0 {
+		job.ConfigVersion, err = s.taxCalculator.LatestConfigVersion(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := s.calculationJobRepository.Start(ctx, job.ID, job.LeaseOwner, len(validRows), job.ConfigVersion, rowErrors); err != nil {
+		return err
+	}
+
+	for start := job.ProcessedRows; start < len(validRows); start += uploadStreamBatchSize {
+		batch := validRows[start:min(start+uploadStreamBatchSize, len(validRows))]
+		for i := range batch {
+			batch[i].request = withConfigVersion(batch[i].request, job.ConfigVersion)
+		}
+
+		calculated, taxes, batchRowErrors, err := s.calculateBatch(ctx, job, batch)
+		if err != nil {
+			return err
+		}
+
+		entries := make([]calculationEntry, 0, len(calculated))
+		for i, v := range calculated {
+			entries = append(entries, calculationEntry{request: v.request, response: taxes[i]})
+		}
+
+		// History is recorded only once the results are stored, so that a worker
+		// whose lease was lost does not record the rows of the worker resuming it.
+		if err := s.calculationJobRepository.SaveResults(ctx, job.ID, job.LeaseOwner, taxes, batchRowErrors, jobLease); err != nil {
+			return err
+		}
+
+		if len(entries) > 0 {
+			if _, err := s.calculationHistoryService.Record(ctx, CalculationSourceCSV, entries); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// calculateBatch calculates a batch of the rows of job, returning the rows that
+// were calculated with their results. The rows of a partial job that cannot be
+// calculated, e.g. those of an unknown tax year, are returned as row errors
+// instead of failing the job.
+func (s *calculationJobService) calculateBatch(ctx context.Context, job CalculationJob, batch []parsedRow) ([]parsedRow, []CalculationResult, []RowError, error) {
+	if job.Partial {
+		results, calculationErrors, err := calculateRows(ctx, s.taxCalculator, batch)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		calculated, taxes, rowErrors := calculatedRows(batch, results, calculationErrors)
+		return calculated, taxes, rowErrors, nil
+	}
+
+	requests := make([]calculationRequest, 0, len(batch))
+	for _, v := range batch {
+		requests = append(requests, v.request)
+	}
+
+	result, err := s.taxCalculator.BatchCalculate(ctx, requests)
+	if err != nil {
+		return nil, nil, nil, calculationError(err)
+	}
+
+	for i, v := range batch {
+		result.Taxes[i].Row = v.row
+	}
+
+	return batch, result.Taxes, nil, nil
+}
@@ -0,0 +1,252 @@
+package tax
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/chuckboliver/assessment-tax/common"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+var _ common.Controller = (*JobController)(nil)
+
+const (
+	ErrorCodeJobNotFinished common.ErrorCode = "JOB_NOT_FINISHED"
+	ErrorCodeJobFailed      common.ErrorCode = "JOB_FAILED"
+	ErrorCodeJobFileNotKept common.ErrorCode = "JOB_FILE_NOT_KEPT"
+)
+
+type JobController struct {
+	calculationJobService CalculationJobService
+	appConfig             common.AppConfig
+}
+
+func NewJobController(calculationJobService CalculationJobService, appConfig common.AppConfig) JobController {
+	return JobController{
+		calculationJobService: calculationJobService,
+		appConfig:             appConfig,
+	}
+}
+
+func (c *JobController) RouteConfig(e *echo.Echo) {
+	uploadMiddlewares := make([]echo.MiddlewareFunc, 0)
+	if c.appConfig.MaxUploadSize != "" {
+		uploadMiddlewares = append(uploadMiddlewares, middleware.BodyLimit(c.appConfig.MaxUploadSize))
+	}
+
+	group := e.Group("/tax/jobs")
+	{
+		group.POST("", c.submitJob, uploadMiddlewares...)
+
+		// Jobs hold the same data as stored calculations, so that they are read
+		// by admins only as well.
+		adminAuth := common.AdminBasicAuth(c.appConfig)
+		group.GET("/:id", c.getJob, adminAuth)
+		group.GET("/:id/result", c.getJobResult, adminAuth)
+	}
+}
+
+// submitJob accepts an upload like POST /tax/calculations/upload, to be
+// calculated in the background.
+func (c *JobController) submitJob(ctx echo.Context) error {
+	fileHeader, err := formFile(ctx, "taxFile")
+	if err != nil {
+		return err
+	}
+
+	contentType := fileHeader.Header.Get(echo.HeaderContentType)
+	if _, ok := parserFor(fileHeader.Filename, contentType); !ok {
+		return unsupportedFileError(fileHeader.Filename)
+	}
+
+	var partial bool
+	var configVersion int
+	err = echo.QueryParamsBinder(ctx).
+		Bool("partial", &partial).
+		Int("configVersion", &configVersion).
+		BindError()
+	if err != nil {
+		return err
+	}
+
+	multipartFile, err := fileHeader.Open()
+	if err != nil {
+		return common.NewError(http.StatusBadRequest, common.ErrorCodeMalformedRequest, err)
+	}
+	defer multipartFile.Close()
+
+	file, err := io.ReadAll(multipartFile)
+	if err != nil {
+		return common.NewError(http.StatusBadRequest, common.ErrorCodeMalformedRequest, err)
+	}
+
+	job, err := c.calculationJobService.Submit(ctx.Request().Context(), fileHeader.Filename, contentType, partial, configVersion, file)
+	if err != nil {
+		return fmt.Errorf("submit job: %w", err)
+	}
+
+	ctx.Response().Header().Set(echo.HeaderLocation, "/tax/jobs/"+job.ID)
+	return ctx.JSON(http.StatusAccepted, job)
+}
+
+type getJobRequest struct {
+	ID string `param:"id" validate:"uuid"`
+}
+
+func (c *JobController) findJob(ctx echo.Context) (CalculationJob, error) {
+	var request getJobRequest
+	if err := ctx.Bind(&request); err != nil {
+		return CalculationJob{}, err
+	}
+
+	// No job can have an ID that is not a UUID.
+	if err := ctx.Validate(&request); err != nil {
+		return CalculationJob{}, jobNotFoundError(ErrJobNotFound)
+	}
+
+	job, err := c.calculationJobService.FindByID(ctx.Request().Context(), request.ID)
+	if errors.Is(err, ErrJobNotFound) {
+		return CalculationJob{}, jobNotFoundError(err)
+	}
+	if err != nil {
+		return CalculationJob{}, fmt.Errorf("get job: %w", err)
+	}
+
+	return job, nil
+}
+
+func (c *JobController) getJob(ctx echo.Context) error {
+	job, err := c.findJob(ctx)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, job)
+}
+
+// getJobResult returns the result of a completed job in the same forms as the
+// synchronous upload endpoints, chosen by resultFormatFor.
+func (c *JobController) getJobResult(ctx echo.Context) error {
+	job, err := c.findJob(ctx)
+	if err != nil {
+		return err
+	}
+
+	var includeTaxLevel bool
+	if err := echo.QueryParamsBinder(ctx).Bool("includeTaxLevel", &includeTaxLevel).BindError(); err != nil {
+		return err
+	}
+
+	switch job.Status {
+	case JobStatusCompleted:
+	case JobStatusFailed:
+		return &common.Error{
+			Status:    http.StatusUnprocessableEntity,
+			Code:      ErrorCodeJobFailed,
+			Message:   job.Error,
+			MessageTH: "การคำนวนภาษีล้มเหลว",
+			Details:   job.Errors,
+		}
+	default:
+		err := fmt.Errorf("job is %s", job.Status)
+		return common.NewError(http.StatusConflict, ErrorCodeJobNotFinished, err).
+			WithMessageTH("การคำนวนภาษียังไม่เสร็จสิ้น")
+	}
+
+	taxes, err := c.calculationJobService.FindResults(ctx.Request().Context(), job.ID)
+	if err != nil {
+		return fmt.Errorf("get job result: %w", err)
+	}
+
+	result := BatchCalculationResult{
+		Taxes:   taxes,
+		Summary: newBatchSummary(taxes),
+	}
+	if len(job.Errors) > 0 {
+		result.SkippedRows = skippedRows(job.Errors)
+		result.Errors = job.Errors
+	}
+
+	format, _ := resultFormatFor(ctx.Request().Header.Get(echo.HeaderAccept))
+	if format.newResultWriter != nil || format.renderer != nil {
+		rowByLine, err := c.calculationJobService.FindRows(ctx.Request().Context(), job)
+		if errors.Is(err, ErrJobFileNotKept) {
+			err := fmt.Errorf("%w, its result is only available as JSON", err)
+			return common.NewError(http.StatusNotAcceptable, ErrorCodeJobFileNotKept, err).
+				WithMessageTH("ผลลัพธ์ของงานคำนวนภาษีนี้มีเฉพาะแบบ JSON")
+		}
+		if err != nil {
+			return fmt.Errorf("get job rows: %w", err)
+		}
+
+		rows := make([]parsedRow, 0, len(taxes))
+		for _, v := range taxes {
+			row, ok := rowByLine[v.Row]
+			if !ok {
+				row = parsedRow{row: v.Row}
+			}
+			rows = append(rows, row)
+		}
+
+		if format.renderer != nil {
+			return renderBatchResult(ctx, format, rows, result)
+		}
+
+		writer := format.newResultWriter(ctx.Response(), resultOptions{includeTaxLevel: includeTaxLevel})
+		return writeJobResult(ctx, format.mediaType, writer, rows, result, rowByLine)
+	}
+
+	if !includeTaxLevel {
+		withoutTaxLevels(result.Taxes)
+	}
+
+	return ctx.JSON(http.StatusOK, result)
+}
+
+// writeJobResult writes the results and the row errors of a job in the order of
+// its file, as a streamed upload would. rows are the calculated rows, in the order
+// of result.Taxes, and rowByLine every row of the file.
+func writeJobResult(ctx echo.Context, mediaType string, writer resultWriter, rows []parsedRow, result BatchCalculationResult, rowByLine map[int]parsedRow) error {
+	ctx.Response().Header().Set(echo.HeaderContentType, mediaType)
+	ctx.Response().WriteHeader(http.StatusOK)
+
+	// Both results and row errors are sorted by row, and a row has either a result
+	// or errors.
+	rowErrors := result.Errors
+	i := 0
+	for i < len(result.Taxes) || len(rowErrors) > 0 {
+		if i < len(result.Taxes) && (len(rowErrors) == 0 || result.Taxes[i].Row < rowErrors[0].Row) {
+			if err := writer.writeResult(rows[i], result.Taxes[i]); err != nil {
+				return err
+			}
+			i++
+			continue
+		}
+
+		line := rowErrors[0].Row
+		end := 1
+		for end < len(rowErrors) && rowErrors[end].Row == line {
+			end++
+		}
+
+		row, ok := rowByLine[line]
+		if !ok {
+			row = parsedRow{row: line}
+		}
+
+		if err := writer.writeRowErrors(row, rowErrors[:end]); err != nil {
+			return err
+		}
+		rowErrors = rowErrors[end:]
+	}
+
+	return flushStream(ctx, writer)
+}
+
+func jobNotFoundError(err error) error {
+	return common.NewError(http.StatusNotFound, common.ErrorCodeNotFound, err).
+		WithMessageTH("ไม่พบงานคำนวนภาษี")
+}
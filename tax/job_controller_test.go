@@ -0,0 +1,334 @@
+package tax
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/chuckboliver/assessment-tax/common"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+const testJobID = "0b7c1f0e-3f4d-4c52-8f7e-2a1d9c6b5e40"
+
+func TestPostJob(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	file := "totalIncome\n500000\n"
+
+	calculationJobService := NewMockCalculationJobService(ctrl)
+	calculationJobService.EXPECT().Submit(gomock.Any(), "taxes.csv", "application/octet-stream", true, 0, []byte(file)).Times(1).Return(CalculationJob{
+		ID:       testJobID,
+		Status:   JobStatusPending,
+		Filename: "taxes.csv",
+		Partial:  true,
+	}, nil)
+
+	e := common.NewConfiguredEcho()
+	jobController := NewJobController(calculationJobService, common.AppConfig{})
+	jobController.RouteConfig(e)
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("taxFile", "taxes.csv")
+	require.NoError(t, err)
+	_, err = part.Write([]byte(file))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	request, err := http.NewRequest(http.MethodPost, "/tax/jobs?partial=true", &body)
+	require.NoError(t, err)
+
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+
+	recorder := httptest.NewRecorder()
+	e.ServeHTTP(recorder, request)
+
+	require.Equal(t, http.StatusAccepted, recorder.Code)
+	require.Equal(t, "/tax/jobs/"+testJobID, recorder.Header().Get("Location"))
+
+	var response CalculationJob
+	err = json.Unmarshal(recorder.Body.Bytes(), &response)
+	require.NoError(t, err)
+	require.Equal(t, testJobID, response.ID)
+	require.Equal(t, JobStatusPending, response.Status)
+}
+
+func TestGetJob(t *testing.T) {
+	testCases := []struct {
+		name         string
+		id           string
+		withoutAuth  bool
+		mock         func(calculationJobService *MockCalculationJobService)
+		expectedCode int
+	}{
+		{
+			name: "Should return job, given existing id",
+			id:   testJobID,
+			mock: func(calculationJobService *MockCalculationJobService) {
+				calculationJobService.EXPECT().FindByID(gomock.Any(), testJobID).Times(1).Return(CalculationJob{
+					ID:            testJobID,
+					Status:        JobStatusRunning,
+					TotalRows:     1000,
+					ProcessedRows: 500,
+				}, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name: "Should return not found, given unknown id",
+			id:   testJobID,
+			mock: func(calculationJobService *MockCalculationJobService) {
+				calculationJobService.EXPECT().FindByID(gomock.Any(), testJobID).Times(1).Return(CalculationJob{}, ErrJobNotFound)
+			},
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name: "Should return not found, given id that is not a UUID",
+			id:   "1",
+			mock: func(calculationJobService *MockCalculationJobService) {
+				calculationJobService.EXPECT().FindByID(gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:        "Should return 401, given no admin credentials",
+			id:          testJobID,
+			withoutAuth: true,
+			mock: func(calculationJobService *MockCalculationJobService) {
+				calculationJobService.EXPECT().FindByID(gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectedCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			calculationJobService := NewMockCalculationJobService(ctrl)
+			tc.mock(calculationJobService)
+
+			e := common.NewConfiguredEcho()
+			jobController := NewJobController(calculationJobService, historyAppConfig)
+			jobController.RouteConfig(e)
+
+			request, err := http.NewRequest(http.MethodGet, "/tax/jobs/"+tc.id, nil)
+			require.NoError(t, err)
+
+			if !tc.withoutAuth {
+				request.SetBasicAuth("admin", "P@ssw0rd")
+			}
+
+			recorder := httptest.NewRecorder()
+			e.ServeHTTP(recorder, request)
+
+			require.Equal(t, tc.expectedCode, recorder.Code)
+		})
+	}
+}
+
+func TestGetJobResult(t *testing.T) {
+	testCases := []struct {
+		name         string
+		accept       string
+		withoutAuth  bool
+		mock         func(calculationJobService *MockCalculationJobService)
+		expectedCode int
+		checkBody    func(t *testing.T, body []byte)
+	}{
+		{
+			name: "Should return results with skipped rows, given completed partial job",
+			mock: func(calculationJobService *MockCalculationJobService) {
+				calculationJobService.EXPECT().FindByID(gomock.Any(), testJobID).Times(1).Return(CalculationJob{
+					ID:     testJobID,
+					Status: JobStatusCompleted,
+					Errors: []RowError{{Row: 3, Column: "wht", Value: "abc", Error: `failed to parse wht: invalid amount "abc"`}},
+				}, nil)
+				calculationJobService.EXPECT().FindResults(gomock.Any(), testJobID).Times(1).Return([]CalculationResult{
+					{Row: 2, TotalIncome: common.Baht(500000), Tax: common.Baht(29000)},
+				}, nil)
+			},
+			expectedCode: http.StatusOK,
+			checkBody: func(t *testing.T, body []byte) {
+				var response BatchCalculationResult
+				err := json.Unmarshal(body, &response)
+				require.NoError(t, err)
+
+				require.Equal(t, []CalculationResult{{Row: 2, TotalIncome: common.Baht(500000), Tax: common.Baht(29000)}}, response.Taxes)
+				require.Equal(t, []int{3}, response.SkippedRows)
+			},
+		},
+		{
+			name:   "Should return file back as CSV with row errors in place, given Accept text/csv",
+			accept: "text/csv",
+			mock: func(calculationJobService *MockCalculationJobService) {
+				job := completedPartialJob()
+				calculationJobService.EXPECT().FindByID(gomock.Any(), testJobID).Times(1).Return(job, nil)
+				calculationJobService.EXPECT().FindResults(gomock.Any(), testJobID).Times(1).Return(completedPartialJobResults(), nil)
+				calculationJobService.EXPECT().FindRows(gomock.Any(), job).Times(1).Return(completedPartialJobRows(t), nil)
+			},
+			expectedCode: http.StatusOK,
+			checkBody: func(t *testing.T, body []byte) {
+				require.Equal(t, `row,totalIncome,wht,tax,taxRefund,"tax 0-150,000","tax 150,001-500,000",error
+2,500000,0,29000.0,0.0,0.0,29000.0,
+3,600000,abc,,,,,"failed to parse wht: invalid amount ""abc"""
+4,100000,0,0.0,0.0,0.0,,
+`, string(body))
+			},
+		},
+		{
+			name:   "Should return PDF summary, given PDF listed before CSV",
+			accept: "application/pdf, text/csv",
+			mock: func(calculationJobService *MockCalculationJobService) {
+				job := completedPartialJob()
+				calculationJobService.EXPECT().FindByID(gomock.Any(), testJobID).Times(1).Return(job, nil)
+				calculationJobService.EXPECT().FindResults(gomock.Any(), testJobID).Times(1).Return(completedPartialJobResults(), nil)
+				calculationJobService.EXPECT().FindRows(gomock.Any(), job).Times(1).Return(completedPartialJobRows(t), nil)
+			},
+			expectedCode: http.StatusOK,
+			checkBody: func(t *testing.T, body []byte) {
+				require.True(t, bytes.HasPrefix(body, []byte("%PDF")))
+			},
+		},
+		{
+			name:   "Should return not acceptable, given Accept text/csv and dropped file",
+			accept: "text/csv",
+			mock: func(calculationJobService *MockCalculationJobService) {
+				job := completedPartialJob()
+				calculationJobService.EXPECT().FindByID(gomock.Any(), testJobID).Times(1).Return(job, nil)
+				calculationJobService.EXPECT().FindResults(gomock.Any(), testJobID).Times(1).Return(completedPartialJobResults(), nil)
+				calculationJobService.EXPECT().FindRows(gomock.Any(), job).Times(1).Return(nil, ErrJobFileNotKept)
+			},
+			expectedCode: http.StatusNotAcceptable,
+			checkBody: func(t *testing.T, body []byte) {
+				var response common.ErrorResponse
+				err := json.Unmarshal(body, &response)
+				require.NoError(t, err)
+
+				require.Equal(t, ErrorCodeJobFileNotKept, response.Code)
+			},
+		},
+		{
+			name: "Should return conflict, given running job",
+			mock: func(calculationJobService *MockCalculationJobService) {
+				calculationJobService.EXPECT().FindByID(gomock.Any(), testJobID).Times(1).Return(CalculationJob{ID: testJobID, Status: JobStatusRunning}, nil)
+				calculationJobService.EXPECT().FindResults(gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectedCode: http.StatusConflict,
+			checkBody: func(t *testing.T, body []byte) {
+				var response common.ErrorResponse
+				err := json.Unmarshal(body, &response)
+				require.NoError(t, err)
+
+				require.Equal(t, ErrorCodeJobNotFinished, response.Code)
+			},
+		},
+		{
+			name: "Should return error of job, given failed job",
+			mock: func(calculationJobService *MockCalculationJobService) {
+				calculationJobService.EXPECT().FindByID(gomock.Any(), testJobID).Times(1).Return(CalculationJob{
+					ID:     testJobID,
+					Status: JobStatusFailed,
+					Error:  "invalid rows in file: 1",
+					Errors: []RowError{{Row: 2, Error: "expected 2 cells, got 1"}},
+				}, nil)
+			},
+			expectedCode: http.StatusUnprocessableEntity,
+			checkBody: func(t *testing.T, body []byte) {
+				var response struct {
+					Code    common.ErrorCode `json:"code"`
+					Message string           `json:"message"`
+					Details []RowError       `json:"details"`
+				}
+				err := json.Unmarshal(body, &response)
+				require.NoError(t, err)
+
+				require.Equal(t, ErrorCodeJobFailed, response.Code)
+				require.Equal(t, "invalid rows in file: 1", response.Message)
+				require.Equal(t, []RowError{{Row: 2, Error: "expected 2 cells, got 1"}}, response.Details)
+			},
+		},
+		{
+			name:        "Should return 401, given no admin credentials",
+			withoutAuth: true,
+			mock: func(calculationJobService *MockCalculationJobService) {
+				calculationJobService.EXPECT().FindByID(gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectedCode: http.StatusUnauthorized,
+			checkBody:    func(t *testing.T, body []byte) {},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			calculationJobService := NewMockCalculationJobService(ctrl)
+			tc.mock(calculationJobService)
+
+			e := common.NewConfiguredEcho()
+			jobController := NewJobController(calculationJobService, historyAppConfig)
+			jobController.RouteConfig(e)
+
+			request, err := http.NewRequest(http.MethodGet, "/tax/jobs/"+testJobID+"/result", nil)
+			require.NoError(t, err)
+
+			request.Header.Set("Accept", tc.accept)
+			if !tc.withoutAuth {
+				request.SetBasicAuth("admin", "P@ssw0rd")
+			}
+
+			recorder := httptest.NewRecorder()
+			e.ServeHTTP(recorder, request)
+
+			require.Equal(t, tc.expectedCode, recorder.Code)
+			tc.checkBody(t, recorder.Body.Bytes())
+		})
+	}
+}
+
+const completedPartialJobFile = "totalIncome,wht\n500000,0\n600000,abc\n100000,0\n"
+
+func completedPartialJob() CalculationJob {
+	return CalculationJob{
+		ID:          testJobID,
+		Status:      JobStatusCompleted,
+		Filename:    "taxes.csv",
+		ContentType: "text/csv",
+		Partial:     true,
+		Errors:      []RowError{{Row: 3, Column: "wht", Value: "abc", Error: `failed to parse wht: invalid amount "abc"`}},
+	}
+}
+
+func completedPartialJobResults() []CalculationResult {
+	return []CalculationResult{
+		{Row: 2, TaxYear: 2567, TotalIncome: common.Baht(500000), Tax: common.Baht(29000), TaxLevels: []TaxLevel{
+			{Level: "0-150,000", Tax: 0},
+			{Level: "150,001-500,000", Tax: common.Baht(29000)},
+		}},
+		{Row: 4, TaxYear: 2567, TotalIncome: common.Baht(100000), TaxLevels: []TaxLevel{
+			{Level: "0-150,000", Tax: 0},
+		}},
+	}
+}
+
+// completedPartialJobRows are the rows of completedPartialJobFile by line.
+func completedPartialJobRows(t *testing.T) map[int]parsedRow {
+	rows := make(map[int]parsedRow)
+	err := newCSVParser().readRows(strings.NewReader(completedPartialJobFile), func(row parsedRow, _ []RowError) error {
+		rows[row.row] = row
+		return nil
+	})
+	require.NoError(t, err)
+
+	return rows
+}
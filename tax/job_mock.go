@@ -0,0 +1,265 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: tax/job.go
+
+// Package tax is a generated GoMock package.
+package tax
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockCalculationJobRepository is a mock of CalculationJobRepository interface.
+type MockCalculationJobRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockCalculationJobRepositoryMockRecorder
+}
+
+// MockCalculationJobRepositoryMockRecorder is the mock recorder for MockCalculationJobRepository.
+type MockCalculationJobRepositoryMockRecorder struct {
+	mock *MockCalculationJobRepository
+}
+
+// NewMockCalculationJobRepository creates a new mock instance.
+func NewMockCalculationJobRepository(ctrl *gomock.Controller) *MockCalculationJobRepository {
+	mock := &MockCalculationJobRepository{ctrl: ctrl}
+	mock.recorder = &MockCalculationJobRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCalculationJobRepository) EXPECT() *MockCalculationJobRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Claim mocks base method.
+func (m *MockCalculationJobRepository) Claim(ctx context.Context, lease time.Duration) (*CalculationJob, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Claim", ctx, lease)
+	ret0, _ := ret[0].(*CalculationJob)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Claim indicates an expected call of Claim.
+func (mr *MockCalculationJobRepositoryMockRecorder) Claim(ctx, lease interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Claim", reflect.TypeOf((*MockCalculationJobRepository)(nil).Claim), ctx, lease)
+}
+
+// Complete mocks base method.
+func (m *MockCalculationJobRepository) Complete(ctx context.Context, id, owner string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Complete", ctx, id, owner)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Complete indicates an expected call of Complete.
+func (mr *MockCalculationJobRepositoryMockRecorder) Complete(ctx, id, owner interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Complete", reflect.TypeOf((*MockCalculationJobRepository)(nil).Complete), ctx, id, owner)
+}
+
+// Create mocks base method.
+func (m *MockCalculationJobRepository) Create(ctx context.Context, job CalculationJob) (CalculationJob, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", ctx, job)
+	ret0, _ := ret[0].(CalculationJob)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockCalculationJobRepositoryMockRecorder) Create(ctx, job interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockCalculationJobRepository)(nil).Create), ctx, job)
+}
+
+// Fail mocks base method.
+func (m *MockCalculationJobRepository) Fail(ctx context.Context, id, owner, message string, rowErrors []RowError) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Fail", ctx, id, owner, message, rowErrors)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Fail indicates an expected call of Fail.
+func (mr *MockCalculationJobRepositoryMockRecorder) Fail(ctx, id, owner, message, rowErrors interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Fail", reflect.TypeOf((*MockCalculationJobRepository)(nil).Fail), ctx, id, owner, message, rowErrors)
+}
+
+// FindByID mocks base method.
+func (m *MockCalculationJobRepository) FindByID(ctx context.Context, id string) (*CalculationJob, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByID", ctx, id)
+	ret0, _ := ret[0].(*CalculationJob)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByID indicates an expected call of FindByID.
+func (mr *MockCalculationJobRepositoryMockRecorder) FindByID(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByID", reflect.TypeOf((*MockCalculationJobRepository)(nil).FindByID), ctx, id)
+}
+
+// FindFile mocks base method.
+func (m *MockCalculationJobRepository) FindFile(ctx context.Context, id string) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindFile", ctx, id)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindFile indicates an expected call of FindFile.
+func (mr *MockCalculationJobRepositoryMockRecorder) FindFile(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindFile", reflect.TypeOf((*MockCalculationJobRepository)(nil).FindFile), ctx, id)
+}
+
+// FindResults mocks base method.
+func (m *MockCalculationJobRepository) FindResults(ctx context.Context, id string) ([]CalculationResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindResults", ctx, id)
+	ret0, _ := ret[0].([]CalculationResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindResults indicates an expected call of FindResults.
+func (mr *MockCalculationJobRepositoryMockRecorder) FindResults(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindResults", reflect.TypeOf((*MockCalculationJobRepository)(nil).FindResults), ctx, id)
+}
+
+// SaveResults mocks base method.
+func (m *MockCalculationJobRepository) SaveResults(ctx context.Context, id, owner string, results []CalculationResult, rowErrors []RowError, lease time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveResults", ctx, id, owner, results, rowErrors, lease)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveResults indicates an expected call of SaveResults.
+func (mr *MockCalculationJobRepositoryMockRecorder) SaveResults(ctx, id, owner, results, rowErrors, lease interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveResults", reflect.TypeOf((*MockCalculationJobRepository)(nil).SaveResults), ctx, id, owner, results, rowErrors, lease)
+}
+
+// Start mocks base method.
+func (m *MockCalculationJobRepository) Start(ctx context.Context, id, owner string, totalRows, configVersion int, rowErrors []RowError) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Start", ctx, id, owner, totalRows, configVersion, rowErrors)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Start indicates an expected call of Start.
+func (mr *MockCalculationJobRepositoryMockRecorder) Start(ctx, id, owner, totalRows, configVersion, rowErrors interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Start", reflect.TypeOf((*MockCalculationJobRepository)(nil).Start), ctx, id, owner, totalRows, configVersion, rowErrors)
+}
+
+// MockCalculationJobService is a mock of CalculationJobService interface.
+type MockCalculationJobService struct {
+	ctrl     *gomock.Controller
+	recorder *MockCalculationJobServiceMockRecorder
+}
+
+// MockCalculationJobServiceMockRecorder is the mock recorder for MockCalculationJobService.
+type MockCalculationJobServiceMockRecorder struct {
+	mock *MockCalculationJobService
+}
+
+// NewMockCalculationJobService creates a new mock instance.
+func NewMockCalculationJobService(ctrl *gomock.Controller) *MockCalculationJobService {
+	mock := &MockCalculationJobService{ctrl: ctrl}
+	mock.recorder = &MockCalculationJobServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCalculationJobService) EXPECT() *MockCalculationJobServiceMockRecorder {
+	return m.recorder
+}
+
+// FindByID mocks base method.
+func (m *MockCalculationJobService) FindByID(ctx context.Context, id string) (CalculationJob, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByID", ctx, id)
+	ret0, _ := ret[0].(CalculationJob)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByID indicates an expected call of FindByID.
+func (mr *MockCalculationJobServiceMockRecorder) FindByID(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByID", reflect.TypeOf((*MockCalculationJobService)(nil).FindByID), ctx, id)
+}
+
+// FindResults mocks base method.
+func (m *MockCalculationJobService) FindResults(ctx context.Context, id string) ([]CalculationResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindResults", ctx, id)
+	ret0, _ := ret[0].([]CalculationResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindResults indicates an expected call of FindResults.
+func (mr *MockCalculationJobServiceMockRecorder) FindResults(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindResults", reflect.TypeOf((*MockCalculationJobService)(nil).FindResults), ctx, id)
+}
+
+// FindRows mocks base method.
+func (m *MockCalculationJobService) FindRows(ctx context.Context, job CalculationJob) (map[int]parsedRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindRows", ctx, job)
+	ret0, _ := ret[0].(map[int]parsedRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindRows indicates an expected call of FindRows.
+func (mr *MockCalculationJobServiceMockRecorder) FindRows(ctx, job interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindRows", reflect.TypeOf((*MockCalculationJobService)(nil).FindRows), ctx, job)
+}
+
+// Process mocks base method.
+func (m *MockCalculationJobService) Process(ctx context.Context) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Process", ctx)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Process indicates an expected call of Process.
+func (mr *MockCalculationJobServiceMockRecorder) Process(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Process", reflect.TypeOf((*MockCalculationJobService)(nil).Process), ctx)
+}
+
+// Submit mocks base method.
+func (m *MockCalculationJobService) Submit(ctx context.Context, filename, contentType string, partial bool, configVersion int, file []byte) (CalculationJob, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Submit", ctx, filename, contentType, partial, configVersion, file)
+	ret0, _ := ret[0].(CalculationJob)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Submit indicates an expected call of Submit.
+func (mr *MockCalculationJobServiceMockRecorder) Submit(ctx, filename, contentType, partial, configVersion, file interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Submit", reflect.TypeOf((*MockCalculationJobService)(nil).Submit), ctx, filename, contentType, partial, configVersion, file)
+}
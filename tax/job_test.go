@@ -0,0 +1,265 @@
+package tax
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/chuckboliver/assessment-tax/common"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+const testLeaseOwner = "5d1f6c2a-8b3e-4f7a-9c0d-1e2b3a4c5d6e"
+
+func TestProcessCalculationJob(t *testing.T) {
+	job := CalculationJob{
+		ID:          testJobID,
+		Status:      JobStatusRunning,
+		Filename:    "taxes.csv",
+		ContentType: "text/csv",
+		File:        []byte("totalIncome,wht\n500000,0\n750000,0\n"),
+		LeaseOwner:  testLeaseOwner,
+	}
+
+	testCases := []struct {
+		name string
+		job  CalculationJob
+		mock func(jobRepo *MockCalculationJobRepository, taxCalculator *MockCalculator, calculationHistoryService *MockCalculationHistoryService)
+	}{
+		{
+			name: "Should calculate every row with the latest config version and complete job",
+			job:  job,
+			mock: func(jobRepo *MockCalculationJobRepository, taxCalculator *MockCalculator, calculationHistoryService *MockCalculationHistoryService) {
+				gomock.InOrder(
+					taxCalculator.EXPECT().LatestConfigVersion(gomock.Any()).Return(4, nil),
+					jobRepo.EXPECT().Start(gomock.Any(), job.ID, testLeaseOwner, 2, 4, gomock.Len(0)).Return(nil),
+					taxCalculator.EXPECT().BatchCalculate(gomock.Any(), []calculationRequest{
+						{TotalIncome: common.Baht(500000), Allowances: []Allowance{}, ConfigVersion: 4},
+						{TotalIncome: common.Baht(750000), Allowances: []Allowance{}, ConfigVersion: 4},
+					}).Return(BatchCalculationResult{
+						Taxes: []CalculationResult{{ConfigVersion: 4, Tax: common.Baht(29000)}, {ConfigVersion: 4, Tax: common.Baht(63750)}},
+					}, nil),
+					jobRepo.EXPECT().SaveResults(gomock.Any(), job.ID, testLeaseOwner, []CalculationResult{
+						{Row: 2, ConfigVersion: 4, Tax: common.Baht(29000)},
+						{Row: 3, ConfigVersion: 4, Tax: common.Baht(63750)},
+					}, nil, jobLease).Return(nil),
+					calculationHistoryService.EXPECT().Record(gomock.Any(), CalculationSourceCSV, gomock.Len(2)).Return(nil, nil),
+					jobRepo.EXPECT().Complete(gomock.Any(), job.ID, testLeaseOwner).Return(nil),
+				)
+			},
+		},
+		{
+			name: "Should resume after processed rows with the config version of the job, given resumed job",
+			job: func() CalculationJob {
+				resumed := job
+				resumed.ProcessedRows = 1
+				resumed.ConfigVersion = 3
+				return resumed
+			}(),
+			mock: func(jobRepo *MockCalculationJobRepository, taxCalculator *MockCalculator, calculationHistoryService *MockCalculationHistoryService) {
+				taxCalculator.EXPECT().LatestConfigVersion(gomock.Any()).Times(0)
+				gomock.InOrder(
+					jobRepo.EXPECT().Start(gomock.Any(), job.ID, testLeaseOwner, 2, 3, gomock.Len(0)).Return(nil),
+					taxCalculator.EXPECT().BatchCalculate(gomock.Any(), []calculationRequest{
+						{TotalIncome: common.Baht(750000), Allowances: []Allowance{}, ConfigVersion: 3},
+					}).Return(BatchCalculationResult{
+						Taxes: []CalculationResult{{ConfigVersion: 3, Tax: common.Baht(63750)}},
+					}, nil),
+					jobRepo.EXPECT().SaveResults(gomock.Any(), job.ID, testLeaseOwner, []CalculationResult{{Row: 3, ConfigVersion: 3, Tax: common.Baht(63750)}}, nil, jobLease).Return(nil),
+					calculationHistoryService.EXPECT().Record(gomock.Any(), CalculationSourceCSV, gomock.Len(1)).Return(nil, nil),
+					jobRepo.EXPECT().Complete(gomock.Any(), job.ID, testLeaseOwner).Return(nil),
+				)
+			},
+		},
+		{
+			name: "Should fail job with row errors, given invalid rows and not partial",
+			job: func() CalculationJob {
+				invalid := job
+				invalid.File = []byte("totalIncome,wht\n500000,abc\n750000,0\n")
+				return invalid
+			}(),
+			mock: func(jobRepo *MockCalculationJobRepository, taxCalculator *MockCalculator, calculationHistoryService *MockCalculationHistoryService) {
+				taxCalculator.EXPECT().BatchCalculate(gomock.Any(), gomock.Any()).Times(0)
+				jobRepo.EXPECT().Fail(gomock.Any(), job.ID, testLeaseOwner, "invalid rows in file: 1", []RowError{
+					{Row: 2, Column: "wht", Value: "abc", Error: `failed to parse wht: invalid amount "abc"`},
+				}).Return(nil)
+			},
+		},
+		{
+			name: "Should fail job, given unknown tax year",
+			job:  job,
+			mock: func(jobRepo *MockCalculationJobRepository, taxCalculator *MockCalculator, calculationHistoryService *MockCalculationHistoryService) {
+				taxCalculator.EXPECT().LatestConfigVersion(gomock.Any()).Return(4, nil)
+				jobRepo.EXPECT().Start(gomock.Any(), job.ID, testLeaseOwner, 2, 4, gomock.Len(0)).Return(nil)
+				taxCalculator.EXPECT().BatchCalculate(gomock.Any(), gomock.Any()).Return(BatchCalculationResult{}, ErrUnknownTaxYear)
+				jobRepo.EXPECT().Fail(gomock.Any(), job.ID, testLeaseOwner, "unknown tax year", nil).Return(nil)
+			},
+		},
+		{
+			name: "Should report row and complete job, given unknown tax year and partial",
+			job: func() CalculationJob {
+				partial := job
+				partial.Partial = true
+				partial.File = []byte("totalIncome,taxYear\n500000,2567\n600000,2500\n")
+				return partial
+			}(),
+			mock: func(jobRepo *MockCalculationJobRepository, taxCalculator *MockCalculator, calculationHistoryService *MockCalculationHistoryService) {
+				gomock.InOrder(
+					taxCalculator.EXPECT().LatestConfigVersion(gomock.Any()).Return(4, nil),
+					jobRepo.EXPECT().Start(gomock.Any(), job.ID, testLeaseOwner, 2, 4, gomock.Len(0)).Return(nil),
+					taxCalculator.EXPECT().BatchCalculate(gomock.Any(), gomock.Len(2)).Return(BatchCalculationResult{}, ErrUnknownTaxYear),
+					taxCalculator.EXPECT().BatchCalculate(gomock.Any(), gomock.Len(1)).Return(BatchCalculationResult{
+						Taxes: []CalculationResult{{TaxYear: 2567, ConfigVersion: 4, Tax: common.Baht(29000)}},
+					}, nil),
+					taxCalculator.EXPECT().BatchCalculate(gomock.Any(), gomock.Len(1)).Return(BatchCalculationResult{}, ErrUnknownTaxYear),
+					jobRepo.EXPECT().SaveResults(gomock.Any(), job.ID, testLeaseOwner, []CalculationResult{
+						{Row: 2, TaxYear: 2567, ConfigVersion: 4, Tax: common.Baht(29000)},
+					}, []RowError{{Row: 3, Error: "unknown tax year"}}, jobLease).Return(nil),
+					calculationHistoryService.EXPECT().Record(gomock.Any(), CalculationSourceCSV, gomock.Len(1)).Return(nil, nil),
+					jobRepo.EXPECT().Complete(gomock.Any(), job.ID, testLeaseOwner).Return(nil),
+				)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			jobRepo := NewMockCalculationJobRepository(ctrl)
+			taxCalculator := NewMockCalculator(ctrl)
+			calculationHistoryService := NewMockCalculationHistoryService(ctrl)
+			tc.mock(jobRepo, taxCalculator, calculationHistoryService)
+
+			claimed := tc.job
+			jobRepo.EXPECT().Claim(gomock.Any(), jobLease).Return(&claimed, nil)
+
+			validator := &common.EchoValidator{Validator: common.NewValidator()}
+			service := NewCalculationJobService(jobRepo, taxCalculator, calculationHistoryService, validator, 0, 0)
+
+			processed, err := service.Process(context.Background())
+			require.NoError(t, err)
+			require.True(t, processed)
+		})
+	}
+}
+
+func TestProcessCalculationJobWithConfigUnavailable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	job := CalculationJob{
+		ID:          testJobID,
+		Status:      JobStatusRunning,
+		Filename:    "taxes.csv",
+		ContentType: "text/csv",
+		File:        []byte("totalIncome,wht\n500000,0\n"),
+	}
+
+	jobRepo := NewMockCalculationJobRepository(ctrl)
+	jobRepo.EXPECT().Claim(gomock.Any(), jobLease).Return(&job, nil)
+	jobRepo.EXPECT().Fail(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+	jobRepo.EXPECT().Complete(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+	taxCalculator := NewMockCalculator(ctrl)
+	taxCalculator.EXPECT().LatestConfigVersion(gomock.Any()).Return(0, fmt.Errorf("%w: %w", ErrConfigUnavailable, errors.New("connection refused")))
+
+	validator := &common.EchoValidator{Validator: common.NewValidator()}
+	service := NewCalculationJobService(jobRepo, taxCalculator, NewMockCalculationHistoryService(ctrl), validator, 0, 0)
+
+	processed, err := service.Process(context.Background())
+	require.ErrorIs(t, err, ErrConfigUnavailable)
+	require.True(t, processed)
+}
+
+func TestProcessCalculationJobWithLeaseLost(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	job := CalculationJob{
+		ID:          testJobID,
+		Status:      JobStatusRunning,
+		Filename:    "taxes.csv",
+		ContentType: "text/csv",
+		File:        []byte("totalIncome,wht\n500000,0\n"),
+		LeaseOwner:  testLeaseOwner,
+	}
+
+	jobRepo := NewMockCalculationJobRepository(ctrl)
+	jobRepo.EXPECT().Claim(gomock.Any(), jobLease).Return(&job, nil)
+	jobRepo.EXPECT().Start(gomock.Any(), job.ID, testLeaseOwner, 1, 4, gomock.Len(0)).Return(nil)
+	jobRepo.EXPECT().SaveResults(gomock.Any(), job.ID, testLeaseOwner, gomock.Len(1), nil, jobLease).Return(ErrJobLeaseLost)
+	jobRepo.EXPECT().Fail(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+	jobRepo.EXPECT().Complete(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+	taxCalculator := NewMockCalculator(ctrl)
+	taxCalculator.EXPECT().LatestConfigVersion(gomock.Any()).Return(4, nil)
+	taxCalculator.EXPECT().BatchCalculate(gomock.Any(), gomock.Len(1)).Return(BatchCalculationResult{
+		Taxes: []CalculationResult{{ConfigVersion: 4, Tax: common.Baht(29000)}},
+	}, nil)
+
+	calculationHistoryService := NewMockCalculationHistoryService(ctrl)
+	calculationHistoryService.EXPECT().Record(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+	validator := &common.EchoValidator{Validator: common.NewValidator()}
+	service := NewCalculationJobService(jobRepo, taxCalculator, calculationHistoryService, validator, 0, 0)
+
+	processed, err := service.Process(context.Background())
+	require.ErrorIs(t, err, ErrJobLeaseLost)
+	require.True(t, processed)
+}
+
+func TestProcessCalculationJobWithoutPendingJob(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	jobRepo := NewMockCalculationJobRepository(ctrl)
+	jobRepo.EXPECT().Claim(gomock.Any(), jobLease).Return(nil, ErrNoPendingJob)
+
+	service := NewCalculationJobService(jobRepo, NewMockCalculator(ctrl), NewMockCalculationHistoryService(ctrl), nil, 0, 0)
+
+	processed, err := service.Process(context.Background())
+	require.NoError(t, err)
+	require.False(t, processed)
+}
+
+func TestFindCalculationJobRows(t *testing.T) {
+	job := CalculationJob{
+		ID:          testJobID,
+		Status:      JobStatusCompleted,
+		Filename:    "taxes.csv",
+		ContentType: "text/csv",
+	}
+
+	t.Run("Should read every row of file by line", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		jobRepo := NewMockCalculationJobRepository(ctrl)
+		jobRepo.EXPECT().FindFile(gomock.Any(), job.ID).Return([]byte("totalIncome,wht\n500000,0\n600000,abc\n"), nil)
+
+		service := NewCalculationJobService(jobRepo, NewMockCalculator(ctrl), NewMockCalculationHistoryService(ctrl), nil, 0, 0)
+
+		rows, err := service.FindRows(context.Background(), job)
+		require.NoError(t, err)
+		require.Len(t, rows, 2)
+		require.Equal(t, common.Baht(500000), rows[2].request.TotalIncome)
+		require.Equal(t, "abc", rows[3].cells["wht"])
+	})
+
+	t.Run("Should return ErrJobFileNotKept, given dropped file", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		jobRepo := NewMockCalculationJobRepository(ctrl)
+		jobRepo.EXPECT().FindFile(gomock.Any(), job.ID).Return(nil, nil)
+
+		service := NewCalculationJobService(jobRepo, NewMockCalculator(ctrl), NewMockCalculationHistoryService(ctrl), nil, 0, 0)
+
+		_, err := service.FindRows(context.Background(), job)
+		require.ErrorIs(t, err, ErrJobFileNotKept)
+	})
+}
@@ -0,0 +1,52 @@
+package tax
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// jobPollInterval is how long an idle worker waits before looking for a job again.
+const jobPollInterval = time.Second
+
+// JobWorkerPool runs calculation jobs in the background with a fixed number of
+// workers. Jobs are taken from the database, so that jobs submitted to any
+// server, or left unfinished by a stopped one, are run.
+type JobWorkerPool struct {
+	calculationJobService CalculationJobService
+	workers               int
+}
+
+func NewJobWorkerPool(calculationJobService CalculationJobService, workers int) *JobWorkerPool {
+	return &JobWorkerPool{
+		calculationJobService: calculationJobService,
+		workers:               max(workers, 1),
+	}
+}
+
+// Start starts the workers, which stop when ctx is done.
+func (p *JobWorkerPool) Start(ctx context.Context) {
+	for i := 0; i < p.workers; i++ {
+		go p.work(ctx)
+	}
+}
+
+func (p *JobWorkerPool) work(ctx context.Context) {
+	for {
+		processed, err := p.calculationJobService.Process(ctx)
+		if err != nil {
+			slog.Error("Failed to process calculation job", "err", err)
+		}
+
+		// Look for the next job right away while there are jobs to run.
+		if processed && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(jobPollInterval):
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package tax
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// maxJSONLLineSize is the longest line, in bytes, accepted in a JSON Lines upload.
+const maxJSONLLineSize = 1 << 20
+
+var _ parser = (*jsonlParser)(nil)
+
+// jsonlParser reads a calculation request, in the same JSON as POST
+// /tax/calculations, from every non-blank line.
+type jsonlParser struct{}
+
+func newJSONLParser() parser {
+	return &jsonlParser{}
+}
+
+func (j *jsonlParser) readRows(reader io.Reader, yield func(row parsedRow, rowErrors []RowError) error) error {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxJSONLLineSize)
+
+	for line := 1; scanner.Scan(); line++ {
+		content := bytes.TrimSpace(scanner.Bytes())
+		if len(content) == 0 {
+			continue
+		}
+
+		var err error
+		var request calculationRequest
+		if unmarshalErr := json.Unmarshal(content, &request); unmarshalErr != nil {
+			err = yield(parsedRow{row: line}, []RowError{{
+				Row:   line,
+				Error: fmt.Sprintf("invalid JSON: %s", unmarshalErr.Error()),
+			}})
+		} else {
+			err = yield(parsedRow{row: line, request: request}, nil)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
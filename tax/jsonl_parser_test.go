@@ -0,0 +1,67 @@
+package tax
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/chuckboliver/assessment-tax/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseJSONL(t *testing.T) {
+	jsonl := `{"taxpayerId": "1101700203450", "totalIncome": 500000, "wht": 0, "allowances": [{"allowanceType": "k-receipt", "amount": 1000}]}
+
+{"totalIncome": "abc"}
+{"incomes": [{"incomeType": "salary", "amount": 600000}], "wht": 1000}
+`
+
+	parsedRows, rowErrors, err := parseCalculationRequest(newJSONLParser(), strings.NewReader(jsonl))
+	require.NoError(t, err)
+
+	require.Len(t, parsedRows, 2)
+	require.Equal(t, 1, parsedRows[0].row)
+	require.Equal(t, calculationRequest{
+		TaxpayerID:  "1101700203450",
+		TotalIncome: common.Baht(500000),
+		Allowances:  []Allowance{{AllowanceType: AllowanceKReceipt, Amount: common.Baht(1000)}},
+	}, parsedRows[0].request)
+	require.Equal(t, 4, parsedRows[1].row)
+	require.Equal(t, calculationRequest{
+		Incomes: []Income{{IncomeType: IncomeSalary, Amount: common.Baht(600000)}},
+		Wht:     common.Baht(1000),
+	}, parsedRows[1].request)
+
+	require.Len(t, rowErrors, 1)
+	require.Equal(t, 3, rowErrors[0].Row)
+	require.Contains(t, rowErrors[0].Error, "invalid JSON")
+
+	require.Equal(t, []RowError{
+		{Row: 4, Column: "wht", Error: "wht failed on ltefield=incomes"},
+	}, parsedRows[1].rowErrors([]common.Violation{{Field: "wht", Rule: "ltefield", Param: "incomes"}}))
+}
+
+func TestParserFor(t *testing.T) {
+	testCases := []struct {
+		filename    string
+		contentType string
+		expected    parser
+	}{
+		{filename: "taxes.csv", contentType: "application/octet-stream", expected: &csvParser{}},
+		{filename: "TAXES.XLSX", contentType: "", expected: &xlsxParser{}},
+		{filename: "taxes.jsonl", contentType: "", expected: &jsonlParser{}},
+		{filename: "taxes.ndjson", contentType: "", expected: &jsonlParser{}},
+		{filename: "taxes", contentType: "text/csv; charset=utf-8", expected: &csvParser{}},
+		{filename: "taxes", contentType: "application/x-ndjson", expected: &jsonlParser{}},
+		{filename: "taxes", contentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", expected: &xlsxParser{}},
+		{filename: "taxes.pdf", contentType: "application/pdf", expected: nil},
+		{filename: "taxes", contentType: "", expected: nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.filename+" "+tc.contentType, func(t *testing.T) {
+			got, ok := parserFor(tc.filename, tc.contentType)
+			require.Equal(t, tc.expected != nil, ok)
+			require.Equal(t, tc.expected, got)
+		})
+	}
+}
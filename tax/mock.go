@@ -34,19 +34,34 @@ func (m *MockTaxConfigRepository) EXPECT() *MockTaxConfigRepositoryMockRecorder
 	return m.recorder
 }
 
-// FindByName mocks base method.
-func (m *MockTaxConfigRepository) FindByName(ctx context.Context, name string) (*Config, error) {
+// FindSnapshot mocks base method.
+func (m *MockTaxConfigRepository) FindSnapshot(ctx context.Context, taxYear, version int) (*ConfigSnapshot, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindByName", ctx, name)
-	ret0, _ := ret[0].(*Config)
+	ret := m.ctrl.Call(m, "FindSnapshot", ctx, taxYear, version)
+	ret0, _ := ret[0].(*ConfigSnapshot)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// FindByName indicates an expected call of FindByName.
-func (mr *MockTaxConfigRepositoryMockRecorder) FindByName(ctx, name interface{}) *gomock.Call {
+// FindSnapshot indicates an expected call of FindSnapshot.
+func (mr *MockTaxConfigRepositoryMockRecorder) FindSnapshot(ctx, taxYear, version interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByName", reflect.TypeOf((*MockTaxConfigRepository)(nil).FindByName), ctx, name)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindSnapshot", reflect.TypeOf((*MockTaxConfigRepository)(nil).FindSnapshot), ctx, taxYear, version)
+}
+
+// LatestVersion mocks base method.
+func (m *MockTaxConfigRepository) LatestVersion(ctx context.Context) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LatestVersion", ctx)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LatestVersion indicates an expected call of LatestVersion.
+func (mr *MockTaxConfigRepositoryMockRecorder) LatestVersion(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LatestVersion", reflect.TypeOf((*MockTaxConfigRepository)(nil).LatestVersion), ctx)
 }
 
 // MockCalculator is a mock of Calculator interface.
@@ -73,11 +88,12 @@ func (m *MockCalculator) EXPECT() *MockCalculatorMockRecorder {
 }
 
 // BatchCalculate mocks base method.
-func (m *MockCalculator) BatchCalculate(ctx context.Context, params []calculationRequest) BatchCalculationResult {
+func (m *MockCalculator) BatchCalculate(ctx context.Context, params []calculationRequest) (BatchCalculationResult, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "BatchCalculate", ctx, params)
 	ret0, _ := ret[0].(BatchCalculationResult)
-	return ret0
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // BatchCalculate indicates an expected call of BatchCalculate.
@@ -87,11 +103,12 @@ func (mr *MockCalculatorMockRecorder) BatchCalculate(ctx, params interface{}) *g
 }
 
 // Calculate mocks base method.
-func (m *MockCalculator) Calculate(ctx context.Context, param calculationRequest) CalculationResultWithTaxLevel {
+func (m *MockCalculator) Calculate(ctx context.Context, param calculationRequest) (CalculationResultWithTaxLevel, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Calculate", ctx, param)
 	ret0, _ := ret[0].(CalculationResultWithTaxLevel)
-	return ret0
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // Calculate indicates an expected call of Calculate.
@@ -99,3 +116,32 @@ func (mr *MockCalculatorMockRecorder) Calculate(ctx, param interface{}) *gomock.
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Calculate", reflect.TypeOf((*MockCalculator)(nil).Calculate), ctx, param)
 }
+
+// CheckRules mocks base method.
+func (m *MockCalculator) CheckRules(ctx context.Context, taxYear, configVersion int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckRules", ctx, taxYear, configVersion)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CheckRules indicates an expected call of CheckRules.
+func (mr *MockCalculatorMockRecorder) CheckRules(ctx, taxYear, configVersion interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckRules", reflect.TypeOf((*MockCalculator)(nil).CheckRules), ctx, taxYear, configVersion)
+}
+
+// LatestConfigVersion mocks base method.
+func (m *MockCalculator) LatestConfigVersion(ctx context.Context) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LatestConfigVersion", ctx)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LatestConfigVersion indicates an expected call of LatestConfigVersion.
+func (mr *MockCalculatorMockRecorder) LatestConfigVersion(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LatestConfigVersion", reflect.TypeOf((*MockCalculator)(nil).LatestConfigVersion), ctx)
+}
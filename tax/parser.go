@@ -5,11 +5,180 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"mime"
+	"path/filepath"
+	"regexp"
 	"strconv"
+	"strings"
+
+	"github.com/chuckboliver/assessment-tax/common"
 )
 
+// RowError is a failure of a row of an uploaded file. Row is the line number in
+// the file, counting the header row as line 1. Column and Value are empty when the
+// failure is not about a single cell.
+type RowError struct {
+	Row    int    `json:"row"`
+	Column string `json:"column,omitempty"`
+	Value  string `json:"value,omitempty"`
+	Error  string `json:"error"`
+}
+
+// parsedRow is a calculation request read from a row of an uploaded file.
+type parsedRow struct {
+	row     int
+	request calculationRequest
+	// columns and cells are the header and the raw value of every column, for the
+	// formats with columns.
+	columns []string
+	cells   map[string]string
+	// allowanceColumns is the column each allowance of request was read from.
+	allowanceColumns []string
+}
+
+var allowanceFieldPattern = regexp.MustCompile(`^allowances\[(\d+)\]`)
+
+// column returns the column that a validated field of the request was read from,
+// or an empty string when the field does not come from a single column.
+func (r parsedRow) column(field string) string {
+	if match := allowanceFieldPattern.FindStringSubmatch(field); match != nil {
+		i, _ := strconv.Atoi(match[1])
+		if i < len(r.allowanceColumns) {
+			return r.allowanceColumns[i]
+		}
+		return ""
+	}
+
+	// Fields of a JSON Lines row are named as in the request.
+	if r.cells == nil {
+		return field
+	}
+
+	if _, ok := r.cells[field]; ok {
+		return field
+	}
+
+	return ""
+}
+
+// rowErrors converts the violations of a failed validation of the row.
+func (r parsedRow) rowErrors(violations []common.Violation) []RowError {
+	rowErrors := make([]RowError, 0, len(violations))
+	for _, v := range violations {
+		column := r.column(v.Field)
+
+		message := fmt.Sprintf("%s failed on %s", v.Field, v.Rule)
+		if v.Param != "" {
+			message = fmt.Sprintf("%s failed on %s=%s", v.Field, v.Rule, v.Param)
+		}
+
+		rowErrors = append(rowErrors, RowError{
+			Row:    r.row,
+			Column: column,
+			Value:  r.cells[column],
+			Error:  message,
+		})
+	}
+
+	return rowErrors
+}
+
 type parser interface {
-	parseCalculationRequest(reader io.Reader) ([]calculationRequest, error)
+	// readRows reads the rows of a file in order, calling yield with every row, or
+	// with the errors of a row that cannot be read. It stops at the first error
+	// returned by yield and returns it. Any other error means that the file, from
+	// the row reached on, cannot be read.
+	readRows(reader io.Reader, yield func(row parsedRow, rowErrors []RowError) error) error
+}
+
+// parseCalculationRequest reads every row of a file into memory.
+func parseCalculationRequest(p parser, reader io.Reader) ([]parsedRow, []RowError, error) {
+	parsedRows := make([]parsedRow, 0)
+	rowErrors := make([]RowError, 0)
+	err := p.readRows(reader, func(row parsedRow, errs []RowError) error {
+		if len(errs) > 0 {
+			rowErrors = append(rowErrors, errs...)
+			return nil
+		}
+
+		parsedRows = append(parsedRows, row)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return parsedRows, rowErrors, nil
+}
+
+// ErrTooManyRows is returned by a parser limited by limitRows once a file has
+// more rows than the limit.
+var ErrTooManyRows = errors.New("too many rows")
+
+var _ parser = (*rowLimitParser)(nil)
+
+type rowLimitParser struct {
+	parser
+	maxRows int
+}
+
+// limitRows returns a parser that reads at most maxRows rows, not counting the
+// header and blank rows, with p. Zero puts no limit.
+func limitRows(p parser, maxRows int) parser {
+	if maxRows <= 0 {
+		return p
+	}
+
+	return &rowLimitParser{parser: p, maxRows: maxRows}
+}
+
+func (p *rowLimitParser) readRows(reader io.Reader, yield func(row parsedRow, rowErrors []RowError) error) error {
+	rows := 0
+	return p.parser.readRows(reader, func(row parsedRow, rowErrors []RowError) error {
+		rows++
+		if rows > p.maxRows {
+			return fmt.Errorf("%w: a file may have at most %d rows", ErrTooManyRows, p.maxRows)
+		}
+
+		return yield(row, rowErrors)
+	})
+}
+
+// parserByExtension and parserByContentType select the parser of an uploaded
+// file, by its extension first since browsers often send a generic content type.
+var parserByExtension = map[string]func() parser{
+	".csv":    newCSVParser,
+	".xlsx":   newXLSXParser,
+	".jsonl":  newJSONLParser,
+	".ndjson": newJSONLParser,
+}
+
+var parserByContentType = map[string]func() parser{
+	"text/csv": newCSVParser,
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet": newXLSXParser,
+	"application/jsonl":       newJSONLParser,
+	"application/x-jsonlines": newJSONLParser,
+	"application/x-ndjson":    newJSONLParser,
+	"application/ndjson":      newJSONLParser,
+}
+
+// parserFor returns the parser of a file with the given name and content type,
+// and false when the format is not supported.
+func parserFor(filename string, contentType string) (parser, bool) {
+	if newParser, ok := parserByExtension[strings.ToLower(filepath.Ext(filename))]; ok {
+		return newParser(), true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+
+	if newParser, ok := parserByContentType[mediaType]; ok {
+		return newParser(), true
+	}
+
+	return nil, false
 }
 
 var _ parser = (*csvParser)(nil)
@@ -20,79 +189,220 @@ func newCSVParser() parser {
 	return &csvParser{}
 }
 
-func (c *csvParser) parseCalculationRequest(reader io.Reader) ([]calculationRequest, error) {
+func (c *csvParser) readRows(reader io.Reader, yield func(row parsedRow, rowErrors []RowError) error) error {
 	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+
+	return parseTable(func() ([]string, int, error) {
+		record, err := csvReader.Read()
+		if err != nil {
+			return nil, 0, err
+		}
+
+		line, _ := csvReader.FieldPos(0)
+		return record, line, nil
+	}, false, yield)
+}
 
-	records, err := csvReader.ReadAll()
+// parseTable reads calculation requests from a table whose first row is the
+// header. next returns the cells of the next row and its line in the file, or
+// io.EOF after the last row. Rows with fewer cells than the header are rejected
+// unless padShortRows is set, for formats that drop trailing empty cells.
+func parseTable(next func() ([]string, int, error), padShortRows bool, yield func(row parsedRow, rowErrors []RowError) error) error {
+	headerRow, _, err := next()
+	if errors.Is(err, io.EOF) {
+		return errors.New("empty file")
+	}
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	if (len(records) == 0) || (len(records[0]) == 0) {
-		return nil, errors.New("empty csv file")
+	columns, err := parseHeaderRow(headerRow)
+	if err != nil {
+		return err
 	}
 
-	parsedResult := make([]calculationRequest, 0)
+	for {
+		record, line, err := next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
 
-	headerRow := records[0]
-	if err := validateHeaderRow(headerRow); err != nil {
-		return nil, err
-	}
+		if padShortRows && len(record) < len(columns) {
+			record = append(record, make([]string, len(columns)-len(record))...)
+		}
 
-	for i := 1; i < len(records); i++ {
+		if len(record) != len(columns) {
+			err = yield(parsedRow{row: line, columns: columns}, []RowError{{
+				Row:   line,
+				Error: fmt.Sprintf("expected %d cells, got %d", len(columns), len(record)),
+			}})
+		} else {
+			err = yield(parseRow(line, columns, record))
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
 
-		calculationRequest := calculationRequest{
+// parseRow reads a calculation request from the cells of a row, reporting every
+// cell that cannot be read. An empty cell takes the default of its column: no
+// taxpayerId, the default tax year, zero wht and no allowance.
+func parseRow(line int, columns []string, record []string) (parsedRow, []RowError) {
+	row := parsedRow{
+		row: line,
+		request: calculationRequest{
 			Allowances: make([]Allowance, 0),
+		},
+		columns: columns,
+		cells:   make(map[string]string, len(record)),
+	}
+
+	cellErrors := make([]RowError, 0)
+	cellError := func(column string, value string, err error) {
+		cellErrors = append(cellErrors, RowError{
+			Row:    line,
+			Column: column,
+			Value:  value,
+			Error:  err.Error(),
+		})
+	}
+
+	for j, col := range record {
+		columnName := columns[j]
+		col = strings.TrimSpace(col)
+		row.cells[columnName] = col
+
+		if col == "" && columnName != columnTotalIncome {
+			continue
 		}
 
-		for j, col := range records[i] {
-			columnName := headerRow[j]
-			switch columnName {
-			case "totalIncome":
-				value, err := strconv.ParseFloat(col, 64)
-				if err != nil {
-					return nil, fmt.Errorf("failed to parse totalIncome: %w", err)
-				}
-
-				calculationRequest.TotalIncome = value
-			case "wht":
-				value, err := strconv.ParseFloat(col, 64)
-				if err != nil {
-					return nil, fmt.Errorf("failed to parse wht: %w", err)
-				}
-
-				calculationRequest.Wht = value
-			case "donation":
-				value, err := strconv.ParseFloat(col, 64)
-				if err != nil {
-					return nil, fmt.Errorf("failed to parse donation: %w", err)
-				}
-
-				calculationRequest.Allowances = append(calculationRequest.Allowances, Allowance{
-					AllowanceType: AllowanceDonation,
-					Amount:        value,
-				})
+		switch columnName {
+		case columnTaxpayerID:
+			if !common.IsThaiNationalID(col) {
+				cellError(columnName, col, errors.New("must be a 13-digit Thai national ID with a valid check digit"))
+				continue
+			}
+
+			row.request.TaxpayerID = col
+		case columnTaxYear:
+			value, err := strconv.Atoi(col)
+			if err != nil {
+				cellError(columnName, col, fmt.Errorf("failed to parse taxYear: %w", err))
+				continue
 			}
-		}
 
-		parsedResult = append(parsedResult, calculationRequest)
+			row.request.TaxYear = value
+		case columnTotalIncome:
+			if col == "" {
+				cellError(columnName, col, errors.New("totalIncome is required"))
+				continue
+			}
+
+			value, err := common.ParseMoney(col)
+			if err != nil {
+				cellError(columnName, col, fmt.Errorf("failed to parse totalIncome: %w", err))
+				continue
+			}
+
+			row.request.TotalIncome = value
+		case columnWht:
+			value, err := common.ParseMoney(col)
+			if err != nil {
+				cellError(columnName, col, fmt.Errorf("failed to parse wht: %w", err))
+				continue
+			}
+
+			row.request.Wht = value
+		default:
+			value, err := common.ParseMoney(col)
+			if err != nil {
+				cellError(columnName, col, fmt.Errorf("failed to parse %s: %w", columnName, err))
+				continue
+			}
+
+			row.request.Allowances = append(row.request.Allowances, Allowance{
+				AllowanceType: AllowanceType(columnName),
+				Amount:        value,
+			})
+			row.allowanceColumns = append(row.allowanceColumns, columnName)
+		}
 	}
 
-	return parsedResult, nil
+	return row, cellErrors
+}
+
+const (
+	columnTaxpayerID  = "taxpayerId"
+	columnTaxYear     = "taxYear"
+	columnTotalIncome = "totalIncome"
+	columnWht         = "wht"
+)
+
+// csvColumnAliases are accepted header names, once normalized, of columns whose
+// name differs.
+var csvColumnAliases = map[string]string{
+	"income":         columnTotalIncome,
+	"withholdingtax": columnWht,
+	"nationalid":     columnTaxpayerID,
+	"citizenid":      columnTaxpayerID,
 }
 
-func validateHeaderRow(headers []string) error {
-	mustHaveColumns := map[string]struct{}{
-		"totalIncome": {},
-		"wht":         {},
-		"donation":    {},
+// normalizeHeader makes header names comparable regardless of case and of the
+// separators between words, so that "Total Income", "total_income" and
+// "totalIncome" are the same column.
+func normalizeHeader(header string) string {
+	header = strings.ToLower(strings.TrimSpace(header))
+	return strings.NewReplacer(" ", "", "-", "", "_", "").Replace(header)
+}
+
+// parseHeaderRow returns the canonical column name of every header. Besides the
+// request fields, every supported allowance type is a column. Only totalIncome is
+// required.
+func parseHeaderRow(headers []string) ([]string, error) {
+	if len(headers) == 0 {
+		return nil, errors.New("empty file")
+	}
+
+	knownColumns := map[string]string{
+		normalizeHeader(columnTaxpayerID):  columnTaxpayerID,
+		normalizeHeader(columnTaxYear):     columnTaxYear,
+		normalizeHeader(columnTotalIncome): columnTotalIncome,
+		normalizeHeader(columnWht):         columnWht,
+	}
+	for _, v := range defaultAllowanceRegistry().supportedTypes() {
+		knownColumns[normalizeHeader(string(v))] = string(v)
+	}
+	for alias, column := range csvColumnAliases {
+		knownColumns[alias] = column
 	}
 
+	// Excel prepends a byte order mark when saving as UTF-8 CSV.
+	headers[0] = strings.TrimPrefix(headers[0], "\ufeff")
+
+	columns := make([]string, 0, len(headers))
+	seen := make(map[string]struct{}, len(headers))
 	for _, header := range headers {
-		if _, ok := mustHaveColumns[header]; !ok {
-			return fmt.Errorf("unknown column: %s", header)
+		column, ok := knownColumns[normalizeHeader(header)]
+		if !ok {
+			return nil, fmt.Errorf("unknown column: %s", strings.TrimSpace(header))
+		}
+
+		if _, ok := seen[column]; ok {
+			return nil, fmt.Errorf("duplicate column: %s", column)
 		}
+		seen[column] = struct{}{}
+
+		columns = append(columns, column)
+	}
+
+	if _, ok := seen[columnTotalIncome]; !ok {
+		return nil, fmt.Errorf("missing column: %s", columnTotalIncome)
 	}
 
-	return nil
+	return columns, nil
 }
@@ -0,0 +1,188 @@
+package tax
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/chuckboliver/assessment-tax/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCSV(t *testing.T) {
+	testCases := []struct {
+		name              string
+		csv               string
+		expectedRequests  []calculationRequest
+		expectedRows      []int
+		expectedRowErrors []RowError
+	}{
+		{
+			name: "Should parse taxpayerId, given valid national IDs",
+			csv:  "taxpayerId,totalIncome,wht,donation\n1101700203450,500000,0,0\n,600000,40000,20000\n",
+			expectedRequests: []calculationRequest{
+				{
+					TaxpayerID:  "1101700203450",
+					TotalIncome: common.Baht(500000),
+					Allowances:  []Allowance{{AllowanceType: AllowanceDonation, Amount: 0}},
+				},
+				{
+					TotalIncome: common.Baht(600000),
+					Wht:         common.Baht(40000),
+					Allowances:  []Allowance{{AllowanceType: AllowanceDonation, Amount: common.Baht(20000)}},
+				},
+			},
+			expectedRows:      []int{2, 3},
+			expectedRowErrors: []RowError{},
+		},
+		{
+			name:             "Should report every bad cell with its row, given rows that cannot be read",
+			csv:              "taxpayerId,totalIncome,wht,donation\n1101700203451,500000,0,0\n,abc,0,1.234\n,500000,0,0\n,500000,0\n",
+			expectedRequests: []calculationRequest{{TotalIncome: common.Baht(500000), Allowances: []Allowance{{AllowanceType: AllowanceDonation, Amount: 0}}}},
+			expectedRows:     []int{4},
+			expectedRowErrors: []RowError{
+				{Row: 2, Column: "taxpayerId", Value: "1101700203451", Error: "must be a 13-digit Thai national ID with a valid check digit"},
+				{Row: 3, Column: "totalIncome", Value: "abc", Error: "failed to parse totalIncome: invalid amount \"abc\""},
+				{Row: 3, Column: "donation", Value: "1.234", Error: "failed to parse donation: amount 1.234 has more than 2 decimal places"},
+				{Row: 5, Error: "expected 4 cells, got 3"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			parsedRows, rowErrors, err := parseCalculationRequest(newCSVParser(), strings.NewReader(tc.csv))
+			require.NoError(t, err)
+
+			requests := make([]calculationRequest, 0, len(parsedRows))
+			rows := make([]int, 0, len(parsedRows))
+			for _, v := range parsedRows {
+				requests = append(requests, v.request)
+				rows = append(rows, v.row)
+			}
+
+			require.Equal(t, tc.expectedRequests, requests)
+			require.Equal(t, tc.expectedRows, rows)
+			require.Equal(t, tc.expectedRowErrors, rowErrors)
+		})
+	}
+}
+
+func TestParseCSVHeaders(t *testing.T) {
+	testCases := []struct {
+		name             string
+		csv              string
+		expectedRequests []calculationRequest
+	}{
+		{
+			name: "Should accept BOM, whitespace and case differences in headers",
+			csv:  "\ufeff Total Income ,WHT, Donation\n500000,1000,200\n",
+			expectedRequests: []calculationRequest{
+				{
+					TotalIncome: common.Baht(500000),
+					Wht:         common.Baht(1000),
+					Allowances:  []Allowance{{AllowanceType: AllowanceDonation, Amount: common.Baht(200)}},
+				},
+			},
+		},
+		{
+			name: "Should read k-receipt and other allowances, given columns in any order",
+			csv:  "k_receipt,total_income,life-insurance,HomeLoanInterest\n50000,500000,20000,100000\n",
+			expectedRequests: []calculationRequest{
+				{
+					TotalIncome: common.Baht(500000),
+					Allowances: []Allowance{
+						{AllowanceType: AllowanceKReceipt, Amount: common.Baht(50000)},
+						{AllowanceType: AllowanceLifeInsurance, Amount: common.Baht(20000)},
+						{AllowanceType: AllowanceHomeLoanInterest, Amount: common.Baht(100000)},
+					},
+				},
+			},
+		},
+		{
+			name: "Should accept aliases",
+			csv:  "citizen id,income,withholding tax\n1101700203450,500000,1000\n",
+			expectedRequests: []calculationRequest{
+				{
+					TaxpayerID:  "1101700203450",
+					TotalIncome: common.Baht(500000),
+					Wht:         common.Baht(1000),
+					Allowances:  []Allowance{},
+				},
+			},
+		},
+		{
+			name: "Should use defaults, given only totalIncome or empty optional cells",
+			csv:  "totalIncome,wht,taxYear,donation\n500000,,,\n600000, 40000 ,2566, 20000\n",
+			expectedRequests: []calculationRequest{
+				{
+					TotalIncome: common.Baht(500000),
+					Allowances:  []Allowance{},
+				},
+				{
+					TaxYear:     2566,
+					TotalIncome: common.Baht(600000),
+					Wht:         common.Baht(40000),
+					Allowances:  []Allowance{{AllowanceType: AllowanceDonation, Amount: common.Baht(20000)}},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			parsedRows, rowErrors, err := parseCalculationRequest(newCSVParser(), strings.NewReader(tc.csv))
+			require.NoError(t, err)
+			require.Empty(t, rowErrors)
+
+			requests := make([]calculationRequest, 0, len(parsedRows))
+			for _, v := range parsedRows {
+				requests = append(requests, v.request)
+			}
+
+			require.Equal(t, tc.expectedRequests, requests)
+		})
+	}
+}
+
+func TestParseCSVWithEmptyTotalIncome(t *testing.T) {
+	_, rowErrors, err := parseCalculationRequest(newCSVParser(), strings.NewReader("totalIncome,wht\n,0\n"))
+	require.NoError(t, err)
+	require.Equal(t, []RowError{{Row: 2, Column: "totalIncome", Error: "totalIncome is required"}}, rowErrors)
+}
+
+func TestParseCSVWithInvalidFile(t *testing.T) {
+	testCases := []struct {
+		name string
+		csv  string
+	}{
+		{name: "Should reject, given empty file", csv: ""},
+		{name: "Should reject, given unknown column", csv: "totalIncome,wht,lottery\n500000,0,0\n"},
+		{name: "Should reject, given duplicate column", csv: "totalIncome,wht,WHT\n500000,0,0\n"},
+		{name: "Should reject, given no totalIncome column", csv: "wht,donation\n0,0\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := parseCalculationRequest(newCSVParser(), strings.NewReader(tc.csv))
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestParsedRowErrors(t *testing.T) {
+	row := parsedRow{
+		row:              7,
+		cells:            map[string]string{"totalIncome": "500000", "wht": "600000", "donation": "-1"},
+		allowanceColumns: []string{"donation"},
+	}
+
+	rowErrors := row.rowErrors([]common.Violation{
+		{Field: "wht", Rule: "ltefield", Param: "totalIncome"},
+		{Field: "allowances[0].amount", Rule: "gte", Param: "0"},
+	})
+
+	require.Equal(t, []RowError{
+		{Row: 7, Column: "wht", Value: "600000", Error: "wht failed on ltefield=totalIncome"},
+		{Row: 7, Column: "donation", Value: "-1", Error: "allowances[0].amount failed on gte=0"},
+	}, rowErrors)
+}
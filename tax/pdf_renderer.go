@@ -0,0 +1,153 @@
+package tax
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/chuckboliver/assessment-tax/common"
+	"github.com/go-pdf/fpdf"
+)
+
+// batchRenderer renders the whole result of an upload in a format other than
+// JSON. rows are the calculated rows, in the order of result.Taxes.
+type batchRenderer struct {
+	filename string
+	render   func(w io.Writer, rows []parsedRow, result BatchCalculationResult) error
+}
+
+// batchRendererByMediaType are the formats the result of an upload can be
+// downloaded in, chosen by the Accept header of the request with resultFormatFor.
+// Formats that can be written row by row are streamed instead, see
+// resultWriterByMediaType.
+var batchRendererByMediaType = map[string]batchRenderer{
+	"application/pdf": {filename: "tax-calculations.pdf", render: renderPDFSummary},
+}
+
+// taxpayerSummary is the calculated rows of a taxpayer, or of a single row
+// without taxpayerId.
+type taxpayerSummary struct {
+	title   string
+	results []CalculationResult
+}
+
+// summarizeByTaxpayer groups results by the taxpayerId of their row, in the order
+// of the first row of every taxpayer.
+func summarizeByTaxpayer(rows []parsedRow, results []CalculationResult) []taxpayerSummary {
+	summaries := make([]taxpayerSummary, 0)
+	indexByTaxpayerID := make(map[string]int)
+	for i, v := range rows {
+		taxpayerID := v.request.TaxpayerID
+		if taxpayerID == "" {
+			summaries = append(summaries, taxpayerSummary{
+				title:   fmt.Sprintf("Row %d (no taxpayerId)", v.row),
+				results: []CalculationResult{results[i]},
+			})
+			continue
+		}
+
+		index, ok := indexByTaxpayerID[taxpayerID]
+		if !ok {
+			index = len(summaries)
+			indexByTaxpayerID[taxpayerID] = index
+			summaries = append(summaries, taxpayerSummary{title: "Taxpayer " + taxpayerID})
+		}
+
+		summaries[index].results = append(summaries[index].results, results[i])
+	}
+
+	return summaries
+}
+
+const (
+	pdfRowHeight   = 6.0
+	pdfColumnWidth = 36.0
+)
+
+// renderPDFSummary writes a section per taxpayer with the tax of every row, the
+// tax per bracket and the totals, followed by the skipped rows of a partial upload.
+func renderPDFSummary(w io.Writer, rows []parsedRow, result BatchCalculationResult) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle("Tax calculation summary", false)
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(0, 10, "Tax calculation summary", "", 1, "L", false, 0, "")
+	pdf.Ln(2)
+
+	for _, summary := range summarizeByTaxpayer(rows, result.Taxes) {
+		pdf.SetFont("Helvetica", "B", 12)
+		pdf.CellFormat(0, 8, summary.title, "", 1, "L", false, 0, "")
+
+		pdfTableRow(pdf, true, "Row", "Tax year", "Total income", "Tax", "Tax refund")
+
+		var totalIncome, tax, taxRefund common.Money
+		levels := make([]string, 0)
+		taxByLevel := make(map[string]common.Money)
+		for _, v := range summary.results {
+			pdfTableRow(pdf, false, strconv.Itoa(v.Row), strconv.Itoa(v.TaxYear), formatAmount(v.TotalIncome), formatAmount(v.Tax), formatAmount(v.TaxRefund))
+
+			totalIncome += v.TotalIncome
+			tax += v.Tax
+			taxRefund += v.TaxRefund
+			for _, level := range v.TaxLevels {
+				if _, ok := taxByLevel[level.Level]; !ok {
+					levels = append(levels, level.Level)
+				}
+				taxByLevel[level.Level] += level.Tax
+			}
+		}
+		pdfTableRow(pdf, true, "Total", "", formatAmount(totalIncome), formatAmount(tax), formatAmount(taxRefund))
+		pdf.Ln(2)
+
+		if len(levels) > 0 {
+			pdfTableRow(pdf, true, "Bracket", "Tax")
+			for _, level := range levels {
+				pdfTableRow(pdf, false, pdfText(level), formatAmount(taxByLevel[level]))
+			}
+		}
+		pdf.Ln(6)
+	}
+
+	if len(result.Errors) > 0 {
+		pdf.SetFont("Helvetica", "B", 12)
+		pdf.CellFormat(0, 8, "Skipped rows", "", 1, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 10)
+		for _, v := range result.Errors {
+			pdf.CellFormat(pdfColumnWidth/2, pdfRowHeight, strconv.Itoa(v.Row), "1", 0, "L", false, 0, "")
+			pdf.CellFormat(0, pdfRowHeight, v.Error, "1", 1, "L", false, 0, "")
+		}
+	}
+
+	return pdf.Output(w)
+}
+
+func pdfTableRow(pdf *fpdf.Fpdf, header bool, cells ...string) {
+	style := ""
+	if header {
+		style = "B"
+	}
+	pdf.SetFont("Helvetica", style, 10)
+
+	for i, v := range cells {
+		align := "R"
+		if i == 0 {
+			align = "L"
+		}
+		pdf.CellFormat(pdfColumnWidth, pdfRowHeight, v, "1", 0, align, false, 0, "")
+	}
+	pdf.Ln(-1)
+}
+
+// pdfText replaces the Thai of bracket levels, which the standard PDF fonts
+// cannot show.
+func pdfText(s string) string {
+	return strings.ReplaceAll(s, " ขึ้นไป", " and above")
+}
+
+// formatAmount formats money in baht with thousands separators and satang, e.g.
+// "1,234,567.50".
+func formatAmount(m common.Money) string {
+	return fmt.Sprintf("%s.%02d", formatThousands(m-m%common.Baht(1)), int64(m%common.Baht(1)))
+}
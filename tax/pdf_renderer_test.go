@@ -0,0 +1,56 @@
+package tax
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/chuckboliver/assessment-tax/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarizeByTaxpayer(t *testing.T) {
+	rows := []parsedRow{
+		{row: 2, request: calculationRequest{TaxpayerID: "1101700203450"}},
+		{row: 3},
+		{row: 4, request: calculationRequest{TaxpayerID: "3100600123457"}},
+		{row: 5, request: calculationRequest{TaxpayerID: "1101700203450"}},
+	}
+	results := []CalculationResult{
+		{Row: 2, Tax: common.Baht(29000)},
+		{Row: 3, Tax: common.Baht(1000)},
+		{Row: 4, Tax: common.Baht(2000)},
+		{Row: 5, Tax: common.Baht(3000)},
+	}
+
+	require.Equal(t, []taxpayerSummary{
+		{title: "Taxpayer 1101700203450", results: []CalculationResult{results[0], results[3]}},
+		{title: "Row 3 (no taxpayerId)", results: []CalculationResult{results[1]}},
+		{title: "Taxpayer 3100600123457", results: []CalculationResult{results[2]}},
+	}, summarizeByTaxpayer(rows, results))
+}
+
+func TestRenderPDFSummary(t *testing.T) {
+	rows := []parsedRow{
+		{row: 2, request: calculationRequest{TaxpayerID: "1101700203450"}},
+	}
+	result := BatchCalculationResult{
+		Taxes: []CalculationResult{
+			{Row: 2, TaxYear: 2567, TotalIncome: common.Baht(3000000), Tax: common.Baht(639000), TaxLevels: []TaxLevel{
+				{Level: "0-150,000", Tax: 0},
+				{Level: "2,000,001 ขึ้นไป", Tax: common.Baht(350000)},
+			}},
+		},
+		Errors: []RowError{{Row: 3, Error: "totalIncome is required"}},
+	}
+
+	var body bytes.Buffer
+	err := renderPDFSummary(&body, rows, result)
+	require.NoError(t, err)
+	require.True(t, bytes.HasPrefix(body.Bytes(), []byte("%PDF-")))
+}
+
+func TestFormatAmount(t *testing.T) {
+	require.Equal(t, "0.00", formatAmount(0))
+	require.Equal(t, "29,000.00", formatAmount(common.Baht(29000)))
+	require.Equal(t, "1,234,567.05", formatAmount(common.Money(123456705)))
+}
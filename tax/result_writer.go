@@ -0,0 +1,298 @@
+package tax
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// resultWriter writes the outcome of every row of an upload as it is calculated.
+// Writes are buffered until flush.
+type resultWriter interface {
+	writeResult(row parsedRow, result CalculationResult) error
+	writeRowErrors(row parsedRow, rowErrors []RowError) error
+	flush() error
+}
+
+// resultOptions are the options of an upload that change its streamed results.
+type resultOptions struct {
+	includeTaxLevel bool
+}
+
+// resultWriterByMediaType are the formats an upload can be streamed back in,
+// chosen by the Accept header of the request with resultFormatFor.
+var resultWriterByMediaType = map[string]func(w io.Writer, options resultOptions) resultWriter{
+	"application/x-ndjson": newNDJSONResultWriter,
+	"application/jsonl":    newNDJSONResultWriter,
+	"text/csv":             newCSVResultWriter,
+}
+
+// resultFormat is a format the result of an upload can be returned in: streamed
+// row by row with newResultWriter, rendered at once with renderer, or, with
+// neither, as JSON.
+type resultFormat struct {
+	mediaType       string
+	newResultWriter func(w io.Writer, options resultOptions) resultWriter
+	renderer        *batchRenderer
+}
+
+// resultFormatByMediaType are JSON and the formats of resultWriterByMediaType and
+// batchRendererByMediaType.
+var resultFormatByMediaType = newResultFormats()
+
+func newResultFormats() map[string]resultFormat {
+	formats := map[string]resultFormat{
+		"application/json": {mediaType: "application/json"},
+	}
+	for mediaType, newResultWriter := range resultWriterByMediaType {
+		formats[mediaType] = resultFormat{mediaType: mediaType, newResultWriter: newResultWriter}
+	}
+	for mediaType, renderer := range batchRendererByMediaType {
+		renderer := renderer
+		formats[mediaType] = resultFormat{mediaType: mediaType, renderer: &renderer}
+	}
+
+	return formats
+}
+
+// resultFormatFor returns the format listed first in accept, and false when none
+// is, in which case the result is returned as JSON.
+func resultFormatFor(accept string) (resultFormat, bool) {
+	_, format, ok := firstAccepted(accept, resultFormatByMediaType)
+	return format, ok
+}
+
+// firstAccepted returns the first media type listed in accept that is a key of
+// supported, with its value. Quality values are not taken into account.
+func firstAccepted[T any](accept string, supported map[string]T) (string, T, bool) {
+	for _, v := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(v))
+		if err != nil {
+			continue
+		}
+
+		if value, ok := supported[mediaType]; ok {
+			return mediaType, value, true
+		}
+	}
+
+	var zero T
+	return "", zero, false
+}
+
+var _ resultWriter = (*ndjsonResultWriter)(nil)
+
+// ndjsonResultWriter writes a CalculationResult or a RowError per line. A client
+// tells them apart by the "error" field of a RowError.
+type ndjsonResultWriter struct {
+	writer  *bufio.Writer
+	encoder *json.Encoder
+	options resultOptions
+}
+
+func newNDJSONResultWriter(w io.Writer, options resultOptions) resultWriter {
+	writer := bufio.NewWriter(w)
+	return &ndjsonResultWriter{
+		writer:  writer,
+		encoder: json.NewEncoder(writer),
+		options: options,
+	}
+}
+
+func (n *ndjsonResultWriter) writeResult(_ parsedRow, result CalculationResult) error {
+	if !n.options.includeTaxLevel {
+		result.TaxLevels = nil
+	}
+
+	return n.encoder.Encode(result)
+}
+
+func (n *ndjsonResultWriter) writeRowErrors(_ parsedRow, rowErrors []RowError) error {
+	for _, v := range rowErrors {
+		if err := n.encoder.Encode(v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (n *ndjsonResultWriter) flush() error {
+	return n.writer.Flush()
+}
+
+var _ resultWriter = (*csvResultWriter)(nil)
+
+// csvRequestColumns are the input columns of the CSV result of an upload without
+// columns, i.e. JSON Lines.
+var csvRequestColumns = []string{columnTaxpayerID, columnTaxYear, columnTotalIncome, columnWht}
+
+// csvResultWriter writes the uploaded file back with its input columns followed
+// by tax, taxRefund, the tax of every bracket and the errors of invalid rows.
+//
+// The header is written on the first flush, with a column per bracket of the
+// results written so far. The tax of a bracket that none of the first results
+// has, e.g. of a later row of another tax year, is left out.
+type csvResultWriter struct {
+	writer *csv.Writer
+	// pending are the lines written before the header.
+	pending      []csvResultLine
+	headerDone   bool
+	inputColumns []string
+	levels       []string
+}
+
+type csvResultLine struct {
+	row       parsedRow
+	result    *CalculationResult
+	rowErrors []RowError
+}
+
+// newCSVResultWriter always writes the tax per bracket, whatever the options.
+func newCSVResultWriter(w io.Writer, _ resultOptions) resultWriter {
+	return &csvResultWriter{
+		writer: csv.NewWriter(w),
+	}
+}
+
+func (c *csvResultWriter) writeResult(row parsedRow, result CalculationResult) error {
+	return c.write(csvResultLine{row: row, result: &result})
+}
+
+func (c *csvResultWriter) writeRowErrors(row parsedRow, rowErrors []RowError) error {
+	return c.write(csvResultLine{row: row, rowErrors: rowErrors})
+}
+
+func (c *csvResultWriter) write(line csvResultLine) error {
+	if !c.headerDone {
+		c.pending = append(c.pending, line)
+		return nil
+	}
+
+	return c.writer.Write(c.record(line))
+}
+
+func (c *csvResultWriter) flush() error {
+	if !c.headerDone {
+		if err := c.writeHeader(); err != nil {
+			return err
+		}
+	}
+
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+func (c *csvResultWriter) writeHeader() error {
+	c.inputColumns = csvRequestColumns
+	for _, v := range c.pending {
+		if v.row.columns != nil {
+			c.inputColumns = v.row.columns
+			break
+		}
+	}
+
+	seen := make(map[string]struct{})
+	for _, v := range c.pending {
+		if v.result == nil {
+			continue
+		}
+
+		for _, level := range v.result.TaxLevels {
+			if _, ok := seen[level.Level]; !ok {
+				seen[level.Level] = struct{}{}
+				c.levels = append(c.levels, level.Level)
+			}
+		}
+	}
+
+	header := make([]string, 0, len(c.inputColumns)+len(c.levels)+4)
+	header = append(header, "row")
+	header = append(header, c.inputColumns...)
+	header = append(header, "tax", "taxRefund")
+	for _, v := range c.levels {
+		header = append(header, "tax "+v)
+	}
+	header = append(header, "error")
+
+	if err := c.writer.Write(header); err != nil {
+		return err
+	}
+
+	c.headerDone = true
+	for _, v := range c.pending {
+		if err := c.writer.Write(c.record(v)); err != nil {
+			return err
+		}
+	}
+	c.pending = nil
+
+	return nil
+}
+
+func (c *csvResultWriter) record(line csvResultLine) []string {
+	record := make([]string, 0, len(c.inputColumns)+len(c.levels)+4)
+
+	row := ""
+	if line.row.row > 0 {
+		row = strconv.Itoa(line.row.row)
+	}
+	record = append(record, row)
+
+	for _, column := range c.inputColumns {
+		switch {
+		case line.row.cells != nil:
+			record = append(record, line.row.cells[column])
+		case line.result != nil:
+			record = append(record, requestValue(line.row.request, column))
+		default:
+			record = append(record, "")
+		}
+	}
+
+	if line.result == nil {
+		for i := 0; i < len(c.levels)+2; i++ {
+			record = append(record, "")
+		}
+
+		messages := make([]string, 0, len(line.rowErrors))
+		for _, v := range line.rowErrors {
+			messages = append(messages, v.Error)
+		}
+
+		return append(record, strings.Join(messages, "; "))
+	}
+
+	record = append(record, line.result.Tax.String(), line.result.TaxRefund.String())
+
+	taxByLevel := make(map[string]string, len(line.result.TaxLevels))
+	for _, v := range line.result.TaxLevels {
+		taxByLevel[v.Level] = v.Tax.String()
+	}
+	for _, v := range c.levels {
+		record = append(record, taxByLevel[v])
+	}
+
+	return append(record, "")
+}
+
+// requestValue is the value of an input column of a request read without
+// columns.
+func requestValue(request calculationRequest, column string) string {
+	switch column {
+	case columnTaxpayerID:
+		return request.TaxpayerID
+	case columnTaxYear:
+		return strconv.Itoa(request.taxYear())
+	case columnTotalIncome:
+		return request.grossIncome().String()
+	case columnWht:
+		return request.Wht.String()
+	}
+
+	return ""
+}
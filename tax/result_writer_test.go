@@ -0,0 +1,37 @@
+package tax
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultFormatFor(t *testing.T) {
+	testCases := []struct {
+		accept            string
+		expectedMediaType string
+		expectedStreamed  bool
+		expectedRendered  bool
+		expectedOK        bool
+	}{
+		{accept: "application/x-ndjson", expectedMediaType: "application/x-ndjson", expectedStreamed: true, expectedOK: true},
+		{accept: "text/csv; charset=utf-8", expectedMediaType: "text/csv", expectedStreamed: true, expectedOK: true},
+		{accept: "application/pdf", expectedMediaType: "application/pdf", expectedRendered: true, expectedOK: true},
+		{accept: "application/pdf, text/csv", expectedMediaType: "application/pdf", expectedRendered: true, expectedOK: true},
+		{accept: "text/csv, application/pdf", expectedMediaType: "text/csv", expectedStreamed: true, expectedOK: true},
+		{accept: "application/json, text/csv;q=0.9", expectedMediaType: "application/json", expectedOK: true},
+		{accept: "image/png, text/csv", expectedMediaType: "text/csv", expectedStreamed: true, expectedOK: true},
+		{accept: "*/*", expectedOK: false},
+		{accept: "", expectedOK: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.accept, func(t *testing.T) {
+			format, ok := resultFormatFor(tc.accept)
+			require.Equal(t, tc.expectedOK, ok)
+			require.Equal(t, tc.expectedMediaType, format.mediaType)
+			require.Equal(t, tc.expectedStreamed, format.newResultWriter != nil)
+			require.Equal(t, tc.expectedRendered, format.renderer != nil)
+		})
+	}
+}
@@ -1,84 +1,501 @@
 package tax
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
+	"sort"
 
 	"github.com/chuckboliver/assessment-tax/common"
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
 )
 
 var _ common.Controller = (*TaxController)(nil)
 
+const (
+	ErrorCodeInvalidFile              common.ErrorCode = "INVALID_FILE"
+	ErrorCodeUnsupportedAllowanceType common.ErrorCode = "UNSUPPORTED_ALLOWANCE_TYPE"
+	ErrorCodeUnsupportedIncomeType    common.ErrorCode = "UNSUPPORTED_INCOME_TYPE"
+	ErrorCodeTotalIncomeMismatch      common.ErrorCode = "TOTAL_INCOME_MISMATCH"
+	ErrorCodeUnknownTaxYear           common.ErrorCode = "UNKNOWN_TAX_YEAR"
+	ErrorCodeUnknownConfigVersion     common.ErrorCode = "UNKNOWN_CONFIG_VERSION"
+	ErrorCodeConfigUnavailable        common.ErrorCode = "CONFIG_UNAVAILABLE"
+)
+
 type TaxController struct {
-	taxCalculator Calculator
+	taxCalculator             Calculator
+	calculationHistoryService CalculationHistoryService
+	appConfig                 common.AppConfig
 }
 
-func NewTaxController(taxCalculator Calculator) TaxController {
+func NewTaxController(taxCalculator Calculator, calculationHistoryService CalculationHistoryService, appConfig common.AppConfig) TaxController {
 	return TaxController{
-		taxCalculator: taxCalculator,
+		taxCalculator:             taxCalculator,
+		calculationHistoryService: calculationHistoryService,
+		appConfig:                 appConfig,
 	}
 }
 
 func (c *TaxController) RouteConfig(e *echo.Echo) {
+	uploadMiddlewares := make([]echo.MiddlewareFunc, 0)
+	if c.appConfig.MaxUploadSize != "" {
+		uploadMiddlewares = append(uploadMiddlewares, middleware.BodyLimit(c.appConfig.MaxUploadSize))
+	}
+
 	group := e.Group("/tax/calculations")
 	{
 		group.POST("", c.calculateTax)
-		group.POST("/upload-csv", c.calculateTaxFromUploadedCSV)
+		group.POST("/upload-csv", c.calculateTaxFromUploadedCSV, uploadMiddlewares...)
+		group.POST("/upload", c.calculateTaxFromUpload, uploadMiddlewares...)
+		// Stored calculations hold the incomes and national IDs of every taxpayer,
+		// so only admins can read them.
+		adminAuth := common.AdminBasicAuth(c.appConfig)
+		group.GET("", c.getCalculations, adminAuth)
+		group.GET("/:id", c.getCalculation, adminAuth)
 	}
 }
 
+// Amounts are capped at a trillion baht, and incomes and allowances at 100 items,
+// so that no sum of satang overflows Money.
 type calculationRequest struct {
-	TotalIncome float64     `json:"totalIncome"`
-	Wht         float64     `json:"wht"`
-	Allowances  []Allowance `json:"allowances"`
+	TaxpayerID  string       `json:"taxpayerId,omitempty" validate:"omitempty,thai_national_id"`
+	TaxYear     int          `json:"taxYear,omitempty" validate:"gte=0"`
+	TotalIncome common.Money `json:"totalIncome" validate:"gte=0,lte=1000000000000"`
+	Incomes     []Income     `json:"incomes,omitempty" validate:"max=100,dive"`
+	Wht         common.Money `json:"wht" validate:"gte=0,lte=1000000000000"`
+	Allowances  []Allowance  `json:"allowances" validate:"max=100,dive"`
+	// ConfigVersion asks for the tax to be calculated with the tax config of a
+	// past version instead of the latest one.
+	ConfigVersion int `json:"configVersion,omitempty" validate:"gte=0"`
+	// Explain asks the calculator to return a trace of every calculation step.
+	Explain bool `json:"-"`
+}
+
+func (r calculationRequest) taxYear() int {
+	if r.TaxYear == 0 {
+		return DefaultTaxYear
+	}
+
+	return r.TaxYear
+}
+
+// grossIncome is the sum of typed incomes, or totalIncome when no typed income is given.
+func (r calculationRequest) grossIncome() common.Money {
+	if len(r.Incomes) == 0 {
+		return r.TotalIncome
+	}
+
+	grossIncome := common.Money(0)
+	for _, v := range r.Incomes {
+		grossIncome += v.Amount
+	}
+
+	return grossIncome
 }
 
 func (c *TaxController) calculateTax(ctx echo.Context) error {
 	var request calculationRequest
 	if err := ctx.Bind(&request); err != nil {
-		ctx.JSON(http.StatusBadRequest, common.ErrorResponse{
-			Message: err.Error(),
-		})
 		return err
 	}
 
 	if err := ctx.Validate(&request); err != nil {
-		ctx.JSON(http.StatusBadRequest, common.ErrorResponse{
-			Message: err.Error(),
-		})
 		return err
 	}
 
-	result := c.taxCalculator.Calculate(ctx.Request().Context(), request)
+	if err := echo.QueryParamsBinder(ctx).Bool("explain", &request.Explain).BindError(); err != nil {
+		return err
+	}
+
+	result, err := c.taxCalculator.Calculate(ctx.Request().Context(), request)
+	if err != nil {
+		return calculationError(err)
+	}
+
+	records, err := c.calculationHistoryService.Record(ctx.Request().Context(), CalculationSourceAPI, []calculationEntry{
+		{request: request, response: result},
+	})
+	if err != nil {
+		return fmt.Errorf("record calculation: %w", err)
+	}
+
+	// A calculation that could not be recorded has no location.
+	if len(records) > 0 {
+		ctx.Response().Header().Set(echo.HeaderLocation, "/tax/calculations/"+records[0].ID)
+	}
 	return ctx.JSON(http.StatusOK, result)
 }
 
 func (c *TaxController) calculateTaxFromUploadedCSV(ctx echo.Context) error {
-	fileHeader, err := ctx.FormFile("taxFile")
+	fileHeader, err := formFile(ctx, "taxFile")
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, common.ErrorResponse{
-			Message: err.Error(),
-		})
 		return err
 	}
 
+	return c.calculateTaxFromUploadedFile(ctx, fileHeader, newCSVParser())
+}
+
+// calculateTaxFromUpload calculates an uploaded CSV, xlsx or JSON Lines file, in
+// the format told by the extension or the content type of the file.
+func (c *TaxController) calculateTaxFromUpload(ctx echo.Context) error {
+	fileHeader, err := formFile(ctx, "taxFile")
+	if err != nil {
+		return err
+	}
+
+	parser, ok := parserFor(fileHeader.Filename, fileHeader.Header.Get(echo.HeaderContentType))
+	if !ok {
+		return unsupportedFileError(fileHeader.Filename)
+	}
+
+	return c.calculateTaxFromUploadedFile(ctx, fileHeader, parser)
+}
+
+// formFile returns the uploaded file of the given form field, leaving a body
+// over the upload size limit to be reported by echo.
+func formFile(ctx echo.Context, name string) (*multipart.FileHeader, error) {
+	fileHeader, err := ctx.FormFile(name)
+	var httpError *echo.HTTPError
+	if errors.As(err, &httpError) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, common.NewError(http.StatusBadRequest, common.ErrorCodeMalformedRequest, err)
+	}
+
+	return fileHeader, nil
+}
+
+// calculateTaxFromUploadedFile calculates every row of an uploaded file. Results
+// are returned in the format of resultFormatFor: at once as a
+// BatchCalculationResult or a rendered file, or streamed row by row.
+func (c *TaxController) calculateTaxFromUploadedFile(ctx echo.Context, fileHeader *multipart.FileHeader, parser parser) error {
 	multipartFile, err := fileHeader.Open()
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, common.ErrorResponse{
-			Message: err.Error(),
-		})
+		return common.NewError(http.StatusBadRequest, common.ErrorCodeMalformedRequest, err)
+	}
+	defer multipartFile.Close()
+
+	var partial, includeTaxLevel bool
+	var configVersion int
+	err = echo.QueryParamsBinder(ctx).
+		Bool("partial", &partial).
+		Bool("includeTaxLevel", &includeTaxLevel).
+		Int("configVersion", &configVersion).
+		BindError()
+	if err != nil {
 		return err
 	}
 
-	parser := newCSVParser()
-	calculationRequests, err := parser.parseCalculationRequest(multipartFile)
+	parser = limitRows(limitUnzipSize(parser, c.appConfig.MaxUploadBytes()), c.appConfig.MaxUploadRows)
+
+	format, _ := resultFormatFor(ctx.Request().Header.Get(echo.HeaderAccept))
+	if format.newResultWriter != nil {
+		// A streamed upload is calculated a batch at a time, so it asks every batch
+		// for the version that is the latest when it starts.
+		if configVersion == 0 {
+			configVersion, err = c.taxCalculator.LatestConfigVersion(ctx.Request().Context())
+			if err != nil {
+				return calculationError(err)
+			}
+		}
+
+		// An upload that is not partial is read twice, to be rejected as a whole
+		// before the first result is streamed.
+		if !partial {
+			rowErrors, err := c.checkUploadedFile(ctx, multipartFile, parser, configVersion)
+			if err != nil {
+				return err
+			}
+
+			if len(rowErrors) > 0 {
+				return invalidRowsError(rowErrors)
+			}
+
+			if _, err := multipartFile.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+		}
+
+		writer := format.newResultWriter(ctx.Response(), resultOptions{includeTaxLevel: includeTaxLevel})
+		return c.streamTaxFromUploadedFile(ctx, multipartFile, parser, configVersion, format.mediaType, writer)
+	}
+
+	parsedRows, rowErrors, err := parseCalculationRequest(parser, multipartFile)
+	if err != nil {
+		return uploadError(err)
+	}
+
+	validRows, rowErrors, err := validateRows(ctx.Echo().Validator, parsedRows, rowErrors)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, common.ErrorResponse{
-			Message: err.Error(),
-		})
 		return err
 	}
 
-	result := c.taxCalculator.BatchCalculate(ctx.Request().Context(), calculationRequests)
+	if len(rowErrors) > 0 && !partial {
+		return invalidRowsError(rowErrors)
+	}
+
+	for i := range validRows {
+		validRows[i].request = withConfigVersion(validRows[i].request, configVersion)
+	}
+
+	var result BatchCalculationResult
+	if partial {
+		// A partial upload skips the rows that cannot be calculated, e.g. those of
+		// an unknown tax year, along with the invalid ones.
+		results, calculationErrors, err := calculateRows(ctx.Request().Context(), c.taxCalculator, validRows)
+		if err != nil {
+			return err
+		}
+
+		var calculationRowErrors []RowError
+		validRows, result.Taxes, calculationRowErrors = calculatedRows(validRows, results, calculationErrors)
+		rowErrors = sortRowErrors(append(rowErrors, calculationRowErrors...))
+	} else {
+		calculationRequests := make([]calculationRequest, 0, len(validRows))
+		for _, v := range validRows {
+			calculationRequests = append(calculationRequests, v.request)
+		}
+
+		result, err = c.taxCalculator.BatchCalculate(ctx.Request().Context(), calculationRequests)
+		if err != nil {
+			return calculationError(err)
+		}
+
+		for i, v := range validRows {
+			result.Taxes[i].Row = v.row
+		}
+	}
+
+	entries := make([]calculationEntry, 0, len(validRows))
+	for i, v := range validRows {
+		entries = append(entries, calculationEntry{request: v.request, response: result.Taxes[i]})
+	}
+
+	if len(rowErrors) > 0 {
+		result.SkippedRows = skippedRows(rowErrors)
+		result.Errors = rowErrors
+	}
+
+	if len(entries) > 0 {
+		if _, err := c.calculationHistoryService.Record(ctx.Request().Context(), CalculationSourceCSV, entries); err != nil {
+			return fmt.Errorf("record calculations: %w", err)
+		}
+	}
+
+	if format.renderer != nil {
+		return renderBatchResult(ctx, format, validRows, result)
+	}
+
+	result.Summary = newBatchSummary(result.Taxes)
+	if !includeTaxLevel {
+		withoutTaxLevels(result.Taxes)
+	}
+
 	return ctx.JSON(http.StatusOK, result)
 }
+
+// renderBatchResult returns the whole result of an upload as a file rendered in
+// format. rows are the calculated rows, in the order of result.Taxes.
+func renderBatchResult(ctx echo.Context, format resultFormat, rows []parsedRow, result BatchCalculationResult) error {
+	var body bytes.Buffer
+	if err := format.renderer.render(&body, rows, result); err != nil {
+		return fmt.Errorf("render %s: %w", format.mediaType, err)
+	}
+
+	ctx.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", format.renderer.filename))
+	return ctx.Blob(http.StatusOK, format.mediaType, body.Bytes())
+}
+
+// withConfigVersion asks for configVersion on a row of an upload that does not
+// ask for a version of its own.
+func withConfigVersion(request calculationRequest, configVersion int) calculationRequest {
+	if request.ConfigVersion == 0 {
+		request.ConfigVersion = configVersion
+	}
+
+	return request
+}
+
+// validateRows validates every parsed row, adding the errors of invalid rows to
+// rowErrors, sorted by row.
+func validateRows(validator echo.Validator, parsedRows []parsedRow, rowErrors []RowError) ([]parsedRow, []RowError, error) {
+	validRows := make([]parsedRow, 0, len(parsedRows))
+	for _, v := range parsedRows {
+		if err := validator.Validate(&v.request); err != nil {
+			violations, ok := common.ViolationsOf(err)
+			if !ok {
+				return nil, nil, err
+			}
+
+			rowErrors = append(rowErrors, v.rowErrors(violations)...)
+			continue
+		}
+
+		validRows = append(validRows, v)
+	}
+
+	return validRows, sortRowErrors(rowErrors), nil
+}
+
+// sortRowErrors sorts rowErrors by row, keeping the errors of a row in order.
+func sortRowErrors(rowErrors []RowError) []RowError {
+	sort.SliceStable(rowErrors, func(i, j int) bool {
+		return rowErrors[i].Row < rowErrors[j].Row
+	})
+
+	return rowErrors
+}
+
+// invalidRowsError rejects a file that is not uploaded as partial for its invalid
+// rows, reporting every row error.
+func invalidRowsError(rowErrors []RowError) error {
+	return &common.Error{
+		Status:    http.StatusBadRequest,
+		Code:      ErrorCodeInvalidFile,
+		Message:   fmt.Sprintf("invalid rows in file: %d", countRows(rowErrors)),
+		MessageTH: "ไฟล์มีข้อมูลไม่ถูกต้อง",
+		Details:   rowErrors,
+	}
+}
+
+// skippedRows returns the rows of rowErrors, sorted by row, without duplicates.
+func skippedRows(rowErrors []RowError) []int {
+	rows := make([]int, 0, len(rowErrors))
+	for _, v := range rowErrors {
+		if len(rows) > 0 && rows[len(rows)-1] == v.Row {
+			continue
+		}
+		rows = append(rows, v.Row)
+	}
+
+	return rows
+}
+
+func countRows(rowErrors []RowError) int {
+	return len(skippedRows(rowErrors))
+}
+
+type getCalculationRequest struct {
+	ID string `param:"id" validate:"uuid"`
+}
+
+func (c *TaxController) getCalculation(ctx echo.Context) error {
+	var request getCalculationRequest
+	if err := ctx.Bind(&request); err != nil {
+		return err
+	}
+
+	// No calculation can have an ID that is not a UUID.
+	if err := ctx.Validate(&request); err != nil {
+		return calculationNotFoundError(ErrCalculationNotFound)
+	}
+
+	record, err := c.calculationHistoryService.FindByID(ctx.Request().Context(), request.ID)
+	if errors.Is(err, ErrCalculationNotFound) {
+		return calculationNotFoundError(err)
+	}
+	if err != nil {
+		return fmt.Errorf("get calculation: %w", err)
+	}
+
+	return ctx.JSON(http.StatusOK, record)
+}
+
+type getCalculationsRequest struct {
+	TaxpayerID string `query:"taxpayerId" validate:"required,thai_national_id"`
+	Limit      int    `query:"limit" validate:"gte=0,lte=100"`
+	Offset     int    `query:"offset" validate:"gte=0"`
+}
+
+func (c *TaxController) getCalculations(ctx echo.Context) error {
+	var request getCalculationsRequest
+	if err := ctx.Bind(&request); err != nil {
+		return err
+	}
+
+	if err := ctx.Validate(&request); err != nil {
+		return err
+	}
+
+	page, err := c.calculationHistoryService.FindAll(ctx.Request().Context(), CalculationFilter{
+		TaxpayerID: request.TaxpayerID,
+		Limit:      request.Limit,
+		Offset:     request.Offset,
+	})
+	if err != nil {
+		return fmt.Errorf("get calculations: %w", err)
+	}
+
+	return ctx.JSON(http.StatusOK, page)
+}
+
+func unsupportedFileError(filename string) error {
+	err := fmt.Errorf("unsupported file %s, supported formats are: csv, xlsx, jsonl", filename)
+	return common.NewError(http.StatusUnsupportedMediaType, common.ErrorCodeUnsupportedMediaType, err)
+}
+
+// uploadError maps an error reading an uploaded file to the response reported to
+// the client.
+func uploadError(err error) error {
+	if errors.Is(err, ErrTooManyRows) {
+		return common.NewError(http.StatusRequestEntityTooLarge, common.ErrorCodeRequestTooLarge, err).
+			WithMessageTH("ไฟล์มีจำนวนแถวเกินกำหนด")
+	}
+
+	return common.NewError(http.StatusBadRequest, ErrorCodeInvalidFile, err).
+		WithMessageTH("ไฟล์ไม่ถูกต้อง")
+}
+
+func calculationNotFoundError(err error) error {
+	return common.NewError(http.StatusNotFound, common.ErrorCodeNotFound, err).
+		WithMessageTH("ไม่พบประวัติการคำนวนภาษี")
+}
+
+// calculationError maps the errors of Calculator to the response reported to the
+// client. Other errors are left to common.HTTPErrorHandler as internal errors.
+func calculationError(err error) error {
+	var unsupportedAllowanceTypeError *UnsupportedAllowanceTypeError
+	if errors.As(err, &unsupportedAllowanceTypeError) {
+		return common.NewError(http.StatusBadRequest, ErrorCodeUnsupportedAllowanceType, err).
+			WithMessageTH("ไม่รองรับประเภทค่าลดหย่อนนี้")
+	}
+
+	var unsupportedIncomeTypeError *UnsupportedIncomeTypeError
+	if errors.As(err, &unsupportedIncomeTypeError) {
+		return common.NewError(http.StatusBadRequest, ErrorCodeUnsupportedIncomeType, err).
+			WithMessageTH("ไม่รองรับประเภทเงินได้นี้")
+	}
+
+	if errors.Is(err, ErrTotalIncomeMismatch) {
+		return common.NewError(http.StatusBadRequest, ErrorCodeTotalIncomeMismatch, err).
+			WithMessageTH("totalIncome ไม่เท่ากับผลรวมของเงินได้ทุกประเภท")
+	}
+
+	if errors.Is(err, ErrUnknownTaxYear) {
+		return common.NewError(http.StatusUnprocessableEntity, ErrorCodeUnknownTaxYear, err).
+			WithMessageTH("ไม่พบข้อมูลภาษีของปีภาษีนี้")
+	}
+
+	if errors.Is(err, ErrUnknownConfigVersion) {
+		return common.NewError(http.StatusUnprocessableEntity, ErrorCodeUnknownConfigVersion, err).
+			WithMessageTH("ไม่พบข้อมูลภาษีของเวอร์ชันนี้")
+	}
+
+	// The error of the repository is only logged.
+	if errors.Is(err, ErrConfigUnavailable) {
+		return &common.Error{
+			Status:    http.StatusServiceUnavailable,
+			Code:      ErrorCodeConfigUnavailable,
+			Message:   ErrConfigUnavailable.Error(),
+			MessageTH: "ไม่สามารถอ่านข้อมูลภาษีได้ กรุณาลองใหม่อีกครั้ง",
+			Err:       err,
+		}
+	}
+
+	return fmt.Errorf("calculate tax: %w", err)
+}
@@ -4,28 +4,32 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/chuckboliver/assessment-tax/common"
 	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/require"
 )
 
 func TestPostCalculateTax(t *testing.T) {
-	taxLevels1 := createEmptyTaxLevels()
-	taxLevels1[1].Tax = 29000
+	taxLevels1 := createEmptyTaxLevels(defaultTaxBrackets())
+	taxLevels1[1].Tax = common.Baht(29000)
 
-	taxLevels2 := createEmptyTaxLevels()
-	taxLevels2[1].Tax = 29000
+	taxLevels2 := createEmptyTaxLevels(defaultTaxBrackets())
+	taxLevels2[1].Tax = common.Baht(29000)
 
-	taxLevels3 := createEmptyTaxLevels()
-	taxLevels3[1].Tax = 19000
+	taxLevels3 := createEmptyTaxLevels(defaultTaxBrackets())
+	taxLevels3[1].Tax = common.Baht(19000)
 
-	taxLevels4 := createEmptyTaxLevels()
-	taxLevels4[1].Tax = 20000
+	taxLevels4 := createEmptyTaxLevels(defaultTaxBrackets())
+	taxLevels4[1].Tax = common.Baht(20000)
 
 	testCases := []struct {
 		name     string
@@ -47,7 +51,7 @@ func TestPostCalculateTax(t *testing.T) {
 				}
 			`,
 			expected: CalculationResultWithTaxLevel{
-				Tax:       29000,
+				Tax:       common.Baht(29000),
 				TaxRefund: 0,
 				TaxLevels: taxLevels1,
 			},
@@ -67,7 +71,7 @@ func TestPostCalculateTax(t *testing.T) {
 				}
 			`,
 			expected: CalculationResultWithTaxLevel{
-				Tax:       4000,
+				Tax:       common.Baht(4000),
 				TaxRefund: 0,
 				TaxLevels: taxLevels2,
 			},
@@ -87,7 +91,7 @@ func TestPostCalculateTax(t *testing.T) {
 				}
 			`,
 			expected: CalculationResultWithTaxLevel{
-				Tax:       19000,
+				Tax:       common.Baht(19000),
 				TaxRefund: 0,
 				TaxLevels: taxLevels3,
 			},
@@ -107,7 +111,7 @@ func TestPostCalculateTax(t *testing.T) {
 				}
 			`,
 			expected: CalculationResultWithTaxLevel{
-				Tax:       20000,
+				Tax:       common.Baht(20000),
 				TaxRefund: 0,
 				TaxLevels: taxLevels4,
 			},
@@ -128,10 +132,33 @@ func TestPostCalculateTax(t *testing.T) {
 			`,
 			expected: CalculationResultWithTaxLevel{
 				Tax:       0,
-				TaxRefund: 5321,
+				TaxRefund: common.Baht(5321),
 				TaxLevels: taxLevels4,
 			},
 		},
+		{
+			name: "Should calculate tax correctly, given typed incomes",
+			body: `
+				{
+					"incomes": [
+						{
+							"incomeType": "salary",
+							"amount": 600000
+						}
+					],
+					"wht": 0,
+					"allowances": []
+				}
+			`,
+			expected: CalculationResultWithTaxLevel{
+				Tax:       common.Baht(29000),
+				TaxRefund: 0,
+				TaxLevels: taxLevels1,
+				Incomes: []IncomeBreakdown{
+					{IncomeType: IncomeSalary, Section: "40(1)", Amount: common.Baht(600000), ExpenseDeduction: common.Baht(100000), NetIncome: common.Baht(500000)},
+				},
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -140,9 +167,10 @@ func TestPostCalculateTax(t *testing.T) {
 			defer ctrl.Finish()
 
 			taxCalculator := NewMockCalculator(ctrl)
+			calculationHistoryService := NewMockCalculationHistoryService(ctrl)
 
 			e := common.NewConfiguredEcho()
-			taxController := NewTaxController(taxCalculator)
+			taxController := NewTaxController(taxCalculator, calculationHistoryService, common.AppConfig{})
 			taxController.RouteConfig(e)
 
 			var expectedInputOfCalculate calculationRequest
@@ -150,7 +178,11 @@ func TestPostCalculateTax(t *testing.T) {
 			require.NoError(t, err)
 
 			ctx := context.Background()
-			taxCalculator.EXPECT().Calculate(ctx, expectedInputOfCalculate).Times(1).Return(tc.expected)
+			taxCalculator.EXPECT().Calculate(ctx, expectedInputOfCalculate).Times(1).Return(tc.expected, nil)
+			calculationHistoryService.EXPECT().
+				Record(ctx, CalculationSourceAPI, []calculationEntry{{request: expectedInputOfCalculate, response: tc.expected}}).
+				Times(1).
+				Return([]CalculationRecord{{ID: "8f0e4c5e-2f6a-4a3b-9d43-6d1c2a7f9b10"}}, nil)
 
 			url := "/tax/calculations"
 			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader([]byte(tc.body)))
@@ -162,6 +194,7 @@ func TestPostCalculateTax(t *testing.T) {
 			e.ServeHTTP(recorder, request)
 
 			require.Equal(t, http.StatusOK, recorder.Code)
+			require.Equal(t, "/tax/calculations/8f0e4c5e-2f6a-4a3b-9d43-6d1c2a7f9b10", recorder.Header().Get(echo.HeaderLocation))
 
 			response := recorder.Body
 			responseBytes, err := io.ReadAll(response)
@@ -174,6 +207,1092 @@ func TestPostCalculateTax(t *testing.T) {
 			require.Equal(t, tc.expected.Tax, gotCalculationResult.Tax)
 			require.Equal(t, tc.expected.TaxRefund, gotCalculationResult.TaxRefund)
 			require.Equal(t, tc.expected.TaxLevels, gotCalculationResult.TaxLevels)
+			require.Equal(t, tc.expected.Incomes, gotCalculationResult.Incomes)
+		})
+	}
+}
+
+func TestPostCalculateTaxWithUnknownTaxYear(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	taxCalculator := NewMockCalculator(ctrl)
+	calculationHistoryService := NewMockCalculationHistoryService(ctrl)
+
+	e := common.NewConfiguredEcho()
+	taxController := NewTaxController(taxCalculator, calculationHistoryService, common.AppConfig{})
+	taxController.RouteConfig(e)
+
+	taxCalculator.EXPECT().Calculate(gomock.Any(), gomock.Any()).Times(1).
+		Return(CalculationResultWithTaxLevel{}, fmt.Errorf("%w: %d", ErrUnknownTaxYear, 2500))
+
+	body := `
+		{
+			"taxYear": 2500,
+			"totalIncome": 500000,
+			"wht": 0,
+			"allowances": []
+		}
+	`
+	request, err := http.NewRequest(http.MethodPost, "/tax/calculations", bytes.NewReader([]byte(body)))
+	require.NoError(t, err)
+
+	request.Header.Set("Content-Type", "application/json")
+
+	recorder := httptest.NewRecorder()
+	e.ServeHTTP(recorder, request)
+
+	require.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+}
+
+func TestPostCalculateTaxWithConfigUnavailable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	taxCalculator := NewMockCalculator(ctrl)
+	calculationHistoryService := NewMockCalculationHistoryService(ctrl)
+
+	e := common.NewConfiguredEcho()
+	taxController := NewTaxController(taxCalculator, calculationHistoryService, common.AppConfig{})
+	taxController.RouteConfig(e)
+
+	taxCalculator.EXPECT().Calculate(gomock.Any(), gomock.Any()).Times(1).
+		Return(CalculationResultWithTaxLevel{}, fmt.Errorf("%w: %w", ErrConfigUnavailable, errors.New("connection refused")))
+	calculationHistoryService.EXPECT().Record(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+	body := `{"totalIncome": 500000, "wht": 0, "allowances": []}`
+	request, err := http.NewRequest(http.MethodPost, "/tax/calculations", bytes.NewReader([]byte(body)))
+	require.NoError(t, err)
+
+	request.Header.Set("Content-Type", "application/json")
+
+	recorder := httptest.NewRecorder()
+	e.ServeHTTP(recorder, request)
+
+	require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+
+	var response common.ErrorResponse
+	err = json.Unmarshal(recorder.Body.Bytes(), &response)
+	require.NoError(t, err)
+	require.Equal(t, ErrorCodeConfigUnavailable, response.Code)
+	require.Equal(t, "tax config unavailable", response.Message)
+}
+
+func TestPostCalculateTaxWithUnsupportedAllowanceType(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	taxCalculator := NewMockCalculator(ctrl)
+	calculationHistoryService := NewMockCalculationHistoryService(ctrl)
+
+	e := common.NewConfiguredEcho()
+	taxController := NewTaxController(taxCalculator, calculationHistoryService, common.AppConfig{})
+	taxController.RouteConfig(e)
+
+	taxCalculator.EXPECT().Calculate(gomock.Any(), gomock.Any()).Times(0)
+
+	body := `
+		{
+			"totalIncome": 500000,
+			"wht": 0,
+			"allowances": [
+				{
+					"allowanceType": "lottery",
+					"amount": 1000
+				}
+			]
+		}
+	`
+	request, err := http.NewRequest(http.MethodPost, "/tax/calculations", bytes.NewReader([]byte(body)))
+	require.NoError(t, err)
+
+	request.Header.Set("Content-Type", "application/json")
+
+	recorder := httptest.NewRecorder()
+	e.ServeHTTP(recorder, request)
+
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	var response common.ErrorResponse
+	err = json.Unmarshal(recorder.Body.Bytes(), &response)
+	require.NoError(t, err)
+	require.Len(t, response.Violations, 1)
+	require.Equal(t, "allowances[0].allowanceType", response.Violations[0].Field)
+	require.Equal(t, "oneof", response.Violations[0].Rule)
+	require.Contains(t, response.Violations[0].Param, "k-receipt")
+}
+
+func TestPostCalculateTaxWithExplain(t *testing.T) {
+	testCases := []struct {
+		name            string
+		query           string
+		expectedExplain bool
+	}{
+		{name: "Should not explain, given no explain query param", query: "", expectedExplain: false},
+		{name: "Should explain, given explain=true", query: "?explain=true", expectedExplain: true},
+		{name: "Should not explain, given explain=false", query: "?explain=false", expectedExplain: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			taxCalculator := NewMockCalculator(ctrl)
+			calculationHistoryService := NewMockCalculationHistoryService(ctrl)
+
+			e := common.NewConfiguredEcho()
+			taxController := NewTaxController(taxCalculator, calculationHistoryService, common.AppConfig{})
+			taxController.RouteConfig(e)
+
+			taxCalculator.EXPECT().Calculate(gomock.Any(), gomock.Any()).Times(1).
+				DoAndReturn(func(_ context.Context, param calculationRequest) (CalculationResultWithTaxLevel, error) {
+					require.Equal(t, tc.expectedExplain, param.Explain)
+					return CalculationResultWithTaxLevel{}, nil
+				})
+			calculationHistoryService.EXPECT().Record(gomock.Any(), CalculationSourceAPI, gomock.Any()).Times(1).
+				Return([]CalculationRecord{{ID: "8f0e4c5e-2f6a-4a3b-9d43-6d1c2a7f9b10"}}, nil)
+
+			body := `{"totalIncome": 500000, "wht": 0, "allowances": []}`
+			request, err := http.NewRequest(http.MethodPost, "/tax/calculations"+tc.query, bytes.NewReader([]byte(body)))
+			require.NoError(t, err)
+
+			request.Header.Set("Content-Type", "application/json")
+
+			recorder := httptest.NewRecorder()
+			e.ServeHTTP(recorder, request)
+
+			require.Equal(t, http.StatusOK, recorder.Code)
+		})
+	}
+}
+
+func TestPostCalculateTaxWithInvalidExplain(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	taxCalculator := NewMockCalculator(ctrl)
+	calculationHistoryService := NewMockCalculationHistoryService(ctrl)
+
+	e := common.NewConfiguredEcho()
+	taxController := NewTaxController(taxCalculator, calculationHistoryService, common.AppConfig{})
+	taxController.RouteConfig(e)
+
+	body := `{"totalIncome": 500000, "wht": 0, "allowances": []}`
+	request, err := http.NewRequest(http.MethodPost, "/tax/calculations?explain=maybe", bytes.NewReader([]byte(body)))
+	require.NoError(t, err)
+
+	request.Header.Set("Content-Type", "application/json")
+
+	recorder := httptest.NewRecorder()
+	e.ServeHTTP(recorder, request)
+
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestPostCalculateTaxWithRecordError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	taxCalculator := NewMockCalculator(ctrl)
+	calculationHistoryService := NewMockCalculationHistoryService(ctrl)
+
+	e := common.NewConfiguredEcho()
+	taxController := NewTaxController(taxCalculator, calculationHistoryService, common.AppConfig{})
+	taxController.RouteConfig(e)
+
+	taxCalculator.EXPECT().Calculate(gomock.Any(), gomock.Any()).Times(1).Return(CalculationResultWithTaxLevel{}, nil)
+	calculationHistoryService.EXPECT().Record(gomock.Any(), CalculationSourceAPI, gomock.Any()).Times(1).
+		Return(nil, errors.New("connection refused"))
+
+	body := `{"totalIncome": 500000, "wht": 0, "allowances": []}`
+	request, err := http.NewRequest(http.MethodPost, "/tax/calculations", bytes.NewReader([]byte(body)))
+	require.NoError(t, err)
+
+	request.Header.Set("Content-Type", "application/json")
+
+	recorder := httptest.NewRecorder()
+	e.ServeHTTP(recorder, request)
+
+	require.Equal(t, http.StatusInternalServerError, recorder.Code)
+}
+
+// historyAppConfig holds the admin credentials required to read stored
+// calculations and jobs.
+var historyAppConfig = common.AppConfig{
+	AdminUsername: "admin",
+	AdminPassword: "P@ssw0rd",
+}
+
+func TestPostCalculateTaxWithoutRecord(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	taxCalculator := NewMockCalculator(ctrl)
+	calculationHistoryService := NewMockCalculationHistoryService(ctrl)
+
+	e := common.NewConfiguredEcho()
+	taxController := NewTaxController(taxCalculator, calculationHistoryService, common.AppConfig{})
+	taxController.RouteConfig(e)
+
+	taxCalculator.EXPECT().Calculate(gomock.Any(), gomock.Any()).Times(1).
+		Return(CalculationResultWithTaxLevel{Tax: common.Baht(29000), ConfigSource: ConfigSourceDefault}, nil)
+	// A lenient CalculationHistoryService records nothing when the database is down.
+	calculationHistoryService.EXPECT().Record(gomock.Any(), CalculationSourceAPI, gomock.Any()).Times(1).Return(nil, nil)
+
+	body := `{"totalIncome": 500000, "wht": 0, "allowances": []}`
+	request, err := http.NewRequest(http.MethodPost, "/tax/calculations", bytes.NewReader([]byte(body)))
+	require.NoError(t, err)
+
+	request.Header.Set("Content-Type", "application/json")
+
+	recorder := httptest.NewRecorder()
+	e.ServeHTTP(recorder, request)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Empty(t, recorder.Header().Get(echo.HeaderLocation))
+}
+
+func TestGetCalculation(t *testing.T) {
+	id := "8f0e4c5e-2f6a-4a3b-9d43-6d1c2a7f9b10"
+
+	testCases := []struct {
+		name         string
+		id           string
+		withoutAuth  bool
+		mock         func(calculationHistoryService *MockCalculationHistoryService)
+		expectedCode int
+	}{
+		{
+			name: "Should return calculation, given existing id",
+			id:   id,
+			mock: func(calculationHistoryService *MockCalculationHistoryService) {
+				calculationHistoryService.EXPECT().FindByID(gomock.Any(), id).Times(1).Return(CalculationRecord{
+					ID:       id,
+					Source:   CalculationSourceAPI,
+					Request:  json.RawMessage(`{"totalIncome":500000.0}`),
+					Response: json.RawMessage(`{"tax":29000.0}`),
+				}, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name: "Should return 404, given unknown id",
+			id:   id,
+			mock: func(calculationHistoryService *MockCalculationHistoryService) {
+				calculationHistoryService.EXPECT().FindByID(gomock.Any(), id).Times(1).Return(CalculationRecord{}, ErrCalculationNotFound)
+			},
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "Should return 404, given id that is not a UUID",
+			id:           "123",
+			mock:         func(calculationHistoryService *MockCalculationHistoryService) {},
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "Should return 401, given no admin credentials",
+			id:           id,
+			withoutAuth:  true,
+			mock:         func(calculationHistoryService *MockCalculationHistoryService) {},
+			expectedCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			taxCalculator := NewMockCalculator(ctrl)
+			calculationHistoryService := NewMockCalculationHistoryService(ctrl)
+			tc.mock(calculationHistoryService)
+
+			e := common.NewConfiguredEcho()
+			taxController := NewTaxController(taxCalculator, calculationHistoryService, historyAppConfig)
+			taxController.RouteConfig(e)
+
+			request, err := http.NewRequest(http.MethodGet, "/tax/calculations/"+tc.id, nil)
+			require.NoError(t, err)
+
+			if !tc.withoutAuth {
+				request.SetBasicAuth("admin", "P@ssw0rd")
+			}
+
+			recorder := httptest.NewRecorder()
+			e.ServeHTTP(recorder, request)
+
+			require.Equal(t, tc.expectedCode, recorder.Code)
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+
+			var got CalculationRecord
+			err = json.Unmarshal(recorder.Body.Bytes(), &got)
+			require.NoError(t, err)
+			require.Equal(t, id, got.ID)
+			require.JSONEq(t, `{"tax":29000.0}`, string(got.Response))
+		})
+	}
+}
+
+func TestGetCalculations(t *testing.T) {
+	testCases := []struct {
+		name           string
+		query          string
+		withoutAuth    bool
+		expectedFilter *CalculationFilter
+		expectedCode   int
+	}{
+		{
+			name:           "Should list calculations of taxpayer",
+			query:          "?taxpayerId=1101700203450&limit=10&offset=20",
+			expectedFilter: &CalculationFilter{TaxpayerID: "1101700203450", Limit: 10, Offset: 20},
+			expectedCode:   http.StatusOK,
+		},
+		{
+			name:         "Should return 400, given no taxpayerId",
+			query:        "",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Should return 400, given invalid taxpayerId",
+			query:        "?taxpayerId=1101700203451",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Should return 400, given limit over maximum",
+			query:        "?taxpayerId=1101700203450&limit=101",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Should return 400, given negative offset",
+			query:        "?taxpayerId=1101700203450&offset=-1",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Should return 401, given no admin credentials",
+			query:        "?taxpayerId=1101700203450",
+			withoutAuth:  true,
+			expectedCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			taxCalculator := NewMockCalculator(ctrl)
+			calculationHistoryService := NewMockCalculationHistoryService(ctrl)
+
+			e := common.NewConfiguredEcho()
+			taxController := NewTaxController(taxCalculator, calculationHistoryService, historyAppConfig)
+			taxController.RouteConfig(e)
+
+			if tc.expectedFilter != nil {
+				calculationHistoryService.EXPECT().FindAll(gomock.Any(), *tc.expectedFilter).Times(1).
+					Return(CalculationPage{Calculations: []CalculationRecord{}, Total: 0, Limit: 20}, nil)
+			}
+
+			request, err := http.NewRequest(http.MethodGet, "/tax/calculations"+tc.query, nil)
+			require.NoError(t, err)
+
+			if !tc.withoutAuth {
+				request.SetBasicAuth("admin", "P@ssw0rd")
+			}
+
+			recorder := httptest.NewRecorder()
+			e.ServeHTTP(recorder, request)
+
+			require.Equal(t, tc.expectedCode, recorder.Code)
+		})
+	}
+}
+
+func TestPostCalculateTaxWithInvalidTaxpayerID(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	taxCalculator := NewMockCalculator(ctrl)
+	calculationHistoryService := NewMockCalculationHistoryService(ctrl)
+
+	e := common.NewConfiguredEcho()
+	taxController := NewTaxController(taxCalculator, calculationHistoryService, common.AppConfig{})
+	taxController.RouteConfig(e)
+
+	body := `{"taxpayerId": "1101700203451", "totalIncome": 500000, "wht": 0, "allowances": []}`
+	request, err := http.NewRequest(http.MethodPost, "/tax/calculations", bytes.NewReader([]byte(body)))
+	require.NoError(t, err)
+
+	request.Header.Set("Content-Type", "application/json")
+
+	recorder := httptest.NewRecorder()
+	e.ServeHTTP(recorder, request)
+
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	var response common.ErrorResponse
+	err = json.Unmarshal(recorder.Body.Bytes(), &response)
+	require.NoError(t, err)
+	require.Equal(t, common.ErrorCodeValidationFailed, response.Code)
+	require.Equal(t, []common.Violation{
+		{Field: "taxpayerId", Rule: "thai_national_id"},
+	}, response.Violations)
+}
+
+func TestPostCalculateTaxWithAmountOverLimit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	taxCalculator := NewMockCalculator(ctrl)
+	taxCalculator.EXPECT().Calculate(gomock.Any(), gomock.Any()).Times(0)
+	calculationHistoryService := NewMockCalculationHistoryService(ctrl)
+
+	e := common.NewConfiguredEcho()
+	taxController := NewTaxController(taxCalculator, calculationHistoryService, common.AppConfig{})
+	taxController.RouteConfig(e)
+
+	body := `{"totalIncome": 92233720368547758.07, "allowances": [{"allowanceType": "double-donation", "amount": 92233720368547758.07}]}`
+	request, err := http.NewRequest(http.MethodPost, "/tax/calculations", bytes.NewReader([]byte(body)))
+	require.NoError(t, err)
+
+	request.Header.Set("Content-Type", "application/json")
+
+	recorder := httptest.NewRecorder()
+	e.ServeHTTP(recorder, request)
+
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	var response common.ErrorResponse
+	err = json.Unmarshal(recorder.Body.Bytes(), &response)
+	require.NoError(t, err)
+	require.Equal(t, common.ErrorCodeValidationFailed, response.Code)
+	require.Equal(t, []common.Violation{
+		{Field: "totalIncome", Rule: "lte", Param: "1000000000000"},
+		{Field: "allowances[0].amount", Rule: "lte", Param: "1000000000000"},
+	}, response.Violations)
+}
+
+func TestPostCalculateTaxFromUploadedCSVWithInvalidRows(t *testing.T) {
+	csv := "totalIncome,wht,donation\n500000,0,0\n600000,600001,20000\n750000,abc,0\n"
+
+	testCases := []struct {
+		name  string
+		query string
+		// csv is the uploaded file, the file with invalid rows when empty.
+		csv          string
+		mock         func(taxCalculator *MockCalculator, calculationHistoryService *MockCalculationHistoryService)
+		expectedCode int
+		checkBody    func(t *testing.T, body []byte)
+	}{
+		{
+			name:  "Should report every invalid row, given upload that is not partial",
+			query: "",
+			mock: func(taxCalculator *MockCalculator, calculationHistoryService *MockCalculationHistoryService) {
+				taxCalculator.EXPECT().BatchCalculate(gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectedCode: http.StatusBadRequest,
+			checkBody: func(t *testing.T, body []byte) {
+				var response struct {
+					Code    common.ErrorCode `json:"code"`
+					Message string           `json:"message"`
+					Details []RowError       `json:"details"`
+				}
+				err := json.Unmarshal(body, &response)
+				require.NoError(t, err)
+
+				require.Equal(t, ErrorCodeInvalidFile, response.Code)
+				require.Equal(t, "invalid rows in file: 2", response.Message)
+				require.Equal(t, []RowError{
+					{Row: 3, Column: "wht", Value: "600001", Error: "wht failed on ltefield=totalIncome"},
+					{Row: 4, Column: "wht", Value: "abc", Error: `failed to parse wht: invalid amount "abc"`},
+				}, response.Details)
+			},
+		},
+		{
+			name:  "Should calculate valid rows and skip invalid rows, given partial upload",
+			query: "?partial=true",
+			mock: func(taxCalculator *MockCalculator, calculationHistoryService *MockCalculationHistoryService) {
+				taxCalculator.EXPECT().BatchCalculate(gomock.Any(), []calculationRequest{
+					{TotalIncome: common.Baht(500000), Allowances: []Allowance{{AllowanceType: AllowanceDonation, Amount: 0}}},
+				}).Times(1).Return(BatchCalculationResult{
+					Taxes: []CalculationResult{{TotalIncome: common.Baht(500000), Tax: common.Baht(29000)}},
+				}, nil)
+				calculationHistoryService.EXPECT().Record(gomock.Any(), CalculationSourceCSV, gomock.Len(1)).Times(1).Return(nil, nil)
+			},
+			expectedCode: http.StatusOK,
+			checkBody: func(t *testing.T, body []byte) {
+				var response BatchCalculationResult
+				err := json.Unmarshal(body, &response)
+				require.NoError(t, err)
+
+				require.Equal(t, []CalculationResult{{Row: 2, TotalIncome: common.Baht(500000), Tax: common.Baht(29000)}}, response.Taxes)
+				require.Equal(t, []int{3, 4}, response.SkippedRows)
+				require.Len(t, response.Errors, 2)
+			},
+		},
+		{
+			name:  "Should calculate every row with requested config version, given configVersion",
+			query: "?partial=true&configVersion=2",
+			mock: func(taxCalculator *MockCalculator, calculationHistoryService *MockCalculationHistoryService) {
+				taxCalculator.EXPECT().BatchCalculate(gomock.Any(), []calculationRequest{
+					{TotalIncome: common.Baht(500000), Allowances: []Allowance{{AllowanceType: AllowanceDonation, Amount: 0}}, ConfigVersion: 2},
+				}).Times(1).Return(BatchCalculationResult{
+					Taxes: []CalculationResult{{ConfigVersion: 2, TotalIncome: common.Baht(500000), Tax: common.Baht(29000)}},
+				}, nil)
+				calculationHistoryService.EXPECT().Record(gomock.Any(), CalculationSourceCSV, gomock.Len(1)).Times(1).Return(nil, nil)
+			},
+			expectedCode: http.StatusOK,
+			checkBody: func(t *testing.T, body []byte) {
+				var response BatchCalculationResult
+				err := json.Unmarshal(body, &response)
+				require.NoError(t, err)
+
+				require.Equal(t, []CalculationResult{{Row: 2, ConfigVersion: 2, TotalIncome: common.Baht(500000), Tax: common.Baht(29000)}}, response.Taxes)
+			},
+		},
+		{
+			name:  "Should skip row, given unknown config version and partial upload",
+			query: "?partial=true&configVersion=99",
+			mock: func(taxCalculator *MockCalculator, calculationHistoryService *MockCalculationHistoryService) {
+				taxCalculator.EXPECT().BatchCalculate(gomock.Any(), gomock.Len(1)).Times(1).
+					Return(BatchCalculationResult{}, fmt.Errorf("%w: %d", ErrUnknownConfigVersion, 99))
+				calculationHistoryService.EXPECT().Record(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectedCode: http.StatusOK,
+			checkBody: func(t *testing.T, body []byte) {
+				var response BatchCalculationResult
+				err := json.Unmarshal(body, &response)
+				require.NoError(t, err)
+
+				require.Empty(t, response.Taxes)
+				require.Equal(t, []int{2, 3, 4}, response.SkippedRows)
+				require.Equal(t, RowError{Row: 2, Error: "unknown config version: 99"}, response.Errors[0])
+			},
+		},
+		{
+			name:  "Should calculate other rows, given row of unknown tax year and partial upload",
+			query: "?partial=true",
+			csv:   "totalIncome,taxYear\n500000,2567\n600000,2500\n",
+			mock: func(taxCalculator *MockCalculator, calculationHistoryService *MockCalculationHistoryService) {
+				gomock.InOrder(
+					taxCalculator.EXPECT().BatchCalculate(gomock.Any(), gomock.Len(2)).Times(1).
+						Return(BatchCalculationResult{}, fmt.Errorf("%w: %d", ErrUnknownTaxYear, 2500)),
+					taxCalculator.EXPECT().BatchCalculate(gomock.Any(), gomock.Len(1)).Times(1).Return(BatchCalculationResult{
+						Taxes: []CalculationResult{{TaxYear: 2567, TotalIncome: common.Baht(500000), Tax: common.Baht(29000)}},
+					}, nil),
+					taxCalculator.EXPECT().BatchCalculate(gomock.Any(), gomock.Len(1)).Times(1).
+						Return(BatchCalculationResult{}, fmt.Errorf("%w: %d", ErrUnknownTaxYear, 2500)),
+				)
+				calculationHistoryService.EXPECT().Record(gomock.Any(), CalculationSourceCSV, gomock.Len(1)).Times(1).Return(nil, nil)
+			},
+			expectedCode: http.StatusOK,
+			checkBody: func(t *testing.T, body []byte) {
+				var response BatchCalculationResult
+				err := json.Unmarshal(body, &response)
+				require.NoError(t, err)
+
+				require.Equal(t, []CalculationResult{{Row: 2, TaxYear: 2567, TotalIncome: common.Baht(500000), Tax: common.Baht(29000)}}, response.Taxes)
+				require.Equal(t, []int{3}, response.SkippedRows)
+				require.Equal(t, []RowError{{Row: 3, Error: "unknown tax year: 2500"}}, response.Errors)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			taxCalculator := NewMockCalculator(ctrl)
+			calculationHistoryService := NewMockCalculationHistoryService(ctrl)
+			tc.mock(taxCalculator, calculationHistoryService)
+
+			e := common.NewConfiguredEcho()
+			taxController := NewTaxController(taxCalculator, calculationHistoryService, common.AppConfig{})
+			taxController.RouteConfig(e)
+
+			var body bytes.Buffer
+			writer := multipart.NewWriter(&body)
+			part, err := writer.CreateFormFile("taxFile", "taxes.csv")
+			require.NoError(t, err)
+			content := csv
+			if tc.csv != "" {
+				content = tc.csv
+			}
+			_, err = part.Write([]byte(content))
+			require.NoError(t, err)
+			require.NoError(t, writer.Close())
+
+			request, err := http.NewRequest(http.MethodPost, "/tax/calculations/upload-csv"+tc.query, &body)
+			require.NoError(t, err)
+
+			request.Header.Set("Content-Type", writer.FormDataContentType())
+
+			recorder := httptest.NewRecorder()
+			e.ServeHTTP(recorder, request)
+
+			require.Equal(t, tc.expectedCode, recorder.Code)
+			tc.checkBody(t, recorder.Body.Bytes())
+		})
+	}
+}
+
+func TestPostCalculateTaxFromUpload(t *testing.T) {
+	testCases := []struct {
+		name         string
+		filename     string
+		content      string
+		mock         func(taxCalculator *MockCalculator, calculationHistoryService *MockCalculationHistoryService)
+		expectedCode int
+		checkBody    func(t *testing.T, body []byte)
+	}{
+		{
+			name:     "Should calculate tax, given JSON Lines file",
+			filename: "taxes.jsonl",
+			content:  `{"totalIncome": 500000, "wht": 0, "allowances": [{"allowanceType": "donation", "amount": 0}]}` + "\n",
+			mock: func(taxCalculator *MockCalculator, calculationHistoryService *MockCalculationHistoryService) {
+				taxCalculator.EXPECT().BatchCalculate(gomock.Any(), []calculationRequest{
+					{TotalIncome: common.Baht(500000), Allowances: []Allowance{{AllowanceType: AllowanceDonation, Amount: 0}}},
+				}).Times(1).Return(BatchCalculationResult{
+					Taxes: []CalculationResult{{TotalIncome: common.Baht(500000), Tax: common.Baht(29000)}},
+				}, nil)
+				calculationHistoryService.EXPECT().Record(gomock.Any(), CalculationSourceCSV, gomock.Len(1)).Times(1).Return(nil, nil)
+			},
+			expectedCode: http.StatusOK,
+			checkBody: func(t *testing.T, body []byte) {
+				var response BatchCalculationResult
+				err := json.Unmarshal(body, &response)
+				require.NoError(t, err)
+
+				require.Equal(t, []CalculationResult{{Row: 1, TotalIncome: common.Baht(500000), Tax: common.Baht(29000)}}, response.Taxes)
+			},
+		},
+		{
+			name:     "Should return unsupported media type, given unsupported file",
+			filename: "taxes.txt",
+			content:  "totalIncome\n500000\n",
+			mock: func(taxCalculator *MockCalculator, calculationHistoryService *MockCalculationHistoryService) {
+				taxCalculator.EXPECT().BatchCalculate(gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectedCode: http.StatusUnsupportedMediaType,
+			checkBody: func(t *testing.T, body []byte) {
+				var response common.ErrorResponse
+				err := json.Unmarshal(body, &response)
+				require.NoError(t, err)
+
+				require.Equal(t, common.ErrorCodeUnsupportedMediaType, response.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			taxCalculator := NewMockCalculator(ctrl)
+			calculationHistoryService := NewMockCalculationHistoryService(ctrl)
+			tc.mock(taxCalculator, calculationHistoryService)
+
+			e := common.NewConfiguredEcho()
+			taxController := NewTaxController(taxCalculator, calculationHistoryService, common.AppConfig{})
+			taxController.RouteConfig(e)
+
+			var body bytes.Buffer
+			writer := multipart.NewWriter(&body)
+			part, err := writer.CreateFormFile("taxFile", tc.filename)
+			require.NoError(t, err)
+			_, err = part.Write([]byte(tc.content))
+			require.NoError(t, err)
+			require.NoError(t, writer.Close())
+
+			request, err := http.NewRequest(http.MethodPost, "/tax/calculations/upload", &body)
+			require.NoError(t, err)
+
+			request.Header.Set("Content-Type", writer.FormDataContentType())
+
+			recorder := httptest.NewRecorder()
+			e.ServeHTTP(recorder, request)
+
+			require.Equal(t, tc.expectedCode, recorder.Code)
+			tc.checkBody(t, recorder.Body.Bytes())
+		})
+	}
+}
+
+func TestPostCalculateTaxFromUploadStreamed(t *testing.T) {
+	csv := "totalIncome,wht,taxYear\n500000,0,\n600000,600001,\n750000,0,2500\n"
+
+	testCases := []struct {
+		name         string
+		accept       string
+		mock         func(taxCalculator *MockCalculator, calculationHistoryService *MockCalculationHistoryService)
+		expectedType string
+		expectedBody string
+	}{
+		{
+			name:   "Should stream a result or an error per row as NDJSON, given Accept application/x-ndjson",
+			accept: "application/x-ndjson",
+			mock: func(taxCalculator *MockCalculator, calculationHistoryService *MockCalculationHistoryService) {
+				gomock.InOrder(
+					taxCalculator.EXPECT().LatestConfigVersion(gomock.Any()).Times(1).Return(4, nil),
+					taxCalculator.EXPECT().BatchCalculate(gomock.Any(), gomock.Len(2)).Times(1).Return(BatchCalculationResult{}, ErrUnknownTaxYear),
+					taxCalculator.EXPECT().BatchCalculate(gomock.Any(), []calculationRequest{
+						{TotalIncome: common.Baht(500000), Allowances: []Allowance{}, ConfigVersion: 4},
+					}).Times(1).Return(BatchCalculationResult{
+						Taxes: []CalculationResult{{TaxYear: 2567, ConfigVersion: 4, ConfigSource: ConfigSourceDatabase, TotalIncome: common.Baht(500000), Tax: common.Baht(29000)}},
+					}, nil),
+					taxCalculator.EXPECT().BatchCalculate(gomock.Any(), gomock.Len(1)).Times(1).Return(BatchCalculationResult{}, ErrUnknownTaxYear),
+				)
+				calculationHistoryService.EXPECT().Record(gomock.Any(), CalculationSourceCSV, gomock.Len(1)).Times(1).Return(nil, nil)
+			},
+			expectedType: "application/x-ndjson",
+			expectedBody: `{"row":2,"taxYear":2567,"configVersion":4,"configSource":"database","totalIncome":500000.0,"tax":29000.0,"taxRefund":0.0}
+{"row":3,"column":"wht","value":"600001","error":"wht failed on ltefield=totalIncome"}
+{"row":4,"error":"unknown tax year"}
+`,
+		},
+		{
+			name:   "Should stream file back as CSV with tax per bracket, given Accept text/csv",
+			accept: "text/csv",
+			mock: func(taxCalculator *MockCalculator, calculationHistoryService *MockCalculationHistoryService) {
+				taxCalculator.EXPECT().LatestConfigVersion(gomock.Any()).Times(1).Return(4, nil)
+				taxCalculator.EXPECT().BatchCalculate(gomock.Any(), gomock.Len(2)).Times(1).Return(BatchCalculationResult{
+					Taxes: []CalculationResult{
+						{TaxYear: 2567, TotalIncome: common.Baht(500000), Tax: common.Baht(29000), TaxLevels: []TaxLevel{
+							{Level: "0-150,000", Tax: 0},
+							{Level: "150,001-500,000", Tax: common.Baht(29000)},
+						}},
+						{TaxYear: 2500, TotalIncome: common.Baht(750000), Tax: common.Baht(63750), TaxLevels: []TaxLevel{
+							{Level: "0-150,000", Tax: 0},
+							{Level: "150,001-500,000", Tax: common.Baht(35000)},
+							{Level: "500,001-1,000,000", Tax: common.Baht(28750)},
+						}},
+					},
+				}, nil)
+				calculationHistoryService.EXPECT().Record(gomock.Any(), CalculationSourceCSV, gomock.Len(2)).Times(1).Return(nil, nil)
+			},
+			expectedType: "text/csv",
+			expectedBody: `row,totalIncome,wht,taxYear,tax,taxRefund,"tax 0-150,000","tax 150,001-500,000","tax 500,001-1,000,000",error
+2,500000,0,,29000.0,0.0,0.0,29000.0,,
+3,600000,600001,,,,,,,wht failed on ltefield=totalIncome
+4,750000,0,2500,63750.0,0.0,0.0,35000.0,28750.0,
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			taxCalculator := NewMockCalculator(ctrl)
+			calculationHistoryService := NewMockCalculationHistoryService(ctrl)
+			tc.mock(taxCalculator, calculationHistoryService)
+
+			e := common.NewConfiguredEcho()
+			taxController := NewTaxController(taxCalculator, calculationHistoryService, common.AppConfig{})
+			taxController.RouteConfig(e)
+
+			var body bytes.Buffer
+			writer := multipart.NewWriter(&body)
+			part, err := writer.CreateFormFile("taxFile", "taxes.csv")
+			require.NoError(t, err)
+			_, err = part.Write([]byte(csv))
+			require.NoError(t, err)
+			require.NoError(t, writer.Close())
+
+			request, err := http.NewRequest(http.MethodPost, "/tax/calculations/upload?partial=true", &body)
+			require.NoError(t, err)
+
+			request.Header.Set("Content-Type", writer.FormDataContentType())
+			request.Header.Set("Accept", tc.accept)
+
+			recorder := httptest.NewRecorder()
+			e.ServeHTTP(recorder, request)
+
+			require.Equal(t, http.StatusOK, recorder.Code)
+			require.Equal(t, tc.expectedType, recorder.Header().Get("Content-Type"))
+			require.Equal(t, tc.expectedBody, recorder.Body.String())
+		})
+	}
+}
+
+func TestPostCalculateTaxFromUploadStreamedNotPartial(t *testing.T) {
+	testCases := []struct {
+		name           string
+		csv            string
+		mock           func(taxCalculator *MockCalculator, calculationHistoryService *MockCalculationHistoryService)
+		expectedCode   int
+		expectedBody   string
+		expectedErrors []RowError
+	}{
+		{
+			name: "Should stream results, given only valid rows",
+			csv:  "totalIncome\n500000\n",
+			mock: func(taxCalculator *MockCalculator, calculationHistoryService *MockCalculationHistoryService) {
+				taxCalculator.EXPECT().LatestConfigVersion(gomock.Any()).Times(1).Return(4, nil)
+				taxCalculator.EXPECT().CheckRules(gomock.Any(), 2567, 4).Times(1).Return(nil)
+				taxCalculator.EXPECT().BatchCalculate(gomock.Any(), gomock.Len(1)).Times(1).Return(BatchCalculationResult{
+					Taxes: []CalculationResult{{TaxYear: 2567, ConfigVersion: 4, ConfigSource: ConfigSourceDatabase, TotalIncome: common.Baht(500000), Tax: common.Baht(29000)}},
+				}, nil)
+				calculationHistoryService.EXPECT().Record(gomock.Any(), CalculationSourceCSV, gomock.Len(1)).Times(1).Return(nil, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"row":2,"taxYear":2567,"configVersion":4,"configSource":"database","totalIncome":500000.0,"tax":29000.0,"taxRefund":0.0}
+`,
+		},
+		{
+			name: "Should reject file without calculating, given invalid rows",
+			csv:  "totalIncome,wht\n500000,0\n600000,600001\n",
+			mock: func(taxCalculator *MockCalculator, calculationHistoryService *MockCalculationHistoryService) {
+				taxCalculator.EXPECT().LatestConfigVersion(gomock.Any()).Times(1).Return(4, nil)
+				taxCalculator.EXPECT().CheckRules(gomock.Any(), 2567, 4).Times(1).Return(nil)
+				taxCalculator.EXPECT().BatchCalculate(gomock.Any(), gomock.Any()).Times(0)
+				calculationHistoryService.EXPECT().Record(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "Should reject file without calculating, given row of unknown tax year",
+			csv:  "totalIncome,taxYear\n500000,2567\n600000,2500\n700000,2500\n",
+			mock: func(taxCalculator *MockCalculator, calculationHistoryService *MockCalculationHistoryService) {
+				taxCalculator.EXPECT().LatestConfigVersion(gomock.Any()).Times(1).Return(4, nil)
+				taxCalculator.EXPECT().CheckRules(gomock.Any(), 2567, 4).Times(1).Return(nil)
+				taxCalculator.EXPECT().CheckRules(gomock.Any(), 2500, 4).Times(1).Return(fmt.Errorf("%w: %d", ErrUnknownTaxYear, 2500))
+				taxCalculator.EXPECT().BatchCalculate(gomock.Any(), gomock.Any()).Times(0)
+				calculationHistoryService.EXPECT().Record(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			expectedCode:   http.StatusBadRequest,
+			expectedErrors: []RowError{{Row: 3, Error: "unknown tax year: 2500"}, {Row: 4, Error: "unknown tax year: 2500"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			taxCalculator := NewMockCalculator(ctrl)
+			calculationHistoryService := NewMockCalculationHistoryService(ctrl)
+			tc.mock(taxCalculator, calculationHistoryService)
+
+			e := common.NewConfiguredEcho()
+			taxController := NewTaxController(taxCalculator, calculationHistoryService, common.AppConfig{})
+			taxController.RouteConfig(e)
+
+			var body bytes.Buffer
+			writer := multipart.NewWriter(&body)
+			part, err := writer.CreateFormFile("taxFile", "taxes.csv")
+			require.NoError(t, err)
+			_, err = part.Write([]byte(tc.csv))
+			require.NoError(t, err)
+			require.NoError(t, writer.Close())
+
+			request, err := http.NewRequest(http.MethodPost, "/tax/calculations/upload", &body)
+			require.NoError(t, err)
+
+			request.Header.Set("Content-Type", writer.FormDataContentType())
+			request.Header.Set("Accept", "application/x-ndjson")
+
+			recorder := httptest.NewRecorder()
+			e.ServeHTTP(recorder, request)
+
+			require.Equal(t, tc.expectedCode, recorder.Code)
+			if tc.expectedCode != http.StatusOK {
+				var response struct {
+					Code    common.ErrorCode `json:"code"`
+					Details []RowError       `json:"details"`
+				}
+				err = json.Unmarshal(recorder.Body.Bytes(), &response)
+				require.NoError(t, err)
+				require.Equal(t, ErrorCodeInvalidFile, response.Code)
+				if tc.expectedErrors != nil {
+					require.Equal(t, tc.expectedErrors, response.Details)
+				}
+				return
+			}
+
+			require.Equal(t, tc.expectedBody, recorder.Body.String())
+		})
+	}
+}
+
+func TestPostCalculateTaxFromUploadOverLimit(t *testing.T) {
+	testCases := []struct {
+		name      string
+		appConfig common.AppConfig
+		accept    string
+	}{
+		{
+			name:      "Should reject file, given more rows than MaxUploadRows",
+			appConfig: common.AppConfig{MaxUploadRows: 2},
+		},
+		{
+			name:      "Should reject streamed file, given more rows than MaxUploadRows",
+			appConfig: common.AppConfig{MaxUploadRows: 2},
+			accept:    "application/x-ndjson",
+		},
+		{
+			name:      "Should reject file, given body larger than MaxUploadSize",
+			appConfig: common.AppConfig{MaxUploadSize: "64B"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			taxCalculator := NewMockCalculator(ctrl)
+			taxCalculator.EXPECT().LatestConfigVersion(gomock.Any()).AnyTimes().Return(1, nil)
+			taxCalculator.EXPECT().CheckRules(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().Return(nil)
+			taxCalculator.EXPECT().BatchCalculate(gomock.Any(), gomock.Any()).Times(0)
+			calculationHistoryService := NewMockCalculationHistoryService(ctrl)
+
+			e := common.NewConfiguredEcho()
+			taxController := NewTaxController(taxCalculator, calculationHistoryService, tc.appConfig)
+			taxController.RouteConfig(e)
+
+			var body bytes.Buffer
+			writer := multipart.NewWriter(&body)
+			part, err := writer.CreateFormFile("taxFile", "taxes.csv")
+			require.NoError(t, err)
+			_, err = part.Write([]byte("totalIncome\n500000\n600000\n700000\n"))
+			require.NoError(t, err)
+			require.NoError(t, writer.Close())
+
+			request, err := http.NewRequest(http.MethodPost, "/tax/calculations/upload-csv", &body)
+			require.NoError(t, err)
+
+			request.Header.Set("Content-Type", writer.FormDataContentType())
+			if tc.accept != "" {
+				request.Header.Set("Accept", tc.accept)
+			}
+
+			recorder := httptest.NewRecorder()
+			e.ServeHTTP(recorder, request)
+
+			require.Equal(t, http.StatusRequestEntityTooLarge, recorder.Code)
+
+			var response common.ErrorResponse
+			err = json.Unmarshal(recorder.Body.Bytes(), &response)
+			require.NoError(t, err)
+			require.Equal(t, common.ErrorCodeRequestTooLarge, response.Code)
+		})
+	}
+}
+
+func TestPostCalculateTaxFromUploadAsPDF(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	taxCalculator := NewMockCalculator(ctrl)
+	taxCalculator.EXPECT().BatchCalculate(gomock.Any(), gomock.Len(1)).Times(1).Return(BatchCalculationResult{
+		Taxes: []CalculationResult{{TaxYear: 2567, TotalIncome: common.Baht(500000), Tax: common.Baht(29000)}},
+	}, nil)
+	calculationHistoryService := NewMockCalculationHistoryService(ctrl)
+	calculationHistoryService.EXPECT().Record(gomock.Any(), CalculationSourceCSV, gomock.Len(1)).Times(1).Return(nil, nil)
+
+	e := common.NewConfiguredEcho()
+	taxController := NewTaxController(taxCalculator, calculationHistoryService, common.AppConfig{})
+	taxController.RouteConfig(e)
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("taxFile", "taxes.csv")
+	require.NoError(t, err)
+	_, err = part.Write([]byte("taxpayerId,totalIncome\n1101700203450,500000\n"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	request, err := http.NewRequest(http.MethodPost, "/tax/calculations/upload-csv", &body)
+	require.NoError(t, err)
+
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+	request.Header.Set("Accept", "application/pdf")
+
+	recorder := httptest.NewRecorder()
+	e.ServeHTTP(recorder, request)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, "application/pdf", recorder.Header().Get("Content-Type"))
+	require.Equal(t, `attachment; filename="tax-calculations.pdf"`, recorder.Header().Get("Content-Disposition"))
+	require.True(t, bytes.HasPrefix(recorder.Body.Bytes(), []byte("%PDF-")))
+}
+
+func TestPostCalculateTaxFromUploadWithTaxLevel(t *testing.T) {
+	taxLevels := []TaxLevel{
+		{Level: "0-150,000", Tax: 0},
+		{Level: "150,001-500,000", Tax: common.Baht(29000)},
+	}
+
+	testCases := []struct {
+		name              string
+		query             string
+		expectedTaxLevels []TaxLevel
+	}{
+		{
+			name:              "Should return tax levels of every row, given includeTaxLevel",
+			query:             "?includeTaxLevel=true",
+			expectedTaxLevels: taxLevels,
+		},
+		{
+			name:              "Should leave tax levels out, given no includeTaxLevel",
+			query:             "",
+			expectedTaxLevels: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			taxCalculator := NewMockCalculator(ctrl)
+			taxCalculator.EXPECT().BatchCalculate(gomock.Any(), gomock.Len(1)).Times(1).Return(BatchCalculationResult{
+				Taxes: []CalculationResult{{TaxYear: 2567, TotalIncome: common.Baht(500000), Tax: common.Baht(29000), TaxLevels: taxLevels}},
+			}, nil)
+			calculationHistoryService := NewMockCalculationHistoryService(ctrl)
+			calculationHistoryService.EXPECT().Record(gomock.Any(), CalculationSourceCSV, gomock.Len(1)).Times(1).Return(nil, nil)
+
+			e := common.NewConfiguredEcho()
+			taxController := NewTaxController(taxCalculator, calculationHistoryService, common.AppConfig{})
+			taxController.RouteConfig(e)
+
+			var body bytes.Buffer
+			writer := multipart.NewWriter(&body)
+			part, err := writer.CreateFormFile("taxFile", "taxes.csv")
+			require.NoError(t, err)
+			_, err = part.Write([]byte("totalIncome\n500000\n"))
+			require.NoError(t, err)
+			require.NoError(t, writer.Close())
+
+			request, err := http.NewRequest(http.MethodPost, "/tax/calculations/upload-csv"+tc.query, &body)
+			require.NoError(t, err)
+
+			request.Header.Set("Content-Type", writer.FormDataContentType())
+
+			recorder := httptest.NewRecorder()
+			e.ServeHTTP(recorder, request)
+
+			require.Equal(t, http.StatusOK, recorder.Code)
+
+			var response BatchCalculationResult
+			err = json.Unmarshal(recorder.Body.Bytes(), &response)
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expectedTaxLevels, response.Taxes[0].TaxLevels)
+			require.Equal(t, &BatchSummary{
+				TotalTax:       common.Baht(29000),
+				TotalTaxRefund: 0,
+				HighestBrackets: []BracketCount{
+					{Level: "0-150,000", Count: 0},
+					{Level: "150,001-500,000", Count: 1},
+				},
+			}, response.Summary)
 		})
 	}
 }
@@ -2,6 +2,9 @@ package tax
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
 
 	"github.com/jmoiron/sqlx"
 )
@@ -18,19 +21,67 @@ func NewTaxConfigPostgresRepository(db sqlx.ExtContext) TaxConfigRepository {
 	}
 }
 
-func (t *taxConfigPostgresRepository) FindByName(ctx context.Context, name string) (*Config, error) {
-	sql := `
-		SELECT name, value
-		FROM tax_config
-		WHERE name = $1
+// FindSnapshot reads the version and the config of the tax year with a single
+// statement, so that the snapshot never mixes values of different versions.
+func (t *taxConfigPostgresRepository) FindSnapshot(ctx context.Context, taxYear int, version int) (*ConfigSnapshot, error) {
+	selectSQL := `
+		WITH as_of AS (
+			SELECT max(version) AS version
+			FROM tax_config_versions
+			WHERE $2 = 0 OR version = $2
+		)
+		SELECT as_of.version, snapshot.config, snapshot.brackets
+		FROM as_of
+		LEFT JOIN LATERAL (
+			SELECT config, brackets
+			FROM tax_config_versions
+			WHERE tax_year = $1 AND version <= as_of.version
+			ORDER BY version DESC
+			LIMIT 1
+		) snapshot ON true
 	`
 
-	row := t.db.QueryRowxContext(ctx, sql, name)
-
-	var config Config
-	if err := row.Scan(&config.Name, &config.Value); err != nil {
+	var asOf sql.NullInt64
+	var config, brackets []byte
+	row := t.db.QueryRowxContext(ctx, selectSQL, taxYear, version)
+	if err := row.Scan(&asOf, &config, &brackets); err != nil {
 		return nil, err
 	}
 
-	return &config, nil
+	if !asOf.Valid && version != 0 {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownConfigVersion, version)
+	}
+
+	snapshot := ConfigSnapshot{
+		Version: int(asOf.Int64),
+		TaxYear: taxYear,
+	}
+
+	if config != nil {
+		if err := json.Unmarshal(config, &snapshot.Values); err != nil {
+			return nil, err
+		}
+	}
+
+	if brackets != nil {
+		if err := json.Unmarshal(brackets, &snapshot.Brackets); err != nil {
+			return nil, err
+		}
+	}
+
+	return &snapshot, nil
+}
+
+func (t *taxConfigPostgresRepository) LatestVersion(ctx context.Context) (int, error) {
+	selectSQL := `
+		SELECT COALESCE(max(version), 0)
+		FROM tax_config_versions
+	`
+
+	var version int
+	if err := t.db.QueryRowxContext(ctx, selectSQL).Scan(&version); err != nil {
+		return 0, err
+	}
+
+	return version, nil
 }
@@ -0,0 +1,88 @@
+package tax
+
+import (
+	"github.com/chuckboliver/assessment-tax/common"
+	"github.com/shopspring/decimal"
+)
+
+type ConfigSource string
+
+const (
+	ConfigSourceDatabase ConfigSource = "database"
+	ConfigSourceDefault  ConfigSource = "default"
+)
+
+type TraceStepType string
+
+const (
+	TraceStepConfig            TraceStepType = "config"
+	TraceStepGrossIncome       TraceStepType = "grossIncome"
+	TraceStepExpenseDeduction  TraceStepType = "expenseDeduction"
+	TraceStepPersonalDeduction TraceStepType = "personalDeduction"
+	TraceStepAllowance         TraceStepType = "allowance"
+	TraceStepTaxableIncome     TraceStepType = "taxableIncome"
+	TraceStepBracket           TraceStepType = "bracket"
+	TraceStepProgressiveTax    TraceStepType = "progressiveTax"
+	TraceStepMinimumTax        TraceStepType = "minimumTax"
+	TraceStepWht               TraceStepType = "wht"
+	TraceStepResult            TraceStepType = "result"
+)
+
+// TraceStep is one step of an explained calculation. Only the fields relevant to
+// the step type are set.
+type TraceStep struct {
+	Step      TraceStepType `json:"step"`
+	Name      string        `json:"name,omitempty"`
+	Source    ConfigSource  `json:"source,omitempty"`
+	Requested *common.Money `json:"requested,omitempty"`
+	Cap       *common.Money `json:"cap,omitempty"`
+	Base      *common.Money `json:"base,omitempty"`
+	Rate      *float64      `json:"rate,omitempty"`
+	Amount    *common.Money `json:"amount,omitempty"`
+}
+
+// tracer collects trace steps of a calculation. A nil tracer records nothing, so
+// calculations that are not explained pay no cost.
+type tracer struct {
+	steps []TraceStep
+}
+
+func newTracer(enabled bool) *tracer {
+	if !enabled {
+		return nil
+	}
+
+	return &tracer{
+		steps: make([]TraceStep, 0),
+	}
+}
+
+func (t *tracer) record(step TraceStep) {
+	if t == nil {
+		return
+	}
+
+	t.steps = append(t.steps, step)
+}
+
+func (t *tracer) result() []TraceStep {
+	if t == nil {
+		return nil
+	}
+
+	return t.steps
+}
+
+func traceMoney(m common.Money) *common.Money {
+	return &m
+}
+
+// traceAmount rounds an unrounded amount of the calculation to satang for display.
+func traceAmount(d decimal.Decimal) *common.Money {
+	return traceMoney(common.RoundMoney(d))
+}
+
+func traceRate(rate decimal.Decimal) *float64 {
+	v := rate.InexactFloat64()
+	return &v
+}
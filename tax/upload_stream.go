@@ -0,0 +1,298 @@
+package tax
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/chuckboliver/assessment-tax/common"
+	"github.com/labstack/echo/v4"
+)
+
+// uploadStreamBatchSize is the number of rows calculated, recorded and flushed to
+// the client together when an upload is streamed.
+const uploadStreamBatchSize = 500
+
+// streamedRow is a row of a streamed upload waiting in a batch: either a valid
+// row, or the errors of an invalid one.
+type streamedRow struct {
+	row       parsedRow
+	rowErrors []RowError
+}
+
+// checkUploadedFile reads every row of an upload before it is streamed, and
+// returns the errors of the invalid rows sorted by row, so that an upload that is
+// not partial can be rejected before any result is sent. A row is invalid when it
+// fails validation or when its tax rules, those of its tax year at its config
+// version or configVersion, cannot be calculated with. Only the row errors and
+// the outcome of every tax year and config version are held in memory.
+func (c *TaxController) checkUploadedFile(ctx echo.Context, file io.Reader, parser parser, configVersion int) ([]RowError, error) {
+	type rulesKey struct {
+		taxYear       int
+		configVersion int
+	}
+	rulesErrors := make(map[rulesKey]error)
+
+	var checkErr error
+	rowErrors := make([]RowError, 0)
+	err := parser.readRows(file, func(row parsedRow, errs []RowError) error {
+		if len(errs) > 0 {
+			rowErrors = append(rowErrors, errs...)
+			return nil
+		}
+
+		if err := ctx.Validate(&row.request); err != nil {
+			violations, ok := common.ViolationsOf(err)
+			if !ok {
+				checkErr = err
+				return err
+			}
+
+			rowErrors = append(rowErrors, row.rowErrors(violations)...)
+			return nil
+		}
+
+		request := withConfigVersion(row.request, configVersion)
+		key := rulesKey{taxYear: request.taxYear(), configVersion: request.ConfigVersion}
+		rulesErr, ok := rulesErrors[key]
+		if !ok {
+			rulesErr = c.taxCalculator.CheckRules(ctx.Request().Context(), key.taxYear, key.configVersion)
+			rulesErrors[key] = rulesErr
+		}
+		if rulesErr == nil {
+			return nil
+		}
+
+		e, ok := requestError(rulesErr)
+		if !ok {
+			checkErr = calculationError(rulesErr)
+			return checkErr
+		}
+
+		rowErrors = append(rowErrors, RowError{Row: row.row, Error: e.Message})
+		return nil
+	})
+	if checkErr != nil {
+		return nil, checkErr
+	}
+	if err != nil {
+		return nil, uploadError(err)
+	}
+
+	return sortRowErrors(rowErrors), nil
+}
+
+// requestError returns the response of an error of calculating a row, and false
+// when it is not an error of the request, such as an unknown tax year, that can
+// be reported on the row alone.
+func requestError(err error) (*common.Error, bool) {
+	var e *common.Error
+	if !errors.As(calculationError(err), &e) || e.Status >= 500 {
+		return nil, false
+	}
+
+	return e, true
+}
+
+// streamTaxFromUploadedFile reads, calculates, records and writes back an upload
+// a batch of rows at a time, so that neither the rows nor the results of a large
+// file are held in memory. Invalid rows are written as row errors in place of
+// their result, so an upload that is not partial has to be checked with
+// checkUploadedFile first. Every row is calculated with
+// configVersion unless it asks for another version.
+func (c *TaxController) streamTaxFromUploadedFile(ctx echo.Context, file io.Reader, parser parser, configVersion int, mediaType string, writer resultWriter) error {
+	ctx.Response().Header().Set(echo.HeaderContentType, mediaType)
+
+	var streamErr error
+	batch := make([]streamedRow, 0, uploadStreamBatchSize)
+	err := parser.readRows(file, func(row parsedRow, rowErrors []RowError) error {
+		if len(rowErrors) == 0 {
+			if err := ctx.Validate(&row.request); err != nil {
+				violations, ok := common.ViolationsOf(err)
+				if !ok {
+					streamErr = err
+					return err
+				}
+
+				rowErrors = row.rowErrors(violations)
+			}
+		}
+
+		row.request = withConfigVersion(row.request, configVersion)
+		batch = append(batch, streamedRow{row: row, rowErrors: rowErrors})
+		if len(batch) < uploadStreamBatchSize {
+			return nil
+		}
+
+		streamErr = c.writeStreamBatch(ctx, writer, batch)
+		batch = batch[:0]
+		return streamErr
+	})
+	if err != nil && streamErr == nil {
+		streamErr = uploadError(err)
+	}
+	if streamErr == nil {
+		streamErr = c.writeStreamBatch(ctx, writer, batch)
+	}
+	if streamErr != nil {
+		return endStream(ctx, writer, streamErr)
+	}
+
+	return nil
+}
+
+// writeStreamBatch calculates and records the valid rows of batch, then writes
+// the outcome of every row in the order of the file.
+func (c *TaxController) writeStreamBatch(ctx echo.Context, writer resultWriter, batch []streamedRow) error {
+	validRows := make([]parsedRow, 0, len(batch))
+	for _, v := range batch {
+		if len(v.rowErrors) == 0 {
+			validRows = append(validRows, v.row)
+		}
+	}
+
+	results, calculationErrors, err := calculateRows(ctx.Request().Context(), c.taxCalculator, validRows)
+	if err != nil {
+		return err
+	}
+
+	entries := make([]calculationEntry, 0, len(results))
+	for _, v := range validRows {
+		if result, ok := results[v.row]; ok {
+			entries = append(entries, calculationEntry{request: v.request, response: result})
+		}
+	}
+
+	if len(entries) > 0 {
+		if _, err := c.calculationHistoryService.Record(ctx.Request().Context(), CalculationSourceCSV, entries); err != nil {
+			return fmt.Errorf("record calculations: %w", err)
+		}
+	}
+
+	for _, v := range batch {
+		rowErrors := v.rowErrors
+		if rowError, ok := calculationErrors[v.row.row]; ok {
+			rowErrors = []RowError{rowError}
+		}
+
+		if len(rowErrors) == 0 {
+			err = writer.writeResult(v.row, results[v.row.row])
+		} else {
+			err = writer.writeRowErrors(v.row, rowErrors)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return flushStream(ctx, writer)
+}
+
+// calculateRows calculates rows with a single BatchCalculate, returning the result
+// or the error of every row by its line in the file. When the batch fails for an
+// error of the request, such as an unknown tax year, the rows are calculated one
+// by one so that the error is only reported on the rows it is about.
+func calculateRows(ctx context.Context, taxCalculator Calculator, rows []parsedRow) (map[int]CalculationResult, map[int]RowError, error) {
+	results := make(map[int]CalculationResult, len(rows))
+	rowErrors := make(map[int]RowError)
+	if len(rows) == 0 {
+		return results, rowErrors, nil
+	}
+
+	requests := make([]calculationRequest, 0, len(rows))
+	for _, v := range rows {
+		requests = append(requests, v.request)
+	}
+
+	result, err := taxCalculator.BatchCalculate(ctx, requests)
+	if err == nil {
+		for i, v := range rows {
+			result.Taxes[i].Row = v.row
+			results[v.row] = result.Taxes[i]
+		}
+
+		return results, rowErrors, nil
+	}
+
+	e, ok := requestError(err)
+	if !ok {
+		return nil, nil, calculationError(err)
+	}
+
+	if len(rows) == 1 {
+		rowErrors[rows[0].row] = RowError{Row: rows[0].row, Error: e.Message}
+		return results, rowErrors, nil
+	}
+
+	for _, v := range rows {
+		rowResults, rowRowErrors, err := calculateRows(ctx, taxCalculator, []parsedRow{v})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for row, result := range rowResults {
+			results[row] = result
+		}
+		for row, rowError := range rowRowErrors {
+			rowErrors[row] = rowError
+		}
+	}
+
+	return results, rowErrors, nil
+}
+
+// calculatedRows splits rows into those calculated, with their results in the
+// same order, and the errors of those that could not be, sorted by row.
+func calculatedRows(rows []parsedRow, results map[int]CalculationResult, calculationErrors map[int]RowError) ([]parsedRow, []CalculationResult, []RowError) {
+	calculated := make([]parsedRow, 0, len(results))
+	taxes := make([]CalculationResult, 0, len(results))
+	rowErrors := make([]RowError, 0, len(calculationErrors))
+	for _, v := range rows {
+		if result, ok := results[v.row]; ok {
+			calculated = append(calculated, v)
+			taxes = append(taxes, result)
+			continue
+		}
+
+		if rowError, ok := calculationErrors[v.row]; ok {
+			rowErrors = append(rowErrors, rowError)
+		}
+	}
+
+	return calculated, taxes, rowErrors
+}
+
+func flushStream(ctx echo.Context, writer resultWriter) error {
+	if err := writer.flush(); err != nil {
+		return err
+	}
+
+	ctx.Response().Flush()
+	return nil
+}
+
+// endStream reports an error that stopped a streamed upload. Until the response is
+// committed the error is returned to common.HTTPErrorHandler as usual. Afterwards
+// the status has been sent, so the error ends the stream as a row error with row
+// 0 instead.
+func endStream(ctx echo.Context, writer resultWriter, err error) error {
+	if !ctx.Response().Committed {
+		return err
+	}
+
+	message := "internal server error"
+	var e *common.Error
+	if errors.As(err, &e) && e.Status < 500 {
+		message = e.Message
+	} else {
+		slog.Error("Failed to stream upload", "method", ctx.Request().Method, "path", ctx.Path(), "err", err)
+	}
+
+	if err := writer.writeRowErrors(parsedRow{}, []RowError{{Error: message}}); err != nil {
+		return err
+	}
+
+	return flushStream(ctx, writer)
+}
@@ -0,0 +1,103 @@
+package tax
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/chuckboliver/assessment-tax/common"
+)
+
+var _ common.Validatable = calculationRequest{}
+
+// Validate checks the rules of a calculation request that validate tags cannot
+// express. It is run by ctx.Validate for JSON requests and for every CSV row.
+//
+// Only allowances claimed per person, such as child, may be claimed more than
+// once. A repeated claim of any other type is rejected rather than summed, since
+// it is most likely a mistake.
+func (r calculationRequest) Validate() error {
+	violations := make([]common.Violation, 0)
+
+	if len(r.Incomes) > 0 {
+		supportedTypes := supportedIncomeTypes()
+		for i, v := range r.Incomes {
+			if !isSupportedIncomeType(supportedTypes, v.IncomeType) {
+				violations = append(violations, common.Violation{
+					Field: fmt.Sprintf("incomes[%d].incomeType", i),
+					Rule:  "oneof",
+					Param: joinIncomeTypes(supportedTypes),
+				})
+			}
+		}
+	}
+
+	if r.Wht > r.grossIncome() {
+		param := "totalIncome"
+		if len(r.Incomes) > 0 {
+			param = "incomes"
+		}
+
+		violations = append(violations, common.Violation{
+			Field: "wht",
+			Rule:  "ltefield",
+			Param: param,
+		})
+	}
+
+	registry := defaultAllowanceRegistry()
+	claimed := make(map[AllowanceType]struct{})
+	for i, v := range r.Allowances {
+		field := fmt.Sprintf("allowances[%d].allowanceType", i)
+
+		if !registry.isSupported(v.AllowanceType) {
+			violations = append(violations, common.Violation{
+				Field: field,
+				Rule:  "oneof",
+				Param: joinAllowanceTypes(registry.supportedTypes()),
+			})
+			continue
+		}
+
+		if _, ok := claimed[v.AllowanceType]; ok && !registry.isPerClaim(v.AllowanceType) {
+			violations = append(violations, common.Violation{
+				Field: field,
+				Rule:  "unique",
+			})
+		}
+		claimed[v.AllowanceType] = struct{}{}
+	}
+
+	if len(violations) > 0 {
+		return common.NewValidationError(violations...)
+	}
+
+	return nil
+}
+
+func isSupportedIncomeType(supportedTypes []IncomeType, incomeType IncomeType) bool {
+	for _, v := range supportedTypes {
+		if v == incomeType {
+			return true
+		}
+	}
+
+	return false
+}
+
+func joinIncomeTypes(incomeTypes []IncomeType) string {
+	values := make([]string, 0, len(incomeTypes))
+	for _, v := range incomeTypes {
+		values = append(values, string(v))
+	}
+
+	return strings.Join(values, " ")
+}
+
+func joinAllowanceTypes(allowanceTypes []AllowanceType) string {
+	values := make([]string, 0, len(allowanceTypes))
+	for _, v := range allowanceTypes {
+		values = append(values, string(v))
+	}
+
+	return strings.Join(values, " ")
+}
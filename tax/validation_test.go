@@ -0,0 +1,166 @@
+package tax
+
+import (
+	"testing"
+
+	"github.com/chuckboliver/assessment-tax/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateCalculationRequest(t *testing.T) {
+	testCases := []struct {
+		name               string
+		request            calculationRequest
+		expectedViolations []common.Violation
+	}{
+		{
+			name: "Should pass, given valid request",
+			request: calculationRequest{
+				TotalIncome: common.Baht(500000),
+				Wht:         common.Baht(25000),
+				Allowances: []Allowance{
+					{AllowanceType: AllowanceDonation, Amount: common.Baht(1000)},
+					{AllowanceType: AllowanceKReceipt, Amount: common.Baht(1000)},
+				},
+			},
+		},
+		{
+			name:               "Should reject, given negative total income",
+			request:            calculationRequest{TotalIncome: common.Baht(-1)},
+			expectedViolations: []common.Violation{{Field: "totalIncome", Rule: "gte", Param: "0"}},
+		},
+		{
+			name:               "Should reject, given negative wht",
+			request:            calculationRequest{TotalIncome: common.Baht(500000), Wht: common.Baht(-1)},
+			expectedViolations: []common.Violation{{Field: "wht", Rule: "gte", Param: "0"}},
+		},
+		{
+			name:               "Should reject, given wht greater than total income",
+			request:            calculationRequest{TotalIncome: common.Baht(500000), Wht: common.Money(50000001)},
+			expectedViolations: []common.Violation{{Field: "wht", Rule: "ltefield", Param: "totalIncome"}},
+		},
+		{
+			name: "Should reject, given wht greater than sum of incomes",
+			request: calculationRequest{
+				Incomes: []Income{
+					{IncomeType: IncomeSalary, Amount: common.Baht(100000)},
+					{IncomeType: IncomeInterest, Amount: common.Baht(10000)},
+				},
+				Wht: common.Baht(110001),
+			},
+			expectedViolations: []common.Violation{{Field: "wht", Rule: "ltefield", Param: "incomes"}},
+		},
+		{
+			name: "Should pass, given wht equal to total income",
+			request: calculationRequest{
+				TotalIncome: common.Baht(500000),
+				Wht:         common.Baht(500000),
+			},
+		},
+		{
+			name: "Should reject, given negative income amount",
+			request: calculationRequest{
+				Incomes: []Income{{IncomeType: IncomeSalary, Amount: common.Baht(-1)}},
+			},
+			expectedViolations: []common.Violation{{Field: "incomes[0].amount", Rule: "gte", Param: "0"}},
+		},
+		{
+			name: "Should reject, given unknown income type",
+			request: calculationRequest{
+				Incomes: []Income{{IncomeType: "lottery", Amount: common.Baht(1000)}},
+			},
+			expectedViolations: []common.Violation{{
+				Field: "incomes[0].incomeType",
+				Rule:  "oneof",
+				Param: "business contracting dividend freelance interest professional rental royalty salary",
+			}},
+		},
+		{
+			name: "Should reject, given negative allowance amount",
+			request: calculationRequest{
+				TotalIncome: common.Baht(500000),
+				Allowances:  []Allowance{{AllowanceType: AllowanceDonation, Amount: common.Baht(-1)}},
+			},
+			expectedViolations: []common.Violation{{Field: "allowances[0].amount", Rule: "gte", Param: "0"}},
+		},
+		{
+			name:               "Should reject, given total income over a trillion baht",
+			request:            calculationRequest{TotalIncome: common.Money(100000000000001)},
+			expectedViolations: []common.Violation{{Field: "totalIncome", Rule: "lte", Param: "1000000000000"}},
+		},
+		{
+			name: "Should reject, given income amount over a trillion baht",
+			request: calculationRequest{
+				Incomes: []Income{{IncomeType: IncomeSalary, Amount: common.Money(100000000000001)}},
+			},
+			expectedViolations: []common.Violation{{Field: "incomes[0].amount", Rule: "lte", Param: "1000000000000"}},
+		},
+		{
+			name: "Should reject, given allowance amount over a trillion baht",
+			request: calculationRequest{
+				TotalIncome: common.Baht(500000),
+				Allowances:  []Allowance{{AllowanceType: AllowanceDoubleDonation, Amount: common.Money(100000000000001)}},
+			},
+			expectedViolations: []common.Violation{{Field: "allowances[0].amount", Rule: "lte", Param: "1000000000000"}},
+		},
+		{
+			name: "Should reject, given missing allowance type",
+			request: calculationRequest{
+				TotalIncome: common.Baht(500000),
+				Allowances:  []Allowance{{Amount: common.Baht(1000)}},
+			},
+			expectedViolations: []common.Violation{{Field: "allowances[0].allowanceType", Rule: "required"}},
+		},
+		{
+			name: "Should reject, given unknown allowance type",
+			request: calculationRequest{
+				TotalIncome: common.Baht(500000),
+				Allowances:  []Allowance{{AllowanceType: "lottery", Amount: common.Baht(1000)}},
+			},
+			expectedViolations: []common.Violation{{
+				Field: "allowances[0].allowanceType",
+				Rule:  "oneof",
+				Param: "child donation double-donation health-insurance home-loan-interest k-receipt life-insurance parent-care provident-fund rmf social-security spouse ssf thai-esg",
+			}},
+		},
+		{
+			name: "Should reject, given repeated donation",
+			request: calculationRequest{
+				TotalIncome: common.Baht(500000),
+				Allowances: []Allowance{
+					{AllowanceType: AllowanceDonation, Amount: common.Baht(1000)},
+					{AllowanceType: AllowanceKReceipt, Amount: common.Baht(1000)},
+					{AllowanceType: AllowanceDonation, Amount: common.Baht(2000)},
+				},
+			},
+			expectedViolations: []common.Violation{{Field: "allowances[2].allowanceType", Rule: "unique"}},
+		},
+		{
+			name: "Should pass, given one child allowance per child",
+			request: calculationRequest{
+				TotalIncome: common.Baht(500000),
+				Allowances: []Allowance{
+					{AllowanceType: AllowanceChild, Amount: common.Baht(30000)},
+					{AllowanceType: AllowanceChild, Amount: common.Baht(30000)},
+					{AllowanceType: AllowanceParentCare, Amount: common.Baht(30000)},
+					{AllowanceType: AllowanceParentCare, Amount: common.Baht(30000)},
+				},
+			},
+		},
+	}
+
+	validator := &common.EchoValidator{Validator: common.NewValidator()}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validator.Validate(&tc.request)
+			if len(tc.expectedViolations) == 0 {
+				require.NoError(t, err)
+				return
+			}
+
+			violations, ok := common.ViolationsOf(err)
+			require.True(t, ok)
+			require.Equal(t, tc.expectedViolations, violations)
+		})
+	}
+}
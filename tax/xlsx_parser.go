@@ -0,0 +1,109 @@
+package tax
+
+import (
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+var _ parser = (*xlsxParser)(nil)
+
+// xlsxUnzipRatio is how many times the size of the largest upload a workbook may
+// unzip to.
+const xlsxUnzipRatio = 10
+
+// xlsxParser reads the first sheet of an Excel workbook laid out like a CSV
+// upload: a header row followed by a row per calculation.
+type xlsxParser struct {
+	// maxFileSize limits the size the workbook unzips to. Zero keeps the limits
+	// of excelize.
+	maxFileSize int64
+}
+
+func newXLSXParser() parser {
+	return &xlsxParser{}
+}
+
+// limitUnzipSize returns p limited to unzip a workbook of an upload of at most
+// maxFileSize bytes, so that a highly compressed workbook cannot expand to
+// gigabytes. Parsers of other formats read the file as a stream and are returned
+// as is. Zero puts no limit.
+func limitUnzipSize(p parser, maxFileSize int64) parser {
+	if _, ok := p.(*xlsxParser); !ok || maxFileSize <= 0 {
+		return p
+	}
+
+	return &xlsxParser{maxFileSize: maxFileSize}
+}
+
+// options limits the whole workbook to xlsxUnzipRatio times maxFileSize, and
+// the worksheets kept in memory to maxFileSize, past which excelize extracts
+// them to temporary files.
+func (x *xlsxParser) options() excelize.Options {
+	if x.maxFileSize <= 0 {
+		return excelize.Options{}
+	}
+
+	return excelize.Options{
+		UnzipSizeLimit:    x.maxFileSize * xlsxUnzipRatio,
+		UnzipXMLSizeLimit: min(x.maxFileSize, excelize.StreamChunkSize),
+	}
+}
+
+// readRows reads the whole workbook into memory before the first row, since an
+// xlsx file is a zip archive that cannot be read as a stream.
+func (x *xlsxParser) readRows(reader io.Reader, yield func(row parsedRow, rowErrors []RowError) error) error {
+	file, err := excelize.OpenReader(reader, x.options())
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	sheets := file.GetSheetList()
+	if len(sheets) == 0 {
+		return errors.New("empty file")
+	}
+
+	rows, err := file.Rows(sheets[0])
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	line := 0
+	return parseTable(func() ([]string, int, error) {
+		for rows.Next() {
+			line++
+
+			// Raw values, so that a cell formatted as "500,000.00" reads as "500000".
+			cells, err := rows.Columns(excelize.Options{RawCellValue: true})
+			if err != nil {
+				return nil, 0, err
+			}
+
+			if isEmptyRow(cells) {
+				continue
+			}
+
+			return cells, line, nil
+		}
+
+		if err := rows.Error(); err != nil {
+			return nil, 0, err
+		}
+
+		return nil, 0, io.EOF
+	}, true, yield)
+}
+
+func isEmptyRow(cells []string) bool {
+	for _, v := range cells {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+
+	return true
+}
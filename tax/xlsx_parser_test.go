@@ -0,0 +1,93 @@
+package tax
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/chuckboliver/assessment-tax/common"
+	"github.com/stretchr/testify/require"
+	"github.com/xuri/excelize/v2"
+)
+
+func TestParseXLSX(t *testing.T) {
+	file := excelize.NewFile()
+	defer file.Close()
+
+	sheet := file.GetSheetName(0)
+	rows := [][]interface{}{
+		{"Total Income", "WHT", "k-receipt", "donation"},
+		{500000, 25000, 50000, 1000},
+		{},
+		{600000.5, 0},
+		{"abc", 0, 0, 0},
+	}
+	for i, row := range rows {
+		cell, err := excelize.CoordinatesToCellName(1, i+1)
+		require.NoError(t, err)
+		require.NoError(t, file.SetSheetRow(sheet, cell, &row))
+	}
+
+	style, err := file.NewStyle(&excelize.Style{NumFmt: 4})
+	require.NoError(t, err)
+	require.NoError(t, file.SetCellStyle(sheet, "A2", "A2", style))
+
+	var buf bytes.Buffer
+	require.NoError(t, file.Write(&buf))
+
+	parsedRows, rowErrors, err := parseCalculationRequest(newXLSXParser(), &buf)
+	require.NoError(t, err)
+
+	require.Len(t, parsedRows, 2)
+	require.Equal(t, 2, parsedRows[0].row)
+	require.Equal(t, calculationRequest{
+		TotalIncome: common.Baht(500000),
+		Wht:         common.Baht(25000),
+		Allowances: []Allowance{
+			{AllowanceType: AllowanceKReceipt, Amount: common.Baht(50000)},
+			{AllowanceType: AllowanceDonation, Amount: common.Baht(1000)},
+		},
+	}, parsedRows[0].request)
+
+	require.Equal(t, 4, parsedRows[1].row)
+	require.Equal(t, calculationRequest{
+		TotalIncome: common.Money(60000050),
+		Allowances:  []Allowance{},
+	}, parsedRows[1].request)
+
+	require.Equal(t, []RowError{
+		{Row: 5, Column: "totalIncome", Value: "abc", Error: `failed to parse totalIncome: invalid amount "abc"`},
+	}, rowErrors)
+}
+
+func TestParseXLSXWithInvalidFile(t *testing.T) {
+	_, _, err := parseCalculationRequest(newXLSXParser(), bytes.NewReader([]byte("totalIncome\n500000\n")))
+	require.Error(t, err)
+}
+
+func TestParseXLSXWithUnzipSizeLimit(t *testing.T) {
+	file := excelize.NewFile()
+	defer file.Close()
+
+	sheet := file.GetSheetName(0)
+	require.NoError(t, file.SetSheetRow(sheet, "A1", &[]interface{}{"totalIncome", "wht"}))
+	for i := 2; i <= 5000; i++ {
+		cell, err := excelize.CoordinatesToCellName(1, i)
+		require.NoError(t, err)
+		require.NoError(t, file.SetSheetRow(sheet, cell, &[]interface{}{500000, 0}))
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, file.Write(&buf))
+	workbook := buf.Bytes()
+
+	t.Run("Should parse workbook, given it unzips within the limit", func(t *testing.T) {
+		parsedRows, _, err := parseCalculationRequest(limitUnzipSize(newXLSXParser(), int64(len(workbook))), bytes.NewReader(workbook))
+		require.NoError(t, err)
+		require.Len(t, parsedRows, 4999)
+	})
+
+	t.Run("Should return error, given it unzips over the limit", func(t *testing.T) {
+		_, _, err := parseCalculationRequest(limitUnzipSize(newXLSXParser(), 1024), bytes.NewReader(workbook))
+		require.ErrorContains(t, err, "unzip size exceeds")
+	})
+}